@@ -0,0 +1,37 @@
+package consumer
+
+import (
+	"encoding/json"
+	"golang.org/x/net/context"
+	"order/domain"
+	"platform/broker"
+)
+
+// OrderConsumer represent the message handler for product service replies
+type OrderConsumer struct {
+	OrderService domain.OrderService
+}
+
+// NewOrderConsumer will start consuming the order reply queue
+func NewOrderConsumer(b broker.Broker, os domain.OrderService) error {
+	consumer := &OrderConsumer{OrderService: os}
+
+	return b.Consume(domain.OrderReplyQueue, consumer.Reply)
+}
+
+// Reply will resolve the pending order with the outcome of the stock reservation. A reply
+// for an order that is gone or can no longer move is dropped, any other failure delivers
+// the reply again, so stock held for an order is not left waiting for the request expiry.
+func (oc *OrderConsumer) Reply(ctx context.Context, body []byte) error {
+	var reply domain.OrderReply
+	err := json.Unmarshal(body, &reply)
+	if err != nil {
+		return err
+	}
+
+	err = oc.OrderService.Resolve(ctx, &reply)
+	if err == domain.ErrNotFound || err == domain.ErrInvalidTransition {
+		return err
+	}
+	return broker.Requeue(err)
+}
@@ -0,0 +1,59 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"order/consumer"
+	"order/domain"
+	"order/domain/mocks"
+	"platform/broker"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOrderConsumer_Reply(t *testing.T) {
+	mockOrderService := new(mocks.OrderService)
+	reply := &domain.OrderReply{OrderID: 1, Accepted: true}
+	mockOrderService.On("Resolve", mock.Anything, reply).Return(nil).Once()
+
+	b := broker.NewMemoryBroker()
+	err := consumer.NewOrderConsumer(b, mockOrderService)
+	assert.NoError(t, err)
+
+	err = b.Publish(context.TODO(), domain.OrderReplyQueue, []byte(`{"order_id":1,"accepted":true}`))
+	assert.NoError(t, err)
+	mockOrderService.AssertExpectations(t)
+}
+
+func TestOrderConsumer_Reply_Error(t *testing.T) {
+	mockOrderService := new(mocks.OrderService)
+	handler := consumer.OrderConsumer{OrderService: mockOrderService}
+
+	t.Run("malformed", func(t *testing.T) {
+		err := handler.Reply(context.TODO(), []byte(`not json`))
+		assert.Error(t, err)
+	})
+
+	t.Run("transient", func(t *testing.T) {
+		mockOrderService.On("Resolve", mock.Anything, mock.AnythingOfType("*domain.OrderReply")).Return(context.DeadlineExceeded).Once()
+
+		// The stock may be held already, so the reply is delivered again
+		err := handler.Reply(context.TODO(), []byte(`{"order_id":1,"accepted":true}`))
+		assert.True(t, broker.IsRequeue(err))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		mockOrderService.AssertExpectations(t)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		for _, want := range []error{domain.ErrNotFound, domain.ErrInvalidTransition} {
+			mockOrderService.On("Resolve", mock.Anything, mock.AnythingOfType("*domain.OrderReply")).Return(want).Once()
+
+			err := handler.Reply(context.TODO(), []byte(`{"order_id":1,"accepted":false,"reason":"insufficient_stock"}`))
+			assert.Equal(t, want, err)
+			assert.False(t, broker.IsRequeue(err))
+		}
+		mockOrderService.AssertExpectations(t)
+	})
+}
@@ -1,16 +1,28 @@
 package controller
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"github.com/labstack/echo/v4"
-	"github.com/spf13/viper"
-	"io/ioutil"
+	"math"
 	"net/http"
 	"order/domain"
+	"platform/breaker"
+	"platform/money"
+	"platform/pagination"
 	"strconv"
 )
 
+// Codes telling the clients an order was refused because its user or one of its
+// products does not exist, because a product is no longer sold at the expected price, or
+// because its products are not sold in the same currency
+const (
+	codeUserNotFound     = "user_not_found"
+	codeProductNotFound  = "product_not_found"
+	codePriceChanged     = "price_changed"
+	codeCurrencyMismatch = "currency_mismatch"
+	codeVariantRequired  = "variant_required"
+)
+
 type OrderController struct {
 	OrderService domain.OrderService
 }
@@ -20,20 +32,75 @@ func NewOrderController(e *echo.Echo, os domain.OrderService) {
 
 	group := e.Group("/api/v1")
 	group.GET("/orders", controller.Fetch)
+	group.GET("/orders/:id", controller.GetByID)
 	group.POST("/orders", controller.Store)
+	group.PUT("/orders/:id", controller.Update)
+	group.DELETE("/orders/:id", controller.Delete)
+	group.POST("/orders/:id/pay", controller.Pay)
+	group.POST("/orders/:id/ship", controller.Ship)
+	group.POST("/orders/:id/deliver", controller.Deliver)
+	group.POST("/orders/:id/cancel", controller.Cancel)
 }
 
+// Fetch method will fetch a page of orders, filtered by ?user_id=&status=&currency= and
+// sorted by ?sort=
 func (oc *OrderController) Fetch(c echo.Context) error {
+	q, err := pagination.Parse(c, domain.OrderSorts...)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	filter := domain.OrderFilter{
+		Status:   domain.OrderStatus(c.QueryParam("status")),
+		Currency: c.QueryParam("currency"),
+	}
+	if userID := c.QueryParam("user_id"); userID != "" {
+		paramID, errParam := strconv.Atoi(userID)
+		if errParam != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"err": errParam.Error(),
+			})
+		}
+		filter.UserID = uint32(paramID)
+	}
+
 	ctx := c.Request().Context()
+	list, next, err := oc.OrderService.Fetch(ctx, filter, q)
+	if err == domain.ErrCurrencyRequired {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
 
-	list, err := oc.OrderService.Fetch(ctx)
+	return c.JSON(http.StatusOK, pagination.Page{Data: list, NextCursor: next})
+}
+
+func (oc *OrderController) GetByID(c echo.Context) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	id := uint32(paramID)
+	ctx := c.Request().Context()
+
+	order, err := oc.OrderService.GetByID(ctx, id)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, echo.Map{
 			"err": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, list)
+	return c.JSON(http.StatusOK, order)
 }
 
 func (oc *OrderController) Store(c echo.Context) (err error)  {
@@ -45,50 +112,194 @@ func (oc *OrderController) Store(c echo.Context) (err error)  {
 		})
 	}
 
-	// Check and update stock at Product Service
-	prodID := order.ProductID
-	qty := order.Qty
-
-	//Encode the data
-	postBody, _ := json.Marshal(map[string]int{
-		"qty": qty,
-	})
+	// Stock is reserved asynchronously by the product service, the order stays pending until it replies
+	ctx := c.Request().Context()
+	err = oc.OrderService.Store(ctx, &order)
+	if err != nil {
+		return orderError(c, err)
+	}
 
-	responseBody := bytes.NewBuffer(postBody)
+	return c.JSON(http.StatusAccepted, order)
+}
 
-	//Leverage Go's HTTP Post function to make request
-	resp, err := http.Post(viper.GetString(`product.url`)+strconv.Itoa(int(prodID)), "application/json", responseBody)
+func (oc *OrderController) Update(c echo.Context) error {
+	var order domain.Order
+	err := c.Bind(&order)
 	if err != nil {
 		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
 			"err": err.Error(),
 		})
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
-		ctx := c.Request().Context()
-		err = oc.OrderService.Store(ctx, &order)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, echo.Map{
-				"err": err.Error(),
-			})
-		}
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	id := uint32(paramID)
+	ctx := c.Request().Context()
 
-		return c.JSON(http.StatusCreated, echo.Map{
-			"message": "order created",
+	err = oc.OrderService.Update(ctx, &order, id)
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
 		})
-	} else {
-		//Read the response body
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, echo.Map{
-				"err": err.Error(),
-			})
-		}
-		sb := string(body)
+	}
+	if err != nil {
+		return orderError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oc *OrderController) Delete(c echo.Context) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	id := uint32(paramID)
+	ctx := c.Request().Context()
+
+	err = oc.OrderService.Delete(ctx, id)
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err == domain.ErrOrderLocked {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"err": sb,
+			"err": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oc *OrderController) Pay(c echo.Context) error {
+	return oc.updateStatus(c, domain.StatusPaid)
+}
+
+func (oc *OrderController) Ship(c echo.Context) error {
+	return oc.updateStatus(c, domain.StatusShipped)
+}
+
+func (oc *OrderController) Deliver(c echo.Context) error {
+	return oc.updateStatus(c, domain.StatusDelivered)
+}
+
+func (oc *OrderController) Cancel(c echo.Context) error {
+	return oc.updateStatus(c, domain.StatusCancelled)
+}
+
+func (oc *OrderController) updateStatus(c echo.Context, status domain.OrderStatus) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	id := uint32(paramID)
+	ctx := c.Request().Context()
+
+	order, err := oc.OrderService.UpdateStatus(ctx, id, status)
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, order)
+	case domain.ErrNotFound:
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	case domain.ErrInvalidTransition, domain.ErrReservationExpired, domain.ErrInsufficientStock:
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err":    err.Error(),
+			"status": order.Status,
+		})
+	default:
+		return serverError(c, err)
+	}
+}
+
+// orderError will answer an order that can not be placed or changed as it is with a 422,
+// or with a 409 telling the current price when the client expected another one
+func orderError(c echo.Context, err error) error {
+	var changed *domain.PriceChangedError
+	if errors.As(err, &changed) {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err":        err.Error(),
+			"code":       codePriceChanged,
+			"product_id": changed.ProductID,
+			"price":      changed.Price,
+		})
+	}
+	if errors.Is(err, money.ErrCurrencyMismatch) {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err":  err.Error(),
+			"code": codeCurrencyMismatch,
 		})
 	}
 
-}
\ No newline at end of file
+	switch err {
+	case domain.ErrOrderLocked:
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err": err.Error(),
+		})
+	case domain.ErrUserNotFound:
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err":  err.Error(),
+			"code": codeUserNotFound,
+		})
+	case domain.ErrProductNotFound:
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err":  err.Error(),
+			"code": codeProductNotFound,
+		})
+	case domain.ErrVariantRequired:
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err":  err.Error(),
+			"code": codeVariantRequired,
+		})
+	case domain.ErrNoItems, domain.ErrInvalidQty, domain.ErrDuplicateItem, money.ErrOverflow:
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	default:
+		return serverError(c, err)
+	}
+}
+
+// serverError will answer an error the client can do nothing about, with a 503 telling
+// when to retry while the product service is cut off by its circuit breaker, or a plain
+// 503 while the user service can not answer
+func serverError(c echo.Context, err error) error {
+	var open *breaker.OpenError
+	if errors.As(err, &open) {
+		retryAfter := int(math.Ceil(open.RetryAfter.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if errors.Is(err, domain.ErrUserUnavailable) {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusInternalServerError, echo.Map{
+		"err": err.Error(),
+	})
+}
@@ -0,0 +1,448 @@
+package controller_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"order/controller"
+	"order/domain"
+	"order/domain/mocks"
+	"order/productclient"
+	"order/service"
+	"platform/breaker"
+	"platform/money"
+	"platform/pagination"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderController_Fetch(t *testing.T) {
+	mockListOrder := []domain.Order{{ID: 1, UserID: 5, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}}}}
+
+	t.Run("all", func(t *testing.T) {
+		mockOrderService := new(mocks.OrderService)
+		mockOrderService.On("Fetch", mock.Anything, domain.OrderFilter{}, pagination.Query{Limit: 20, Sort: "id"}).Return(mockListOrder, "", nil).Once()
+
+		e := echo.New()
+		req, err := http.NewRequest(echo.GET, "/api/v1/orders", strings.NewReader(""))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := controller.OrderController{OrderService: mockOrderService}
+		err = handler.Fetch(c)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"data":[{"id":1,"user_id":5,"items":[{"id":0,"order_id":0,"product_id":3,"qty":2,"unit_price":{"amount":0,"currency":""},"subtotal":{"amount":0,"currency":""}}],"total":{"amount":0,"currency":""},"status":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}],"next_cursor":""}`, rec.Body.String())
+		mockOrderService.AssertExpectations(t)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		mockOrderService := new(mocks.OrderService)
+		filter := domain.OrderFilter{UserID: 5, Status: domain.StatusPaid}
+		mockOrderService.On("Fetch", mock.Anything, filter, pagination.Query{Limit: 2, Sort: "created_at", Desc: true}).Return(mockListOrder, "next", nil).Once()
+
+		e := echo.New()
+		req, err := http.NewRequest(echo.GET, "/api/v1/orders?user_id=5&status=PAID&limit=2&sort=-created_at", strings.NewReader(""))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := controller.OrderController{OrderService: mockOrderService}
+		err = handler.Fetch(c)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"user_id":5`)
+		assert.Contains(t, rec.Body.String(), `"next_cursor":"next"`)
+		mockOrderService.AssertExpectations(t)
+	})
+
+	t.Run("total without currency", func(t *testing.T) {
+		mockOrderService := new(mocks.OrderService)
+		mockOrderService.On("Fetch", mock.Anything, domain.OrderFilter{}, pagination.Query{Limit: 20, Sort: "total"}).Return(nil, "", domain.ErrCurrencyRequired).Once()
+
+		e := echo.New()
+		req, err := http.NewRequest(echo.GET, "/api/v1/orders?sort=total", strings.NewReader(""))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := controller.OrderController{OrderService: mockOrderService}
+		err = handler.Fetch(c)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		mockOrderService.AssertExpectations(t)
+	})
+
+	t.Run("invalid user", func(t *testing.T) {
+		mockOrderService := new(mocks.OrderService)
+
+		e := echo.New()
+		req, err := http.NewRequest(echo.GET, "/api/v1/orders?user_id=abc", strings.NewReader(""))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := controller.OrderController{OrderService: mockOrderService}
+		err = handler.Fetch(c)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		mockOrderService.AssertExpectations(t)
+	})
+}
+
+func TestOrderController_GetByID(t *testing.T) {
+	num := 1
+	mockOrderService := new(mocks.OrderService)
+	mockOrderService.On("GetByID", mock.Anything, uint32(num)).Return(domain.Order{ID: 1}, nil).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/orders/"+strconv.Itoa(num), strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/orders/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(num))
+
+	handler := controller.OrderController{OrderService: mockOrderService}
+	err = handler.GetByID(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockOrderService.AssertExpectations(t)
+}
+
+func TestOrderController_Store(t *testing.T) {
+	mockOrderService := new(mocks.OrderService)
+	mockOrderService.On("Store", mock.Anything, mock.AnythingOfType("*domain.Order")).
+		Run(func(args mock.Arguments) {
+			order := args.Get(1).(*domain.Order)
+			order.ID = 1
+			order.Status = domain.StatusPending
+			order.Items[0].UnitPrice = money.New(5000, "IDR")
+			order.Items[1].UnitPrice = money.New(12000, "IDR")
+			_ = order.ComputeTotal()
+		}).Return(nil).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.POST, "/api/v1/orders", strings.NewReader(`{"user_id":1,"items":[{"product_id":3,"qty":2},{"product_id":4,"qty":1}]}`))
+	assert.NoError(t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/orders")
+
+	handler := controller.OrderController{OrderService: mockOrderService}
+	err = handler.Store(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"PENDING"`)
+	assert.Contains(t, rec.Body.String(), `"subtotal":{"amount":10000,"currency":"IDR"}`)
+	assert.Contains(t, rec.Body.String(), `"total":{"amount":22000,"currency":"IDR"}`)
+	mockOrderService.AssertExpectations(t)
+}
+
+func TestOrderController_Store_Refused(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+	}{
+		{"user not found", domain.ErrUserNotFound, http.StatusUnprocessableEntity, `{"err":"user not found","code":"user_not_found"}`},
+		{"product not found", domain.ErrProductNotFound, http.StatusUnprocessableEntity, `{"err":"product not found","code":"product_not_found"}`},
+		{"variant required", domain.ErrVariantRequired, http.StatusUnprocessableEntity, `{"err":"product has variants, order one of them","code":"variant_required"}`},
+		{"duplicate item", domain.ErrDuplicateItem, http.StatusUnprocessableEntity, `{"err":"product ordered more than once"}`},
+		{"price changed", &domain.PriceChangedError{ProductID: 3, Expected: money.New(4500, "IDR"), Price: money.New(5000, "IDR")}, http.StatusConflict, `{"err":"price of product 3 is IDR 50.00, not IDR 45.00","code":"price_changed","product_id":3,"price":{"amount":5000,"currency":"IDR"}}`},
+		{"mixed currencies", fmt.Errorf("%w: IDR and USD", money.ErrCurrencyMismatch), http.StatusUnprocessableEntity, `{"err":"currencies do not match: IDR and USD","code":"currency_mismatch"}`},
+		{"user service down", fmt.Errorf("%w: connection refused", domain.ErrUserUnavailable), http.StatusServiceUnavailable, `{"err":"user service unavailable: connection refused"}`},
+		{"error", errors.New("unexpected error"), http.StatusInternalServerError, `{"err":"unexpected error"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderService := new(mocks.OrderService)
+			mockOrderService.On("Store", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/orders", strings.NewReader(`{"user_id":9,"items":[{"product_id":3,"qty":2}]}`))
+			assert.NoError(t, err)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/orders")
+
+			handler := controller.OrderController{OrderService: mockOrderService}
+			err = handler.Store(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.JSONEq(t, tt.body, rec.Body.String())
+			mockOrderService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderController_Update(t *testing.T) {
+	num := 1
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"success", nil, http.StatusNoContent},
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+		{"confirmed", domain.ErrOrderLocked, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderService := new(mocks.OrderService)
+			mockOrderService.On("Update", mock.Anything, mock.AnythingOfType("*domain.Order"), uint32(num)).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.PUT, "/api/v1/orders/"+strconv.Itoa(num), strings.NewReader(`{"user_id":1,"items":[{"product_id":3,"qty":4}]}`))
+			assert.NoError(t, err)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/orders/:id")
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.Itoa(num))
+
+			handler := controller.OrderController{OrderService: mockOrderService}
+			err = handler.Update(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, rec.Code)
+			mockOrderService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderController_Delete(t *testing.T) {
+	num := 1
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"success", nil, http.StatusNoContent},
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+		{"not final", domain.ErrOrderLocked, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderService := new(mocks.OrderService)
+			mockOrderService.On("Delete", mock.Anything, uint32(num)).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.DELETE, "/api/v1/orders/"+strconv.Itoa(num), strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/orders/:id")
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.Itoa(num))
+
+			handler := controller.OrderController{OrderService: mockOrderService}
+			err = handler.Delete(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, rec.Code)
+			mockOrderService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderController_Cancel(t *testing.T) {
+	num := 1
+
+	tests := []struct {
+		name   string
+		order  domain.Order
+		err    error
+		status int
+	}{
+		{"success", domain.Order{ID: 1, Status: domain.StatusCancelled}, nil, http.StatusOK},
+		{"not found", domain.Order{}, domain.ErrNotFound, http.StatusNotFound},
+		{"illegal transition", domain.Order{ID: 1, Status: domain.StatusShipped}, domain.ErrInvalidTransition, http.StatusConflict},
+		{"error", domain.Order{}, errors.New("unexpected error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderService := new(mocks.OrderService)
+			mockOrderService.On("UpdateStatus", mock.Anything, uint32(num), domain.StatusCancelled).Return(tt.order, tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/orders/"+strconv.Itoa(num)+"/cancel", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/orders/:id/cancel")
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.Itoa(num))
+
+			handler := controller.OrderController{OrderService: mockOrderService}
+			err = handler.Cancel(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, rec.Code)
+			mockOrderService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderController_Cancel_ProductUnavailable(t *testing.T) {
+	num := 1
+	mockOrderService := new(mocks.OrderService)
+	openErr := &breaker.OpenError{Name: "product", RetryAfter: 11500 * time.Millisecond}
+	mockOrderService.On("UpdateStatus", mock.Anything, uint32(num), domain.StatusCancelled).Return(domain.Order{}, openErr).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.POST, "/api/v1/orders/"+strconv.Itoa(num)+"/cancel", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/orders/:id/cancel")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(num))
+
+	handler := controller.OrderController{OrderService: mockOrderService}
+	err = handler.Cancel(c)
+	require.NoError(t, err)
+
+	// The product service can not give the stock back, the client is told when to retry
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "12", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "circuit breaker product is open")
+	mockOrderService.AssertExpectations(t)
+}
+
+func TestOrderController_Store_ProductUnavailable(t *testing.T) {
+	// The order is priced with the product service, which only answers 500
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	productClient := productclient.NewProductClient(productclient.Config{
+		BaseURL: down.URL + "/api/v1/products",
+		Breaker: breaker.New("product", breaker.Config{Threshold: 1, Cooldown: time.Minute}),
+	})
+	mockUserClient := new(mocks.UserClient)
+	mockUserClient.On("GetUser", mock.Anything, uint32(9)).Return(domain.User{ID: 9}, nil)
+	orderService := service.NewOrderService(new(mocks.OrderRepository), new(mocks.SagaCoordinator), mockUserClient, productClient, time.Second)
+	handler := controller.OrderController{OrderService: orderService}
+
+	store := func() *httptest.ResponseRecorder {
+		e := echo.New()
+		req, err := http.NewRequest(echo.POST, "/api/v1/orders", strings.NewReader(`{"user_id":9,"items":[{"product_id":3,"qty":2}]}`))
+		require.NoError(t, err)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/orders")
+		require.NoError(t, handler.Store(c))
+		return rec
+	}
+
+	// The failed call opens the breaker, the next order does not reach the product service
+	rec := store()
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec = store()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "circuit breaker product is open")
+}
+
+func TestOrderController_Pay(t *testing.T) {
+	num := 1
+
+	tests := []struct {
+		name   string
+		order  domain.Order
+		err    error
+		status int
+	}{
+		{"success", domain.Order{ID: 1, Status: domain.StatusPaid}, nil, http.StatusOK},
+		{"reservation expired", domain.Order{ID: 1, Status: domain.StatusFailed}, domain.ErrReservationExpired, http.StatusConflict},
+		{"insufficient stock", domain.Order{ID: 1, Status: domain.StatusConfirmed}, domain.ErrInsufficientStock, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderService := new(mocks.OrderService)
+			mockOrderService.On("UpdateStatus", mock.Anything, uint32(num), domain.StatusPaid).Return(tt.order, tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/orders/"+strconv.Itoa(num)+"/pay", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/orders/:id/pay")
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.Itoa(num))
+
+			handler := controller.OrderController{OrderService: mockOrderService}
+			err = handler.Pay(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"status":"`+string(tt.order.Status)+`"`)
+			mockOrderService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderController_Ship(t *testing.T) {
+	num := 1
+	mockOrderService := new(mocks.OrderService)
+	mockOrderService.On("UpdateStatus", mock.Anything, uint32(num), domain.StatusShipped).Return(domain.Order{ID: 1, Status: domain.StatusShipped}, nil).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.POST, "/api/v1/orders/"+strconv.Itoa(num)+"/ship", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/orders/:id/ship")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(num))
+
+	handler := controller.OrderController{OrderService: mockOrderService}
+	err = handler.Ship(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"SHIPPED"`)
+	mockOrderService.AssertExpectations(t)
+}
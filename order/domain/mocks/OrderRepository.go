@@ -0,0 +1,95 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"order/domain"
+	"platform/pagination"
+)
+
+type OrderRepository struct {
+	mock.Mock
+}
+
+func (_m *OrderRepository) Fetch(ctx context.Context, filter domain.OrderFilter, q pagination.Query) ([]domain.Order, string, error) {
+	ret := _m.Called(ctx, filter, q)
+
+	var r0 []domain.Order
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Order)
+	}
+
+	return r0, ret.String(1), ret.Error(2)
+}
+
+func (_m *OrderRepository) GetByID(ctx context.Context, id uint32) (domain.Order, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Order
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Order); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *OrderRepository) Store(_a0 context.Context, _a1 *domain.Order) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Order) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderRepository) Update(ctx context.Context, o *domain.Order, id uint32) error {
+	ret := _m.Called(ctx, o, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Order, uint32) error); ok {
+		r0 = rf(ctx, o, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderRepository) Delete(ctx context.Context, id uint32) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderRepository) UpdateStatus(ctx context.Context, id uint32, from domain.OrderStatus, to domain.OrderStatus) error {
+	ret := _m.Called(ctx, id, from, to)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32, domain.OrderStatus, domain.OrderStatus) error); ok {
+		r0 = rf(ctx, id, from, to)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
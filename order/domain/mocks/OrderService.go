@@ -0,0 +1,115 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"order/domain"
+	"platform/pagination"
+)
+
+type OrderService struct {
+	mock.Mock
+}
+
+func (_m *OrderService) Fetch(ctx context.Context, filter domain.OrderFilter, q pagination.Query) ([]domain.Order, string, error) {
+	ret := _m.Called(ctx, filter, q)
+
+	var r0 []domain.Order
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Order)
+	}
+
+	return r0, ret.String(1), ret.Error(2)
+}
+
+func (_m *OrderService) GetByID(ctx context.Context, id uint32) (domain.Order, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Order
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Order); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *OrderService) Store(_a0 context.Context, _a1 *domain.Order) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Order) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderService) Update(_a0 context.Context, _a1 *domain.Order, _a2 uint32) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Order, uint32) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderService) Delete(_a0 context.Context, _a1 uint32) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderService) Resolve(_a0 context.Context, _a1 *domain.OrderReply) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.OrderReply) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *OrderService) UpdateStatus(ctx context.Context, id uint32, status domain.OrderStatus) (domain.Order, error) {
+	ret := _m.Called(ctx, id, status)
+
+	var r0 domain.Order
+	if rf, ok := ret.Get(0).(func(context.Context, uint32, domain.OrderStatus) domain.Order); ok {
+		r0 = rf(ctx, id, status)
+	} else {
+		r0 = ret.Get(0).(domain.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32, domain.OrderStatus) error); ok {
+		r1 = rf(ctx, id, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
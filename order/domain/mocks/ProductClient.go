@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"order/domain"
+)
+
+type ProductClient struct {
+	mock.Mock
+}
+
+func (_m *ProductClient) GetProduct(ctx context.Context, id uint32) (domain.Product, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Product
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Product); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Product)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *ProductClient) CommitOrder(ctx context.Context, orderID uint32) error {
+	ret := _m.Called(ctx, orderID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *ProductClient) ReleaseOrder(ctx context.Context, orderID uint32) error {
+	ret := _m.Called(ctx, orderID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,89 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"order/domain"
+	"time"
+)
+
+type SagaCoordinator struct {
+	mock.Mock
+}
+
+func (_m *SagaCoordinator) Start(_a0 context.Context, _a1 *domain.Order) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Order) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaCoordinator) Resolve(_a0 context.Context, _a1 *domain.OrderReply) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.OrderReply) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaCoordinator) Cancel(_a0 context.Context, _a1 uint32) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaCoordinator) Pay(_a0 context.Context, _a1 uint32) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaCoordinator) Resume(_a0 context.Context) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaCoordinator) ExpireRequests(ctx context.Context, timeout time.Duration) (int, error) {
+	ret := _m.Called(ctx, timeout)
+
+	return ret.Int(0), ret.Error(1)
+}
+
+func (_m *SagaCoordinator) RetryStalled(ctx context.Context, age time.Duration) (int, error) {
+	ret := _m.Called(ctx, age)
+
+	return ret.Int(0), ret.Error(1)
+}
@@ -0,0 +1,119 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"order/domain"
+	"time"
+)
+
+type SagaRepository struct {
+	mock.Mock
+}
+
+func (_m *SagaRepository) FetchInFlight(ctx context.Context) ([]domain.Saga, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []domain.Saga
+	if rf, ok := ret.Get(0).(func(context.Context) []domain.Saga); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Saga)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *SagaRepository) GetByOrderID(ctx context.Context, orderID uint32) (domain.Saga, error) {
+	ret := _m.Called(ctx, orderID)
+
+	var r0 domain.Saga
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Saga); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		r0 = ret.Get(0).(domain.Saga)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *SagaRepository) GetItems(ctx context.Context, sagaID uint32) ([]domain.StockItem, error) {
+	ret := _m.Called(ctx, sagaID)
+
+	var r0 []domain.StockItem
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.StockItem)
+	}
+
+	return r0, ret.Error(1)
+}
+
+func (_m *SagaRepository) FetchRequested(ctx context.Context, before time.Time) ([]domain.Saga, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 []domain.Saga
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Saga)
+	}
+
+	return r0, ret.Error(1)
+}
+
+func (_m *SagaRepository) FetchStalled(ctx context.Context, before time.Time) ([]domain.Saga, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 []domain.Saga
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Saga)
+	}
+
+	return r0, ret.Error(1)
+}
+
+func (_m *SagaRepository) Store(_a0 context.Context, _a1 *domain.Order, _a2 *domain.Saga) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Order, *domain.Saga) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaRepository) UpdateState(ctx context.Context, id uint32, state domain.SagaState) error {
+	ret := _m.Called(ctx, id, state)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32, domain.SagaState) error); ok {
+		r0 = rf(ctx, id, state)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *SagaRepository) UpdateStateFrom(ctx context.Context, id uint32, from domain.SagaState, state domain.SagaState) error {
+	ret := _m.Called(ctx, id, from, state)
+
+	return ret.Error(0)
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"order/domain"
+)
+
+type UserClient struct {
+	mock.Mock
+}
+
+func (_m *UserClient) GetUser(ctx context.Context, id uint32) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -1,25 +1,212 @@
 package domain
 
 import (
+	"errors"
+	"fmt"
 	"golang.org/x/net/context"
+	"platform/money"
+	"platform/pagination"
 	"time"
 )
 
+// Queues used to exchange order events with the product service
+const (
+	OrderQueue      = "OrderQueue"
+	OrderReplyQueue = "OrderReplyQueue"
+)
+
+type OrderStatus string
+
+const (
+	StatusPending   OrderStatus = "PENDING"
+	StatusConfirmed OrderStatus = "CONFIRMED"
+	StatusPaid      OrderStatus = "PAID"
+	StatusShipped   OrderStatus = "SHIPPED"
+	StatusDelivered OrderStatus = "DELIVERED"
+	StatusCancelled OrderStatus = "CANCELLED"
+	StatusFailed    OrderStatus = "FAILED"
+)
+
+var (
+	ErrNotFound          = errors.New("order not found")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrNoItems           = errors.New("order has no items")
+	ErrInvalidQty        = errors.New("qty must be positive")
+	ErrDuplicateItem     = errors.New("product ordered more than once")
+	ErrPriceChanged      = errors.New("price changed")
+	ErrCurrencyRequired  = errors.New("sorting by total needs a currency")
+	ErrOrderLocked       = errors.New("order can no longer be changed in its status")
+)
+
+// PriceChangedError is returned for an item ordered at an expected price that is no
+// longer the price of its product
+type PriceChangedError struct {
+	ProductID uint32
+	Expected  money.Money
+	Price     money.Money
+}
+
+func (e *PriceChangedError) Error() string {
+	return fmt.Sprintf("price of product %d is %s, not %s", e.ProductID, e.Price, e.Expected)
+}
+
+func (e *PriceChangedError) Is(target error) bool {
+	return target == ErrPriceChanged
+}
+
+// transitions holds the statuses an order may move to from each status,
+// delivered, cancelled and failed orders are final
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusConfirmed, StatusFailed, StatusCancelled},
+	StatusConfirmed: {StatusPaid, StatusFailed, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+}
+
+// CanTransitionTo reports whether an order in status s may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Editable reports whether an order in status s may still have its lines changed, only a
+// pending one can as long as its saga has not requested the stock of its lines
+func (s OrderStatus) Editable() bool {
+	return s == StatusPending
+}
+
+// Final reports whether an order in status s has nothing left to happen to it, no stock
+// is held or taken for it any more so it can be deleted
+func (s OrderStatus) Final() bool {
+	return len(transitions[s]) == 0
+}
+
 type Order struct {
-	ID        uint32    `json:"id"`
-	ProductID uint32    `json:"product_id"`
-	UserID    uint32    `json:"user_id"`
-	Qty       int       `json:"qty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint32      `json:"id"`
+	UserID    uint32      `json:"user_id"`
+	Items     []OrderItem `json:"items"`
+	Total     money.Money `json:"total"`
+	Status    OrderStatus `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderItem is a line of an order, UnitPrice is the product price when it was ordered.
+// ExpectedPrice is the price the client saw, when given the item is refused if the
+// product price is no longer the same.
+type OrderItem struct {
+	ID            uint32       `json:"id"`
+	OrderID       uint32       `json:"order_id"`
+	ProductID     uint32       `json:"product_id"`
+	Qty           int          `json:"qty"`
+	UnitPrice     money.Money  `json:"unit_price"`
+	Subtotal      money.Money  `json:"subtotal"`
+	ExpectedPrice *money.Money `json:"expected_price,omitempty"`
+}
+
+// Validate will check the order has items, each for a positive qty of a different product
+func (o *Order) Validate() error {
+	if len(o.Items) == 0 {
+		return ErrNoItems
+	}
+
+	products := make(map[uint32]bool, len(o.Items))
+	for _, item := range o.Items {
+		if item.Qty <= 0 {
+			return ErrInvalidQty
+		}
+		if products[item.ProductID] {
+			return ErrDuplicateItem
+		}
+		products[item.ProductID] = true
+	}
+	return nil
+}
+
+// ComputeTotal will set the subtotal of every item from its unit price and qty, and the
+// order total from the subtotals. It fails with money.ErrCurrencyMismatch when the items
+// are not priced in the same currency, and with money.ErrOverflow.
+func (o *Order) ComputeTotal() (err error) {
+	subtotals := make([]money.Money, len(o.Items))
+	for i := range o.Items {
+		item := &o.Items[i]
+		item.Subtotal, err = item.UnitPrice.Mul(int64(item.Qty))
+		if err != nil {
+			return
+		}
+		subtotals[i] = item.Subtotal
+	}
+
+	o.Total, err = money.Sum(subtotals...)
+	return
+}
+
+// StockItems will return the qty of every product of the order
+func (o *Order) StockItems() []StockItem {
+	items := make([]StockItem, len(o.Items))
+	for i, item := range o.Items {
+		items[i] = StockItem{ProductID: item.ProductID, Qty: item.Qty}
+	}
+	return items
+}
+
+// StockItem is a qty of a product reserved or released for an order
+type StockItem struct {
+	ProductID uint32 `json:"product_id"`
+	Qty       int    `json:"qty"`
+}
+
+// OrderCreated is published to OrderQueue once a pending order has been stored, the
+// product service reserves the stock of all the items or of none
+type OrderCreated struct {
+	OrderID uint32      `json:"order_id"`
+	Items   []StockItem `json:"items"`
+}
+
+// OrderReply is published by the product service to OrderReplyQueue once the stock has been checked
+type OrderReply struct {
+	OrderID  uint32 `json:"order_id"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// The reasons of a reply rejecting an order, codes set by the product service
+const (
+	ReasonInsufficientStock = "insufficient_stock"
+	ReasonProductNotFound   = "product_not_found"
+	ReasonVariantRequired   = "variant_required"
+	ReasonInvalidItems      = "invalid_items"
+)
+
+// OrderSorts are the fields a list of orders can be sorted by, besides id
+var OrderSorts = []string{"total", "created_at"}
+
+// OrderFilter narrows a list of orders, a field left empty does not filter
+type OrderFilter struct {
+	UserID   uint32
+	Status   OrderStatus
+	Currency string
 }
 
 type OrderRepository interface {
-	Fetch(ctx context.Context) (orders []Order, err error)
+	Fetch(ctx context.Context, filter OrderFilter, q pagination.Query) (orders []Order, next string, err error)
+	GetByID(ctx context.Context, id uint32) (order Order, err error)
 	Store(ctx context.Context, order *Order) error
+	Update(ctx context.Context, order *Order, id uint32) error
+	Delete(ctx context.Context, id uint32) error
+	UpdateStatus(ctx context.Context, id uint32, from OrderStatus, to OrderStatus) error
 }
 
 type OrderService interface {
-	Fetch(ctx context.Context) ([]Order, error)
+	Fetch(ctx context.Context, filter OrderFilter, q pagination.Query) ([]Order, string, error)
+	GetByID(ctx context.Context, id uint32) (Order, error)
 	Store(context.Context, *Order) error
+	Update(ctx context.Context, order *Order, id uint32) error
+	Delete(ctx context.Context, id uint32) error
+	Resolve(ctx context.Context, reply *OrderReply) error
+	UpdateStatus(ctx context.Context, id uint32, status OrderStatus) (Order, error)
 }
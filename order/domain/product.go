@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"platform/money"
+)
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrVariantRequired   = errors.New("product has variants, order one of them")
+	// ErrReservationExpired is returned when an order is paid after the stock held for it
+	// was let go
+	ErrReservationExpired = errors.New("the stock held for the order has expired")
+)
+
+// Product is a product as the product service returns it. A product with Variants is
+// not sold itself, its variants are products of their own with their price and stock.
+type Product struct {
+	ID       uint32      `json:"id"`
+	Name     string      `json:"name"`
+	Price    money.Money `json:"price"`
+	Stock    int         `json:"stock"`
+	Variants []Product   `json:"variants,omitempty"`
+}
+
+// ProductClient represent the calls the order service makes to the product service, the
+// stock of an order is held by the product service when it accepts the order, taken by
+// CommitOrder once it is paid and given back by ReleaseOrder when it is not
+type ProductClient interface {
+	GetProduct(ctx context.Context, id uint32) (Product, error)
+	CommitOrder(ctx context.Context, orderID uint32) error
+	ReleaseOrder(ctx context.Context, orderID uint32) error
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"time"
+)
+
+// ErrSagaStateChanged is returned when a saga is no longer in the state a step expected
+var ErrSagaStateChanged = errors.New("saga state has changed")
+
+type SagaState string
+
+// An order placement saga goes STARTED → REQUESTED → RESERVED → COMPLETED. A rejected
+// reservation ends ABORTED, and reserved stock that can not be kept is given back
+// to the product service through COMPENSATING → COMPENSATED.
+const (
+	SagaStarted      SagaState = "STARTED"
+	SagaRequested    SagaState = "REQUESTED"
+	SagaReserved     SagaState = "RESERVED"
+	SagaCompleted    SagaState = "COMPLETED"
+	SagaAborted      SagaState = "ABORTED"
+	SagaCompensating SagaState = "COMPENSATING"
+	SagaCompensated  SagaState = "COMPENSATED"
+)
+
+// Saga is the placement of an order. Items are the products and qty it reserves, kept
+// as they were when the order was placed, so a later change of the order does not change
+// what the saga asks for. They are only loaded when the reservation is requested again.
+type Saga struct {
+	ID        uint32      `json:"id"`
+	OrderID   uint32      `json:"order_id"`
+	State     SagaState   `json:"state"`
+	Items     []StockItem `json:"items,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type SagaRepository interface {
+	FetchInFlight(ctx context.Context) (sagas []Saga, err error)
+	FetchRequested(ctx context.Context, before time.Time) (sagas []Saga, err error)
+	FetchStalled(ctx context.Context, before time.Time) (sagas []Saga, err error)
+	GetByOrderID(ctx context.Context, orderID uint32) (saga Saga, err error)
+	GetItems(ctx context.Context, sagaID uint32) (items []StockItem, err error)
+	Store(ctx context.Context, order *Order, saga *Saga) error
+	UpdateState(ctx context.Context, id uint32, state SagaState) error
+	UpdateStateFrom(ctx context.Context, id uint32, from, state SagaState) error
+}
+
+// SagaCoordinator drives an order from placement to a confirmed stock reservation,
+// undoing the reservation when a later step fails
+type SagaCoordinator interface {
+	Start(ctx context.Context, order *Order) error
+	Resolve(ctx context.Context, reply *OrderReply) error
+	Cancel(ctx context.Context, orderID uint32) error
+	Pay(ctx context.Context, orderID uint32) error
+	Resume(ctx context.Context) error
+	ExpireRequests(ctx context.Context, timeout time.Duration) (int, error)
+	RetryStalled(ctx context.Context, age time.Duration) (int, error)
+}
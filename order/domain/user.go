@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrUserUnavailable = errors.New("user service unavailable")
+)
+
+// User is a user as the user service returns it
+type User struct {
+	ID    uint32 `json:"id"`
+	Email string `json:"email"`
+}
+
+// UserClient represent the calls the order service makes to the user service
+type UserClient interface {
+	GetUser(ctx context.Context, id uint32) (User, error)
+}
@@ -4,9 +4,12 @@ go 1.16
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
-	github.com/go-sql-driver/mysql v1.6.0 // indirect
 	github.com/labstack/echo/v4 v4.2.2
+	github.com/prometheus/client_golang v1.11.1
 	github.com/spf13/viper v1.7.1
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
+	platform v0.0.0
 )
+
+replace platform => ../platform
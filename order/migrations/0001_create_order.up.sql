@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `order` (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	product_id INT UNSIGNED NOT NULL,
+	user_id INT UNSIGNED NOT NULL,
+	qty INT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS order_user ON `order`;
+ALTER TABLE `order` DROP COLUMN IF EXISTS status;
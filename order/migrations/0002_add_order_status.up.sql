@@ -0,0 +1,6 @@
+ALTER TABLE `order` ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'PENDING';
+
+-- Orders placed before the status existed took their stock right away
+UPDATE `order` SET status='CONFIRMED' WHERE status='PENDING';
+
+CREATE INDEX IF NOT EXISTS order_user ON `order` (user_id);
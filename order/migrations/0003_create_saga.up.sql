@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS saga (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	order_id INT UNSIGNED NOT NULL,
+	product_id INT UNSIGNED NOT NULL,
+	qty INT NOT NULL,
+	state VARCHAR(16) NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	UNIQUE INDEX saga_order (order_id),
+	INDEX saga_state (state)
+);
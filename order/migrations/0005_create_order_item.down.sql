@@ -0,0 +1,11 @@
+ALTER TABLE `order` ADD COLUMN IF NOT EXISTS product_id INT UNSIGNED NOT NULL DEFAULT 0 AFTER id, ADD COLUMN IF NOT EXISTS qty INT NOT NULL DEFAULT 0 AFTER user_id;
+ALTER TABLE saga ADD COLUMN IF NOT EXISTS product_id INT UNSIGNED NOT NULL DEFAULT 0 AFTER order_id, ADD COLUMN IF NOT EXISTS qty INT NOT NULL DEFAULT 0 AFTER product_id;
+
+-- An order keeps its first item only
+UPDATE `order` o
+	JOIN (SELECT order_id, MIN(id) AS id FROM order_item GROUP BY order_id) f ON f.order_id=o.id
+	JOIN order_item i ON i.id=f.id
+	SET o.product_id=i.product_id, o.qty=i.qty;
+UPDATE saga s JOIN `order` o ON o.id=s.order_id SET s.product_id=o.product_id, s.qty=o.qty;
+
+DROP TABLE IF EXISTS order_item;
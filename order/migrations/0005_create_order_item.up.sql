@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS order_item (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	order_id INT UNSIGNED NOT NULL,
+	product_id INT UNSIGNED NOT NULL,
+	qty INT NOT NULL,
+	unit_price INT NOT NULL DEFAULT 0,
+	UNIQUE INDEX order_item_product (order_id, product_id)
+);
+
+-- Orders placed before line items hold a single item, their price was never recorded
+INSERT INTO order_item (order_id, product_id, qty) SELECT id, product_id, qty FROM `order`;
+
+ALTER TABLE `order` DROP COLUMN IF EXISTS product_id, DROP COLUMN IF EXISTS qty;
+ALTER TABLE saga DROP COLUMN IF EXISTS product_id, DROP COLUMN IF EXISTS qty;
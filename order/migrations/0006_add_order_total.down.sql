@@ -0,0 +1,2 @@
+ALTER TABLE `order` DROP COLUMN IF EXISTS total;
+ALTER TABLE order_item DROP COLUMN IF EXISTS subtotal;
@@ -0,0 +1,5 @@
+ALTER TABLE order_item ADD COLUMN IF NOT EXISTS subtotal INT NOT NULL DEFAULT 0;
+ALTER TABLE `order` ADD COLUMN IF NOT EXISTS total INT NOT NULL DEFAULT 0 AFTER user_id;
+
+UPDATE order_item SET subtotal=unit_price*qty;
+UPDATE `order` o SET total=(SELECT COALESCE(SUM(i.subtotal), 0) FROM order_item i WHERE i.order_id=o.id);
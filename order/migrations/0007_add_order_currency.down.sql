@@ -0,0 +1,8 @@
+UPDATE `order` SET total=total DIV 100;
+UPDATE order_item SET unit_price=unit_price DIV 100, subtotal=subtotal DIV 100;
+
+ALTER TABLE `order` DROP COLUMN IF EXISTS currency;
+ALTER TABLE `order` MODIFY COLUMN total INT NOT NULL DEFAULT 0;
+ALTER TABLE order_item DROP COLUMN IF EXISTS currency;
+ALTER TABLE order_item MODIFY COLUMN subtotal INT NOT NULL DEFAULT 0;
+ALTER TABLE order_item MODIFY COLUMN unit_price INT NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- Prices were whole rupiah, they are now kept in the minor unit of their currency
+ALTER TABLE order_item MODIFY COLUMN unit_price BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_item MODIFY COLUMN subtotal BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_item ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER subtotal;
+ALTER TABLE `order` MODIFY COLUMN total BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE `order` ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER total;
+
+UPDATE order_item SET unit_price=unit_price*100, subtotal=subtotal*100;
+UPDATE `order` SET total=total*100;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS order_status ON `order`;
+DROP INDEX IF EXISTS order_created ON `order`;
+DROP INDEX IF EXISTS order_total ON `order`;
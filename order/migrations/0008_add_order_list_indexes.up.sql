@@ -0,0 +1,4 @@
+-- Lists of orders are paged by sort column then id
+CREATE INDEX IF NOT EXISTS order_total ON `order` (total, id);
+CREATE INDEX IF NOT EXISTS order_created ON `order` (created_at, id);
+CREATE INDEX IF NOT EXISTS order_status ON `order` (status, id);
@@ -0,0 +1,11 @@
+-- The items a saga reserves, kept as they were when the order was placed
+CREATE TABLE IF NOT EXISTS saga_item (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	saga_id INT UNSIGNED NOT NULL,
+	product_id INT UNSIGNED NOT NULL,
+	qty INT NOT NULL,
+	UNIQUE INDEX saga_item_product (saga_id, product_id)
+);
+
+-- Sagas started before reserve the items their order holds now
+INSERT INTO saga_item (saga_id, product_id, qty) SELECT saga.id, order_item.product_id, order_item.qty FROM saga JOIN order_item ON order_item.order_id = saga.order_id;
@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// FS holds the versioned SQL migrations of the service, applied in order of their number
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,295 @@
+package productclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"net/http"
+	"order/domain"
+	"platform/breaker"
+	"platform/idempotency"
+	"platform/metrics"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+)
+
+// headerIdempotencyKey makes the product service apply a retried request once
+const headerIdempotencyKey = "Idempotency-Key"
+
+// The codes of the product service errors the client tells apart, sent with the message
+const (
+	codeReservationExpired = "reservation_expired"
+	codeInsufficientStock  = "insufficient_stock"
+)
+
+const defaultTimeout = 5 * time.Second
+
+var duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "product_request_duration_seconds",
+	Help:    "Latency of the calls to the product service.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation", "status"})
+
+func init() {
+	metrics.Registry.MustRegister(duration)
+}
+
+// StatusError is an answer of the product service that is none of the domain errors
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("product service: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+type Config struct {
+	// BaseURL is the root of the product API, like http://product:9090/api/v1/products
+	BaseURL string
+	// Timeout bounds every attempt of a call
+	Timeout time.Duration
+	// Retries is the number of attempts made after a failed one
+	Retries int
+	// Backoff is the wait before the first retry, it doubles for every next one
+	Backoff   time.Duration
+	Transport http.RoundTripper
+	// Breaker, when set, fails the calls fast while the product service is down
+	Breaker *breaker.Breaker
+}
+
+// NewConfig will read the product service settings from viper, the timeout and the
+// breaker cooldown are given in seconds and the backoff in milliseconds
+func NewConfig() Config {
+	return Config{
+		BaseURL: viper.GetString(`product.url`),
+		Timeout: time.Duration(viper.GetInt(`product.timeout`)) * time.Second,
+		Retries: viper.GetInt(`product.retries`),
+		Backoff: time.Duration(viper.GetInt(`product.backoff`)) * time.Millisecond,
+		Breaker: breaker.New(`product`, breaker.Config{
+			Threshold: viper.GetInt(`product.breaker.threshold`),
+			Cooldown:  time.Duration(viper.GetInt(`product.breaker.cooldown`)) * time.Second,
+		}),
+	}
+}
+
+type productClient struct {
+	config Config
+	client *http.Client
+}
+
+// NewProductClient will create a client for the product service API, an attempt
+// times out after 5 seconds unless the config says otherwise
+func NewProductClient(c Config) domain.ProductClient {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &productClient{
+		config: c,
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// GetProduct will fetch the product with id
+func (pc *productClient) GetProduct(ctx context.Context, id uint32) (product domain.Product, err error) {
+	res, err := pc.call(ctx, "get", http.MethodGet, fmt.Sprintf("/%d", id), "", nil)
+	if err != nil {
+		return
+	}
+
+	switch res.status {
+	case http.StatusOK:
+		err = json.Unmarshal(res.body, &product)
+	case http.StatusNotFound:
+		err = domain.ErrProductNotFound
+	default:
+		err = res.err()
+	}
+	return
+}
+
+// CommitOrder will take the stock held for an order out of the product stock, once the
+// order is paid. Holds that expired before are answered with ErrReservationExpired.
+func (pc *productClient) CommitOrder(ctx context.Context, orderID uint32) error {
+	res, err := pc.call(ctx, "commit", http.MethodPost, fmt.Sprintf("/orders/%d/commit", orderID), orderKey(orderID, "commit"), struct{}{})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.ok():
+		return nil
+	case res.code() == codeReservationExpired:
+		return domain.ErrReservationExpired
+	case res.code() == codeInsufficientStock:
+		return domain.ErrInsufficientStock
+	default:
+		return res.err()
+	}
+}
+
+// ReleaseOrder will give back the stock held or taken for an order, releasing an order
+// again does nothing
+func (pc *productClient) ReleaseOrder(ctx context.Context, orderID uint32) error {
+	res, err := pc.call(ctx, "release", http.MethodPost, fmt.Sprintf("/orders/%d/release", orderID), orderKey(orderID, "release"), struct{}{})
+	if err != nil {
+		return err
+	}
+
+	if !res.ok() {
+		return res.err()
+	}
+	return nil
+}
+
+// response is the answer to an attempt, read in full before its timeout expires
+type response struct {
+	status int
+	body   []byte
+}
+
+func (r response) ok() bool {
+	return r.status >= 200 && r.status <= 299
+}
+
+// message will return the error of the product service, sent as {"err": "..."}
+func (r response) message() string {
+	var body struct {
+		Err string `json:"err"`
+	}
+	if err := json.Unmarshal(r.body, &body); err == nil && body.Err != "" {
+		return body.Err
+	}
+	return string(bytes.TrimSpace(r.body))
+}
+
+// code will return the code the product service sent with its error, if any
+func (r response) code() string {
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(r.body, &body)
+	return body.Code
+}
+
+func (r response) err() error {
+	return &StatusError{StatusCode: r.status, Message: r.message()}
+}
+
+// call will send the request, and retry it with a growing backoff when the product
+// service can not be reached, is unavailable or is still handling the same request.
+// A request with a payload changes the stock, so it carries the Idempotency-Key given,
+// kept across the attempts, and the product service applies it once. Every attempt goes
+// through the breaker, an open one ends the call with a *breaker.OpenError.
+func (pc *productClient) call(ctx context.Context, operation, method, path, key string, payload interface{}) (res response, err error) {
+	var body []byte
+	header := http.Header{}
+	if payload != nil {
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return
+		}
+		header.Set("Content-Type", "application/json")
+		header.Set(headerIdempotencyKey, key)
+	}
+
+	backoff := pc.config.Backoff
+	for attempt := 0; ; attempt++ {
+		if pc.config.Breaker != nil {
+			if err = pc.config.Breaker.Allow(); err != nil {
+				return
+			}
+		}
+
+		res, err = pc.attempt(ctx, operation, method, pc.config.BaseURL+path, header, body)
+		pc.report(ctx, res, err)
+		if attempt >= pc.config.Retries || !retryable(res, err) || ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// report will tell the breaker how an attempt went, an attempt given up by the caller
+// says nothing about the product service
+func (pc *productClient) report(ctx context.Context, res response, err error) {
+	b := pc.config.Breaker
+	switch {
+	case b == nil:
+	case err != nil && ctx.Err() != nil:
+	case err != nil || res.status >= 500:
+		b.Failure()
+	default:
+		b.Success()
+	}
+}
+
+// retryable tells whether an attempt may succeed when sent again, a 409 only when the
+// product service is still handling the same request
+func retryable(res response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if res.status == http.StatusConflict {
+		return res.message() == idempotency.ErrKeyInProgress.Error()
+	}
+	return res.status >= 500 || res.status == http.StatusTooManyRequests
+}
+
+func (pc *productClient) attempt(c context.Context, operation, method, url string, header http.Header, body []byte) (res response, err error) {
+	ctx, cancel := context.WithTimeout(c, pc.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := pc.do(operation, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	res.status = resp.StatusCode
+	res.body, err = ioutil.ReadAll(resp.Body)
+	return
+}
+
+// do will send req and observe its latency, a request without response has the status "error"
+func (pc *productClient) do(operation string, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := pc.client.Do(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	duration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// orderKey will return the Idempotency-Key of a step of an order, the same for every run
+// of the step, so a step the saga runs again after it succeeded is not applied twice
+func orderKey(orderID uint32, step string) string {
+	return fmt.Sprintf("order-%d-%s", orderID, step)
+}
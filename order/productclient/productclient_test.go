@@ -0,0 +1,328 @@
+package productclient_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"order/domain"
+	"order/productclient"
+	"order/productclient/productclienttest"
+	"platform/breaker"
+	"platform/metrics"
+	"platform/money"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductClient_ReleaseOrder(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		var path, key string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, key = r.URL.Path, r.Header.Get("Idempotency-Key")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		pc := productclient.NewProductClient(productclient.Config{BaseURL: server.URL + "/api/v1/products"})
+		err := pc.ReleaseOrder(context.TODO(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, "/api/v1/products/orders/7/release", path)
+		assert.Equal(t, "order-7-release", key)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"err":"deadlock found"}`))
+		}))
+		defer server.Close()
+
+		pc := productclient.NewProductClient(productclient.Config{BaseURL: server.URL + "/api/v1/products"})
+		err := pc.ReleaseOrder(context.TODO(), 7)
+		var statusErr *productclient.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+		assert.Equal(t, "deadlock found", statusErr.Message)
+	})
+
+	t.Run("paid", func(t *testing.T) {
+		server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+		defer server.Close()
+		server.Hold(7, domain.StockItem{ProductID: 3, Qty: 2})
+
+		pc := productclient.NewProductClient(productclient.Config{BaseURL: server.BaseURL()})
+		require.NoError(t, pc.CommitOrder(context.TODO(), 7))
+		product, _ := server.Product(3)
+		assert.Equal(t, 3, product.Stock)
+
+		// The stock taken is given back once, however often the order is released
+		assert.NoError(t, pc.ReleaseOrder(context.TODO(), 7))
+		assert.NoError(t, pc.ReleaseOrder(context.TODO(), 7))
+		product, _ = server.Product(3)
+		assert.Equal(t, 5, product.Stock)
+	})
+}
+
+func TestProductClient_GetProduct(t *testing.T) {
+	server := productclienttest.NewServer(domain.Product{ID: 3, Name: "Pen", Price: money.New(5000, "IDR"), Stock: 10})
+	defer server.Close()
+	pc := productclient.NewProductClient(productclient.Config{BaseURL: server.BaseURL()})
+
+	t.Run("success", func(t *testing.T) {
+		product, err := pc.GetProduct(context.TODO(), 3)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.Product{ID: 3, Name: "Pen", Price: money.New(5000, "IDR"), Stock: 10}, product)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := pc.GetProduct(context.TODO(), 4)
+		assert.Equal(t, domain.ErrProductNotFound, err)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		server.Fail(1)
+		_, err := pc.GetProduct(context.TODO(), 3)
+
+		var statusErr *productclient.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	})
+}
+
+func TestProductClient_CommitOrder(t *testing.T) {
+	server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 2}, domain.Product{ID: 4, Stock: 5})
+	defer server.Close()
+	pc := productclient.NewProductClient(productclient.Config{BaseURL: server.BaseURL()})
+
+	server.Hold(7, domain.StockItem{ProductID: 3, Qty: 2}, domain.StockItem{ProductID: 4, Qty: 1})
+	assert.NoError(t, pc.CommitOrder(context.TODO(), 7))
+	product, _ := server.Product(3)
+	assert.Equal(t, 0, product.Stock)
+
+	// A retry of a paid order takes nothing more
+	assert.NoError(t, pc.CommitOrder(context.TODO(), 7))
+	product, _ = server.Product(4)
+	assert.Equal(t, 4, product.Stock)
+
+	server.Hold(8, domain.StockItem{ProductID: 4, Qty: 1})
+	server.Expire(8)
+	assert.Equal(t, domain.ErrReservationExpired, pc.CommitOrder(context.TODO(), 8))
+	product, _ = server.Product(4)
+	assert.Equal(t, 4, product.Stock)
+
+	var statusErr *productclient.StatusError
+	require.True(t, errors.As(pc.CommitOrder(context.TODO(), 9), &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	// The stock was taken meanwhile, by a correction for instance
+	server.Hold(10, domain.StockItem{ProductID: 4, Qty: 5})
+	assert.Equal(t, domain.ErrInsufficientStock, pc.CommitOrder(context.TODO(), 10))
+}
+
+func TestProductClient_CommitOrder_Code(t *testing.T) {
+	// The errors are told apart by their code, whatever their message says
+	for code, want := range map[string]error{
+		"reservation_expired": domain.ErrReservationExpired,
+		"insufficient_stock":  domain.ErrInsufficientStock,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"err":"reworded","code":"` + code + `"}`))
+		}))
+		pc := productclient.NewProductClient(productclient.Config{BaseURL: server.URL})
+
+		assert.Equal(t, want, pc.CommitOrder(context.TODO(), 7), code)
+		server.Close()
+	}
+}
+
+func TestProductClient_Retry(t *testing.T) {
+	config := productclient.Config{Retries: 2, Backoff: time.Millisecond}
+
+	t.Run("recovered", func(t *testing.T) {
+		server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+		defer server.Close()
+		config.BaseURL = server.BaseURL()
+		server.Hold(7, domain.StockItem{ProductID: 3, Qty: 2})
+
+		server.Fail(2)
+		err := productclient.NewProductClient(config).CommitOrder(context.TODO(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, server.Requests())
+		product, _ := server.Product(3)
+		assert.Equal(t, 3, product.Stock)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+		defer server.Close()
+		config.BaseURL = server.BaseURL()
+
+		server.Fail(3)
+		_, err := productclient.NewProductClient(config).GetProduct(context.TODO(), 3)
+		var statusErr *productclient.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+		assert.Equal(t, 3, server.Requests())
+	})
+
+	t.Run("not retried", func(t *testing.T) {
+		// A 409 about the order itself is not a request still in progress
+		server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+		defer server.Close()
+		config.BaseURL = server.BaseURL()
+		server.Hold(7, domain.StockItem{ProductID: 3, Qty: 2})
+		server.Expire(7)
+
+		err := productclient.NewProductClient(config).CommitOrder(context.TODO(), 7)
+		assert.Equal(t, domain.ErrReservationExpired, err)
+		assert.Equal(t, 1, server.Requests())
+	})
+
+	t.Run("same key", func(t *testing.T) {
+		// The release is applied, but the answer is lost on the way back
+		keys := make([]string, 0)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if len(keys) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+		config.BaseURL = server.URL + "/api/v1/products"
+
+		pc := productclient.NewProductClient(config)
+		err := pc.ReleaseOrder(context.TODO(), 7)
+		assert.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "order-7-release", keys[0])
+		assert.Equal(t, keys[0], keys[1])
+
+		// A compensation run again after its release went through sends the same key
+		err = pc.ReleaseOrder(context.TODO(), 7)
+		assert.NoError(t, err)
+		require.Len(t, keys, 3)
+		assert.Equal(t, keys[0], keys[2])
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+		defer server.Close()
+
+		server.Delay(time.Second)
+		pc := productclient.NewProductClient(productclient.Config{BaseURL: server.BaseURL(), Timeout: 10 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+		_, err := pc.GetProduct(context.TODO(), 3)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, 2, server.Requests())
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+		defer server.Close()
+		config.BaseURL = server.BaseURL()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := productclient.NewProductClient(config).GetProduct(ctx, 3)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 0, server.Requests())
+	})
+}
+
+func TestProductClient_Breaker(t *testing.T) {
+	server := productclienttest.NewServer(domain.Product{ID: 3, Stock: 5})
+	defer server.Close()
+
+	b := breaker.New("product-test", breaker.Config{Threshold: 2, Cooldown: 50 * time.Millisecond})
+	pc := productclient.NewProductClient(productclient.Config{BaseURL: server.BaseURL(), Breaker: b})
+
+	// Answers of the product service about the request do not count as failures
+	_, err := pc.GetProduct(context.TODO(), 4)
+	assert.Equal(t, domain.ErrProductNotFound, err)
+	_, err = pc.GetProduct(context.TODO(), 4)
+	assert.Equal(t, domain.ErrProductNotFound, err)
+	assert.Equal(t, breaker.StateClosed, b.State())
+
+	server.Fail(2)
+	_, err = pc.GetProduct(context.TODO(), 3)
+	assert.Error(t, err)
+	_, err = pc.GetProduct(context.TODO(), 3)
+	assert.Error(t, err)
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	// Open, the call fails fast without reaching the product service
+	requests := server.Requests()
+	_, err = pc.GetProduct(context.TODO(), 3)
+	var openErr *breaker.OpenError
+	require.True(t, errors.As(err, &openErr))
+	assert.Equal(t, requests, server.Requests())
+
+	// Half-open, the trial call succeeds and closes the breaker
+	time.Sleep(50 * time.Millisecond)
+	_, err = pc.GetProduct(context.TODO(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, breaker.StateClosed, b.State())
+
+	// A call given up by its caller is not held against the product service
+	server.Delay(time.Second)
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err = pc.GetProduct(ctx, 3)
+		cancel()
+		assert.Error(t, err)
+	}
+	assert.Equal(t, breaker.StateClosed, b.State())
+}
+
+// scrape will read the value of series from the exposition text, 0 when it is missing
+func scrape(t *testing.T, url, series string) float64 {
+	resp, err := http.Get(url + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), series+" "); value != scanner.Text() {
+			v, err := strconv.ParseFloat(value, 64)
+			require.NoError(t, err)
+			return v
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return 0
+}
+
+func TestProductClient_Metrics(t *testing.T) {
+	product := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer product.Close()
+
+	e := echo.New()
+	metrics.NewMetricsController(e)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	success := `product_request_duration_seconds_count{operation="release",status="204"}`
+	failure := `product_request_duration_seconds_count{operation="release",status="error"}`
+	successes, failures := scrape(t, server.URL, success), scrape(t, server.URL, failure)
+
+	pc := productclient.NewProductClient(productclient.Config{BaseURL: product.URL + "/api/v1/products"})
+	assert.NoError(t, pc.ReleaseOrder(context.TODO(), 7))
+	product.Close()
+	assert.Error(t, pc.ReleaseOrder(context.TODO(), 7))
+
+	assert.Equal(t, successes+1, scrape(t, server.URL, success))
+	assert.Equal(t, failures+1, scrape(t, server.URL, failure))
+}
@@ -0,0 +1,228 @@
+package productclienttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"order/domain"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// hold is the stock held for an order, active until it is committed, released or expired
+type hold struct {
+	items  []domain.StockItem
+	status string
+}
+
+// reply is a response kept for the Idempotency-Key of the request it answered
+type reply struct {
+	status int
+	body   interface{}
+}
+
+// Server is a fake product service keeping the products in memory. It answers the
+// calls of the product client like the product service does, including the replay of
+// a request retried with the same Idempotency-Key.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	products map[uint32]domain.Product
+	holds    map[uint32]*hold
+	replies  map[string]reply
+	failures int
+	delay    time.Duration
+	requests int
+}
+
+// NewServer will start a fake product service selling products
+func NewServer(products ...domain.Product) *Server {
+	s := &Server{
+		products: make(map[uint32]domain.Product),
+		holds:    make(map[uint32]*hold),
+		replies:  make(map[string]reply),
+	}
+	for _, p := range products {
+		s.products[p.ID] = p
+	}
+
+	e := echo.New()
+	e.Use(s.fault)
+	group := e.Group("/api/v1")
+	group.GET("/products/:id", s.get)
+	group.POST("/products/orders/:id/commit", s.idempotent(s.commit))
+	group.POST("/products/orders/:id/release", s.idempotent(s.release))
+
+	s.Server = httptest.NewServer(e)
+	return s
+}
+
+// BaseURL is the root of the product API to create the product client with
+func (s *Server) BaseURL() string {
+	return s.URL + "/api/v1/products"
+}
+
+// Product will return the product with id as the fake holds it
+func (s *Server) Product(id uint32) (domain.Product, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[id]
+	return p, ok
+}
+
+// Hold will hold the stock of items for an order, as the product service does when it
+// accepts the order
+func (s *Server) Hold(orderID uint32, items ...domain.StockItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holds[orderID] = &hold{items: items, status: "active"}
+}
+
+// Expire will let the stock held for an order go, as the product service does once the
+// holds are past their expiry
+func (s *Server) Expire(orderID uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.holds[orderID]; ok && h.status == "active" {
+		h.status = "expired"
+	}
+}
+
+// Fail will answer the next n requests with a 503
+func (s *Server) Fail(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// Delay will hold every next request for d before it is answered
+func (s *Server) Delay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// Requests will return the number of requests received
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) fault(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s.mu.Lock()
+		s.requests++
+		delay := s.delay
+		fail := s.failures > 0
+		if fail {
+			s.failures--
+		}
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-c.Request().Context().Done():
+				return nil
+			}
+		}
+		if fail {
+			return c.JSON(http.StatusServiceUnavailable, echo.Map{"err": "Service Unavailable"})
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) idempotent(next func(c echo.Context) (int, interface{})) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s.mu.Lock()
+		key := c.Request().Header.Get("Idempotency-Key")
+		r, ok := s.replies[key]
+		if !ok {
+			r.status, r.body = next(c)
+			if key != "" {
+				s.replies[key] = r
+			}
+		}
+		s.mu.Unlock()
+
+		if r.body == nil {
+			return c.NoContent(r.status)
+		}
+		return c.JSON(r.status, r.body)
+	}
+}
+
+func (s *Server) get(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"err": err.Error()})
+	}
+
+	p, ok := s.Product(uint32(id))
+	if !ok {
+		return c.JSON(http.StatusNotFound, echo.Map{"err": "Record Not Found"})
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// commit must be called with mu held, the stock of every item is taken or none
+func (s *Server) commit(c echo.Context) (int, interface{}) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest, echo.Map{"err": err.Error()}
+	}
+
+	h, ok := s.holds[uint32(id)]
+	switch {
+	case !ok:
+		return http.StatusNotFound, echo.Map{"err": "Record Not Found"}
+	case h.status == "committed":
+		return http.StatusNoContent, nil
+	case h.status == "expired":
+		return http.StatusConflict, echo.Map{"err": "reservation has expired", "code": "reservation_expired"}
+	case h.status != "active":
+		return http.StatusConflict, echo.Map{"err": "reservation is no longer active", "code": "reservation_closed"}
+	}
+
+	for _, item := range h.items {
+		if s.products[item.ProductID].Stock < item.Qty {
+			return http.StatusUnprocessableEntity, echo.Map{"err": "Insufficient stock", "code": "insufficient_stock"}
+		}
+	}
+	for _, item := range h.items {
+		p := s.products[item.ProductID]
+		p.Stock -= item.Qty
+		s.products[p.ID] = p
+	}
+	h.status = "committed"
+	return http.StatusNoContent, nil
+}
+
+// release must be called with mu held, the stock taken by a committed order is given back
+func (s *Server) release(c echo.Context) (int, interface{}) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest, echo.Map{"err": err.Error()}
+	}
+
+	h, ok := s.holds[uint32(id)]
+	if !ok || h.status != "active" && h.status != "committed" {
+		return http.StatusNoContent, nil
+	}
+
+	if h.status == "committed" {
+		for _, item := range h.items {
+			p := s.products[item.ProductID]
+			p.Stock += item.Qty
+			s.products[p.ID] = p
+		}
+	}
+	h.status = "released"
+	return http.StatusNoContent, nil
+}
@@ -5,14 +5,12 @@ import (
 	"golang.org/x/net/context"
 	"log"
 	"order/domain"
+	"platform/pagination"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var (
-	t  = time.Now()
-	ts = t.Format("2006-01-02 15:04:05")
-)
-
 type orderRepository struct {
 	Conn *sql.DB
 }
@@ -21,42 +19,302 @@ func NewOrderRepository(db *sql.DB) domain.OrderRepository  {
 	return &orderRepository{Conn: db}
 }
 
-func (or *orderRepository) Fetch(ctx context.Context) (orders []domain.Order, err error)  {
-	query := "SELECT id, product_id, user_id, qty, created_at, updated_at FROM `order`"
-	rows, err := or.Conn.QueryContext(ctx, query)
+// fetch will return the page of orders the query selects with q, and the cursor of the
+// next page when there is one. The items are only loaded for the orders of the page.
+func (or *orderRepository) fetch(ctx context.Context, q pagination.Query, query string, args ...interface{}) (orders []domain.Order, next string, err error) {
+	rows, err := or.Conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, "", err
 	}
-	defer func() {
-		errRow := rows.Close()
-		if errRow != nil {
-			log.Fatal(errRow)
-		}
-	}()
+	defer rows.Close()
 
 	orders = make([]domain.Order, 0)
 	for rows.Next() {
 		o := domain.Order{}
-		err = rows.Scan(&o.ID, &o.ProductID, &o.UserID, &o.Qty, &o.CreatedAt, &o.UpdatedAt)
+		err = rows.Scan(&o.ID, &o.UserID, &o.Total.Amount, &o.Total.Currency, &o.Status, &o.CreatedAt, &o.UpdatedAt)
 		if err != nil {
-			log.Fatal(err)
-			return nil, err
+			return nil, "", err
 		}
 		orders = append(orders, o)
 	}
+	err = rows.Err()
+	if err != nil {
+		return nil, "", err
+	}
 
+	if q.More(len(orders)) {
+		orders = orders[:q.Limit]
+		last := orders[q.Limit-1]
+		next = q.Cursor(sortValue(last, q.Sort), last.ID)
+	}
+
+	err = or.fetchItems(ctx, orders)
 	return
 }
 
+// sortValue will return the value of the sort field of order, as a cursor holds it
+func sortValue(order domain.Order, sort string) string {
+	switch sort {
+	case "total":
+		return strconv.FormatInt(order.Total.Amount, 10)
+	case "created_at":
+		return order.CreatedAt.Format(pagination.TimeLayout)
+	}
+	return ""
+}
+
+// fetchItems will load the items of the orders with a single query
+func (or *orderRepository) fetchItems(ctx context.Context, orders []domain.Order) (err error) {
+	if len(orders) == 0 {
+		return
+	}
+
+	index := make(map[uint32]int, len(orders))
+	args := make([]interface{}, len(orders))
+	for i, o := range orders {
+		index[o.ID] = i
+		args[i] = o.ID
+	}
+
+	query := "SELECT id, order_id, product_id, qty, unit_price, subtotal, currency FROM order_item WHERE order_id IN (?" +
+		strings.Repeat(", ?", len(orders)-1) + ") ORDER BY id"
+	rows, err := or.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for i := range orders {
+		orders[i].Items = make([]domain.OrderItem, 0)
+	}
+	for rows.Next() {
+		item := domain.OrderItem{}
+		err = rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Qty, &item.UnitPrice.Amount, &item.Subtotal.Amount, &item.UnitPrice.Currency)
+		if err != nil {
+			return
+		}
+		item.Subtotal.Currency = item.UnitPrice.Currency
+		o := &orders[index[item.OrderID]]
+		o.Items = append(o.Items, item)
+	}
+	return rows.Err()
+}
+
+// Fetch will return a page of the orders matching the filter, and the cursor of the next
+// page when there is one
+func (or *orderRepository) Fetch(ctx context.Context, filter domain.OrderFilter, q pagination.Query) (orders []domain.Order, next string, err error)  {
+	var conds []string
+	var args []interface{}
+	if filter.UserID != 0 {
+		conds = append(conds, "user_id=?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		conds = append(conds, "status=?")
+		args = append(args, filter.Status)
+	}
+	if filter.Currency != "" {
+		conds = append(conds, "currency=?")
+		args = append(args, filter.Currency)
+	}
+
+	keyset, keysetArgs, orderBy := q.Keyset()
+	query := "SELECT id, user_id, total, currency, status, created_at, updated_at FROM `order`" + pagination.Where(append(conds, keyset)...) + orderBy
+	return or.fetch(ctx, q, query, append(args, keysetArgs...)...)
+}
+
+func (or *orderRepository) GetByID(ctx context.Context, id uint32) (order domain.Order, err error) {
+	query := "SELECT id, user_id, total, currency, status, created_at, updated_at FROM `order` WHERE id=?"
+
+	stmt, err := or.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	row := stmt.QueryRowContext(ctx, id)
+	order = domain.Order{}
+
+	err = row.Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Total.Amount,
+		&order.Total.Currency,
+		&order.Status,
+		&order.CreatedAt,
+		&order.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	orders := []domain.Order{order}
+	err = or.fetchItems(ctx, orders)
+	return orders[0], err
+}
+
+// Store will save the order and its items within a single transaction
 func (or *orderRepository) Store(ctx context.Context, order *domain.Order) (err error)  {
-	query := "INSERT INTO `order` (product_id, user_id, qty, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
+	return transaction(ctx, or.Conn, func(tx *sql.Tx) error {
+		return storeOrder(ctx, tx, order)
+	})
+}
+
+// Update will change the user and total of the order and replace its items within a single
+// transaction. The order is locked first, so it is not confirmed while its lines change,
+// and ErrOrderLocked is returned when its status no longer allows a change. So is its
+// saga, which must not have requested the stock yet, the items it reserves are replaced
+// as well.
+func (or *orderRepository) Update(ctx context.Context, order *domain.Order, id uint32) (err error) {
+	query := "UPDATE `order` SET user_id=?, total=?, currency=?, updated_at=? WHERE id=?"
+
+	return transaction(ctx, or.Conn, func(tx *sql.Tx) (err error) {
+		var status domain.OrderStatus
+		err = tx.QueryRowContext(ctx, "SELECT status FROM `order` WHERE id=? FOR UPDATE", id).Scan(&status)
+		if err == sql.ErrNoRows {
+			return domain.ErrNotFound
+		}
+		if err != nil {
+			return
+		}
+		if !status.Editable() {
+			return domain.ErrOrderLocked
+		}
+
+		saga := domain.Saga{OrderID: id, Items: order.StockItems()}
+		err = tx.QueryRowContext(ctx, "SELECT id, state FROM saga WHERE order_id=? FOR UPDATE", id).Scan(&saga.ID, &saga.State)
+		if err == sql.ErrNoRows {
+			// Placed before sagas were recorded, its stock was requested with the order
+			return domain.ErrOrderLocked
+		}
+		if err != nil {
+			return
+		}
+		if saga.State != domain.SagaStarted {
+			return domain.ErrOrderLocked
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM saga_item WHERE saga_id=?", saga.ID)
+		if err != nil {
+			return
+		}
+		err = storeSagaItems(ctx, tx, &saga)
+		if err != nil {
+			return
+		}
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return
+		}
+
+		t := time.Now()
+		ts := t.Format("2006-01-02 15:04:05")
+		_, err = stmt.ExecContext(ctx, order.UserID, order.Total.Amount, order.Total.Currency, ts, id)
+		if err != nil {
+			return
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM order_item WHERE order_id=?", id)
+		if err != nil {
+			return
+		}
+
+		order.ID = id
+		return storeItems(ctx, tx, order)
+	})
+}
+
+// Delete will remove the order and its items within a single transaction
+func (or *orderRepository) Delete(ctx context.Context, id uint32) (err error) {
+	query := "DELETE FROM `order` WHERE id=?"
+
+	return transaction(ctx, or.Conn, func(tx *sql.Tx) (err error) {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return
+		}
+
+		result, err := stmt.ExecContext(ctx, id)
+		if err != nil {
+			return
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return
+		}
+
+		if rowsAffected != 1 {
+			err = domain.ErrNotFound
+			return
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM order_item WHERE order_id=?", id)
+		return
+	})
+}
+
+// UpdateStatus will move the order from one status to another, the order is left
+// untouched when its status is no longer the expected one
+func (or *orderRepository) UpdateStatus(ctx context.Context, id uint32, from domain.OrderStatus, to domain.OrderStatus) (err error) {
+	query := "UPDATE `order` SET status=?, updated_at=? WHERE id=? AND status=?"
+
 	stmt, err := or.Conn.PrepareContext(ctx, query)
 	if err != nil {
 		return
 	}
 
-	res, err := stmt.ExecContext(ctx, order.ProductID, order.UserID, order.Qty, ts, ts)
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	result, err := stmt.ExecContext(ctx, to, ts, id, from)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected != 1 {
+		err = domain.ErrInvalidTransition
+		return
+	}
+
+	return
+}
+
+// transaction will run fn in a database transaction, committing it only when fn succeeds
+func transaction(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	err = fn(tx)
+	if err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			log.Println(errRollback)
+		}
+		return
+	}
+
+	return tx.Commit()
+}
+
+// storeOrder will insert the order and its items, setting their ID
+func storeOrder(ctx context.Context, tx *sql.Tx, order *domain.Order) (err error) {
+	query := "INSERT INTO `order` (user_id, total, currency, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	res, err := stmt.ExecContext(ctx, order.UserID, order.Total.Amount, order.Total.Currency, order.Status, ts, ts)
 	if err != nil {
 		return
 	}
@@ -66,5 +324,33 @@ func (or *orderRepository) Store(ctx context.Context, order *domain.Order) (err
 		return
 	}
 	order.ID = uint32(lastID)
+	order.CreatedAt = t
+	order.UpdatedAt = t
+	return storeItems(ctx, tx, order)
+}
+
+// storeItems will insert the items of the order, setting their ID and order ID
+func storeItems(ctx context.Context, tx *sql.Tx, order *domain.Order) (err error) {
+	query := "INSERT INTO order_item (order_id, product_id, qty, unit_price, subtotal, currency) VALUES (?, ?, ?, ?, ?, ?)"
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		res, err := stmt.ExecContext(ctx, order.ID, item.ProductID, item.Qty, item.UnitPrice.Amount, item.Subtotal.Amount, item.UnitPrice.Currency)
+		if err != nil {
+			return err
+		}
+
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = uint32(lastID)
+		item.OrderID = order.ID
+	}
 	return
 }
@@ -8,21 +8,31 @@ import (
 	"log"
 	"order/domain"
 	"order/repository"
+	"platform/money"
+	"platform/pagination"
 	"regexp"
 	"testing"
-	"time"
 )
 
 var (
-	t       = time.Now()
-	ts      = t.Format("2006-01-02 15:04:05")
 	order = &domain.Order{
 		ID: 1,
-		ProductID: 3,
-		Qty: 2,
+		UserID: 1,
+		Items: []domain.OrderItem{
+			{ProductID: 3, Qty: 2, UnitPrice: idr(5000), Subtotal: idr(10000)},
+			{ProductID: 4, Qty: 1, UnitPrice: idr(12000), Subtotal: idr(12000)},
+		},
+		Total: idr(22000),
+		Status: domain.StatusPending,
 	}
+	orderColumns = []string{"id", "user_id", "total", "currency", "status", "created_at", "updated_at"}
+	itemColumns  = []string{"id", "order_id", "product_id", "qty", "unit_price", "subtotal", "currency"}
 )
 
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
 func NewMock() (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -38,13 +48,305 @@ func TestOrderRepository_Store(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 
-	query := regexp.QuoteMeta(`INSERT INTO order (product_id, user_id, qty, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`)
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().WithArgs(order.ProductID, order.Qty, ts, ts).WillReturnResult(sqlmock.NewResult(1, 1))
+	query := regexp.QuoteMeta("INSERT INTO `order` (user_id, total, currency, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)")
+	itemQuery := regexp.QuoteMeta("INSERT INTO order_item (order_id, product_id, qty, unit_price, subtotal, currency) VALUES (?, ?, ?, ?, ?, ?)")
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(order.UserID, order.Total.Amount, order.Total.Currency, order.Status, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		prepItem := mock.ExpectPrepare(itemQuery)
+		prepItem.ExpectExec().WithArgs(1, 3, 2, 5000, 10000, "IDR").WillReturnResult(sqlmock.NewResult(7, 1))
+		prepItem.ExpectExec().WithArgs(1, 4, 1, 12000, 12000, "IDR").WillReturnResult(sqlmock.NewResult(8, 1))
+		mock.ExpectCommit()
+
+		or := repository.NewOrderRepository(db)
+
+		err = or.Store(context.TODO(), order)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1), order.ID)
+		assert.Equal(t, uint32(7), order.Items[0].ID)
+		assert.Equal(t, uint32(1), order.Items[1].OrderID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("item fails", func(t *testing.T) {
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(order.UserID, 10000, "IDR", order.Status, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+		prepItem := mock.ExpectPrepare(itemQuery)
+		prepItem.ExpectExec().WithArgs(2, 3, 2, 5000, 10000, "IDR").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		or := repository.NewOrderRepository(db)
+
+		err = or.Store(context.TODO(), &domain.Order{UserID: 1, Items: order.Items[:1], Total: idr(10000), Status: domain.StatusPending})
+		assert.Equal(t, sql.ErrConnDone, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepository_Fetch(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta("SELECT id, user_id, total, currency, status, created_at, updated_at FROM `order` ORDER BY id ASC LIMIT 21")
+	itemQuery := regexp.QuoteMeta("SELECT id, order_id, product_id, qty, unit_price, subtotal, currency FROM order_item WHERE order_id IN (?, ?) ORDER BY id")
+
+	rows := sqlmock.NewRows(orderColumns).
+		AddRow(order.ID, order.UserID, order.Total.Amount, order.Total.Currency, order.Status, order.CreatedAt, order.UpdatedAt).
+		AddRow(2, 5, 5000, "IDR", domain.StatusConfirmed, order.CreatedAt, order.UpdatedAt)
+	itemRows := sqlmock.NewRows(itemColumns).
+		AddRow(7, 1, 3, 2, 5000, 10000, "IDR").
+		AddRow(8, 2, 3, 1, 5000, 5000, "IDR").
+		AddRow(9, 1, 4, 1, 12000, 12000, "IDR")
 
-	or := repository.NewOrderRepository(db)
+	mock.ExpectQuery(query).WillReturnRows(rows)
+	mock.ExpectQuery(itemQuery).WithArgs(1, 2).WillReturnRows(itemRows)
 
-	err = or.Store(context.TODO(), order)
+	orders, next, err := repo.Fetch(context.TODO(), domain.OrderFilter{}, pagination.Query{Limit: 20, Sort: "id"})
 	assert.NoError(t, err)
-	assert.Equal(t, uint32(1), order.ID)
+	assert.Empty(t, next)
+	assert.Len(t, orders, 2)
+	assert.Equal(t, domain.StatusPending, orders[0].Status)
+	assert.Len(t, orders[0].Items, 2)
+	assert.Equal(t, idr(22000), orders[0].Total)
+	assert.Len(t, orders[1].Items, 1)
+	assert.Equal(t, idr(5000), orders[1].Total)
+}
+
+func TestOrderRepository_Fetch_Error(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta("SELECT id, user_id, total, currency, status, created_at, updated_at FROM `order` ORDER BY id ASC LIMIT 21")
+	mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+
+	_, _, err := repo.Fetch(context.TODO(), domain.OrderFilter{}, pagination.Query{Limit: 20, Sort: "id"})
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_Fetch_Filtered(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta("SELECT id, user_id, total, currency, status, created_at, updated_at FROM `order` WHERE user_id=? AND status=? AND currency=?")
+
+	t.Run("next page", func(t *testing.T) {
+		rows := sqlmock.NewRows(orderColumns).
+			AddRow(order.ID, order.UserID, order.Total.Amount, order.Total.Currency, order.Status, order.CreatedAt, order.UpdatedAt).
+			AddRow(3, order.UserID, 5000, "IDR", order.Status, order.CreatedAt, order.UpdatedAt)
+		itemRows := sqlmock.NewRows(itemColumns).
+			AddRow(7, 1, 3, 2, 5000, 10000, "IDR")
+
+		q := pagination.Query{Limit: 1, Sort: "total", Desc: true}
+		mock.ExpectQuery(query+regexp.QuoteMeta(" ORDER BY total DESC, id DESC LIMIT 2")).WithArgs(order.UserID, domain.StatusPending, "IDR").WillReturnRows(rows)
+		// The items are only loaded for the orders of the page
+		mock.ExpectQuery("FROM order_item").WithArgs(1).WillReturnRows(itemRows)
+
+		orders, next, err := repo.Fetch(context.TODO(), domain.OrderFilter{UserID: order.UserID, Status: domain.StatusPending, Currency: "IDR"}, q)
+		assert.NoError(t, err)
+		assert.Len(t, orders, 1)
+		assert.Equal(t, order.UserID, orders[0].UserID)
+		assert.Len(t, orders[0].Items, 1)
+		assert.Equal(t, q.Cursor("22000", 1), next)
+	})
+
+	t.Run("no orders", func(t *testing.T) {
+		mock.ExpectQuery(query).WithArgs(uint32(9), domain.StatusPending, "IDR").WillReturnRows(sqlmock.NewRows(orderColumns))
+
+		orders, _, err := repo.Fetch(context.TODO(), domain.OrderFilter{UserID: 9, Status: domain.StatusPending, Currency: "IDR"}, pagination.Query{Limit: 20, Sort: "id"})
+		assert.NoError(t, err)
+		assert.Len(t, orders, 0)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepository_GetByID(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta("SELECT id, user_id, total, currency, status, created_at, updated_at FROM `order` WHERE id=?")
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows(orderColumns).
+			AddRow(order.ID, order.UserID, order.Total.Amount, order.Total.Currency, order.Status, order.CreatedAt, order.UpdatedAt)
+		itemRows := sqlmock.NewRows(itemColumns).
+			AddRow(7, 1, 3, 2, 5000, 10000, "IDR").
+			AddRow(9, 1, 4, 1, 12000, 12000, "IDR")
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(order.ID).WillReturnRows(rows)
+		mock.ExpectQuery("FROM order_item").WithArgs(order.ID).WillReturnRows(itemRows)
+
+		o, err := repo.GetByID(context.TODO(), order.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, order.ID, o.ID)
+		assert.Equal(t, []domain.OrderItem{
+			{ID: 7, OrderID: 1, ProductID: 3, Qty: 2, UnitPrice: idr(5000), Subtotal: idr(10000)},
+			{ID: 9, OrderID: 1, ProductID: 4, Qty: 1, UnitPrice: idr(12000), Subtotal: idr(12000)},
+		}, o.Items)
+		assert.Equal(t, idr(22000), o.Total)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		rows := sqlmock.NewRows(orderColumns)
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(uint32(2)).WillReturnRows(rows)
+
+		_, err := repo.GetByID(context.TODO(), 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+}
+
+func TestOrderRepository_Update(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	lockQuery := regexp.QuoteMeta("SELECT status FROM `order` WHERE id=? FOR UPDATE")
+	sagaQuery := regexp.QuoteMeta("SELECT id, state FROM saga WHERE order_id=? FOR UPDATE")
+	query := regexp.QuoteMeta("UPDATE `order` SET user_id=?, total=?, currency=?, updated_at=? WHERE id=?")
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("PENDING"))
+		mock.ExpectQuery(sagaQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(4, domain.SagaStarted))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM saga_item WHERE saga_id=?")).WithArgs(uint32(4)).WillReturnResult(sqlmock.NewResult(0, 2))
+		prepSagaItem := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO saga_item (saga_id, product_id, qty) VALUES (?, ?, ?)"))
+		prepSagaItem.ExpectExec().WithArgs(uint32(4), uint32(3), 2).WillReturnResult(sqlmock.NewResult(5, 1))
+		prepSagaItem.ExpectExec().WithArgs(uint32(4), uint32(4), 1).WillReturnResult(sqlmock.NewResult(6, 1))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(order.UserID, order.Total.Amount, order.Total.Currency, sqlmock.AnyArg(), order.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM order_item WHERE order_id=?")).WithArgs(order.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+		prepItem := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO order_item (order_id, product_id, qty, unit_price, subtotal, currency) VALUES (?, ?, ?, ?, ?, ?)"))
+		prepItem.ExpectExec().WithArgs(order.ID, 3, 2, 5000, 10000, "IDR").WillReturnResult(sqlmock.NewResult(10, 1))
+		prepItem.ExpectExec().WithArgs(order.ID, 4, 1, 12000, 12000, "IDR").WillReturnResult(sqlmock.NewResult(11, 1))
+		mock.ExpectCommit()
+
+		err := repo.Update(context.TODO(), order, order.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("confirmed meanwhile", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("CONFIRMED"))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), order, order.ID)
+		assert.Equal(t, domain.ErrOrderLocked, err)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("FAILED"))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), order, order.ID)
+		assert.Equal(t, domain.ErrOrderLocked, err)
+	})
+
+	t.Run("requested", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("PENDING"))
+		mock.ExpectQuery(sagaQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(4, domain.SagaRequested))
+		mock.ExpectRollback()
+
+		// The product service may already hold the stock of the lines the order was placed with
+		err := repo.Update(context.TODO(), order, order.ID)
+		assert.Equal(t, domain.ErrOrderLocked, err)
+	})
+
+	t.Run("placed before sagas", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("PENDING"))
+		mock.ExpectQuery(sagaQuery).WithArgs(order.ID).WillReturnRows(sqlmock.NewRows([]string{"id", "state"}))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), order, order.ID)
+		assert.Equal(t, domain.ErrOrderLocked, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"status"}))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), order, 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_Delete(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta("DELETE FROM `order` WHERE id=?")
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM order_item WHERE order_id=?")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		err := repo.Delete(context.TODO(), 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.Delete(context.TODO(), 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderRepository_UpdateStatus(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewOrderRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta("UPDATE `order` SET status=?, updated_at=? WHERE id=? AND status=?")
+
+	t.Run("success", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(domain.StatusConfirmed, sqlmock.AnyArg(), order.ID, domain.StatusPending).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UpdateStatus(context.TODO(), order.ID, domain.StatusPending, domain.StatusConfirmed)
+		assert.NoError(t, err)
+	})
+
+	t.Run("status changed", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(domain.StatusConfirmed, sqlmock.AnyArg(), order.ID, domain.StatusPending).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UpdateStatus(context.TODO(), order.ID, domain.StatusPending, domain.StatusConfirmed)
+		assert.Equal(t, domain.ErrInvalidTransition, err)
+	})
 }
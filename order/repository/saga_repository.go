@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"golang.org/x/net/context"
+	"order/domain"
+	"time"
+)
+
+type sagaRepository struct {
+	Conn *sql.DB
+}
+
+func NewSagaRepository(db *sql.DB) domain.SagaRepository {
+	return &sagaRepository{Conn: db}
+}
+
+// FetchInFlight will fetch the sagas that still have a step to run
+func (sr *sagaRepository) FetchInFlight(ctx context.Context) (sagas []domain.Saga, err error) {
+	query := `SELECT id, order_id, state, created_at, updated_at FROM saga WHERE state IN (?, ?, ?, ?) ORDER BY id`
+	rows, err := sr.Conn.QueryContext(ctx, query, domain.SagaStarted, domain.SagaRequested, domain.SagaReserved, domain.SagaCompensating)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sagas = make([]domain.Saga, 0)
+	for rows.Next() {
+		s := domain.Saga{}
+		err = rows.Scan(&s.ID, &s.OrderID, &s.State, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, s)
+	}
+
+	return sagas, rows.Err()
+}
+
+// FetchRequested will fetch the sagas waiting for the product service reply since before
+func (sr *sagaRepository) FetchRequested(ctx context.Context, before time.Time) (sagas []domain.Saga, err error) {
+	query := `SELECT id, order_id, state, created_at, updated_at FROM saga WHERE state=? AND updated_at<? ORDER BY id`
+	rows, err := sr.Conn.QueryContext(ctx, query, domain.SagaRequested, before.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sagas = make([]domain.Saga, 0)
+	for rows.Next() {
+		s := domain.Saga{}
+		err = rows.Scan(&s.ID, &s.OrderID, &s.State, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, s)
+	}
+
+	return sagas, rows.Err()
+}
+
+// FetchStalled will fetch the sagas left reserved or compensating since before, a confirm
+// or a release that failed on the way
+func (sr *sagaRepository) FetchStalled(ctx context.Context, before time.Time) (sagas []domain.Saga, err error) {
+	query := `SELECT id, order_id, state, created_at, updated_at FROM saga WHERE state IN (?, ?) AND updated_at<? ORDER BY id`
+	rows, err := sr.Conn.QueryContext(ctx, query, domain.SagaReserved, domain.SagaCompensating, before.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sagas = make([]domain.Saga, 0)
+	for rows.Next() {
+		s := domain.Saga{}
+		err = rows.Scan(&s.ID, &s.OrderID, &s.State, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, s)
+	}
+
+	return sagas, rows.Err()
+}
+
+func (sr *sagaRepository) GetByOrderID(ctx context.Context, orderID uint32) (saga domain.Saga, err error) {
+	query := `SELECT id, order_id, state, created_at, updated_at FROM saga WHERE order_id=?`
+
+	stmt, err := sr.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return domain.Saga{}, err
+	}
+
+	row := stmt.QueryRowContext(ctx, orderID)
+	saga = domain.Saga{}
+
+	err = row.Scan(
+		&saga.ID,
+		&saga.OrderID,
+		&saga.State,
+		&saga.CreatedAt,
+		&saga.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
+	return
+}
+
+// GetItems will return the products and qty the saga reserves
+func (sr *sagaRepository) GetItems(ctx context.Context, sagaID uint32) (items []domain.StockItem, err error) {
+	query := `SELECT product_id, qty FROM saga_item WHERE saga_id=? ORDER BY id`
+	rows, err := sr.Conn.QueryContext(ctx, query, sagaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items = make([]domain.StockItem, 0)
+	for rows.Next() {
+		item := domain.StockItem{}
+		err = rows.Scan(&item.ProductID, &item.Qty)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// Store will save a new order with the saga placing it, and the items the saga reserves,
+// within a single transaction so an order is never left without its saga
+func (sr *sagaRepository) Store(ctx context.Context, order *domain.Order, saga *domain.Saga) (err error) {
+	query := `INSERT INTO saga (order_id, state, created_at, updated_at) VALUES (?, ?, ?, ?)`
+
+	return transaction(ctx, sr.Conn, func(tx *sql.Tx) (err error) {
+		err = storeOrder(ctx, tx, order)
+		if err != nil {
+			return
+		}
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return
+		}
+
+		t := time.Now()
+		ts := t.Format("2006-01-02 15:04:05")
+		res, err := stmt.ExecContext(ctx, order.ID, saga.State, ts, ts)
+		if err != nil {
+			return
+		}
+
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return
+		}
+		saga.ID = uint32(lastID)
+		saga.OrderID = order.ID
+		return storeSagaItems(ctx, tx, saga)
+	})
+}
+
+func (sr *sagaRepository) UpdateState(ctx context.Context, id uint32, state domain.SagaState) (err error) {
+	query := `UPDATE saga SET state=?, updated_at=? WHERE id=?`
+
+	stmt, err := sr.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	result, err := stmt.ExecContext(ctx, state, ts, id)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected != 1 {
+		err = fmt.Errorf("total affected: %d", rowsAffected)
+		return
+	}
+
+	return
+}
+
+// UpdateStateFrom will move the saga to state only while it is still in from, so two
+// steps racing on a saga do not both run, ErrSagaStateChanged is returned to the other
+func (sr *sagaRepository) UpdateStateFrom(ctx context.Context, id uint32, from, state domain.SagaState) (err error) {
+	query := `UPDATE saga SET state=?, updated_at=? WHERE id=? AND state=?`
+
+	ts := time.Now().Format("2006-01-02 15:04:05")
+	result, err := sr.Conn.ExecContext(ctx, query, state, ts, id, from)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected != 1 {
+		err = domain.ErrSagaStateChanged
+	}
+	return
+}
+
+// storeSagaItems will insert the items the saga reserves
+func storeSagaItems(ctx context.Context, tx *sql.Tx, saga *domain.Saga) (err error) {
+	query := `INSERT INTO saga_item (saga_id, product_id, qty) VALUES (?, ?, ?)`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	for _, item := range saga.Items {
+		_, err = stmt.ExecContext(ctx, saga.ID, item.ProductID, item.Qty)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
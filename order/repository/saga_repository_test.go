@@ -0,0 +1,237 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"order/domain"
+	"order/repository"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var saga = &domain.Saga{
+	ID:      1,
+	OrderID: 1,
+	State:   domain.SagaStarted,
+}
+
+func TestSagaRepository_FetchInFlight(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT id, order_id, state, created_at, updated_at FROM saga WHERE state IN (?, ?, ?, ?) ORDER BY id`)
+
+	rows := sqlmock.NewRows([]string{"id", "order_id", "state", "created_at", "updated_at"}).
+		AddRow(saga.ID, saga.OrderID, domain.SagaReserved, saga.CreatedAt, saga.UpdatedAt)
+
+	mock.ExpectQuery(query).
+		WithArgs(domain.SagaStarted, domain.SagaRequested, domain.SagaReserved, domain.SagaCompensating).
+		WillReturnRows(rows)
+
+	sagas, err := repo.FetchInFlight(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, sagas, 1)
+	assert.Equal(t, domain.SagaReserved, sagas[0].State)
+}
+
+func TestSagaRepository_GetByOrderID(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT id, order_id, state, created_at, updated_at FROM saga WHERE order_id=?`)
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "order_id", "state", "created_at", "updated_at"}).
+			AddRow(saga.ID, saga.OrderID, saga.State, saga.CreatedAt, saga.UpdatedAt)
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(saga.OrderID).WillReturnRows(rows)
+
+		s, err := repo.GetByOrderID(context.TODO(), saga.OrderID)
+		assert.NoError(t, err)
+		assert.Equal(t, saga.OrderID, s.OrderID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "order_id", "state", "created_at", "updated_at"})
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(uint32(2)).WillReturnRows(rows)
+
+		_, err := repo.GetByOrderID(context.TODO(), 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+}
+
+func TestSagaRepository_GetItems(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT product_id, qty FROM saga_item WHERE saga_id=? ORDER BY id`)
+
+	rows := sqlmock.NewRows([]string{"product_id", "qty"}).AddRow(3, 2).AddRow(4, 1)
+	mock.ExpectQuery(query).WithArgs(uint32(4)).WillReturnRows(rows)
+
+	items, err := repo.GetItems(context.TODO(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.StockItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}, items)
+}
+
+func TestSagaRepository_Store(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	orderQuery := regexp.QuoteMeta("INSERT INTO `order` (user_id, total, currency, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)")
+	orderItemQuery := regexp.QuoteMeta("INSERT INTO order_item (order_id, product_id, qty, unit_price, subtotal, currency) VALUES (?, ?, ?, ?, ?, ?)")
+	query := regexp.QuoteMeta(`INSERT INTO saga (order_id, state, created_at, updated_at) VALUES (?, ?, ?, ?)`)
+	itemQuery := regexp.QuoteMeta(`INSERT INTO saga_item (saga_id, product_id, qty) VALUES (?, ?, ?)`)
+
+	newOrder := func() *domain.Order {
+		return &domain.Order{
+			UserID: 1,
+			Items:  []domain.OrderItem{{ProductID: 3, Qty: 2, UnitPrice: idr(5000), Subtotal: idr(10000)}},
+			Total:  idr(10000),
+			Status: domain.StatusPending,
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		prepOrder := mock.ExpectPrepare(orderQuery)
+		prepOrder.ExpectExec().WithArgs(1, 10000, "IDR", domain.StatusPending, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(7, 1))
+		prepOrderItem := mock.ExpectPrepare(orderItemQuery)
+		prepOrderItem.ExpectExec().WithArgs(7, 3, 2, 5000, 10000, "IDR").WillReturnResult(sqlmock.NewResult(9, 1))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().
+			WithArgs(uint32(7), domain.SagaStarted, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(4, 1))
+		prepItem := mock.ExpectPrepare(itemQuery)
+		prepItem.ExpectExec().WithArgs(uint32(4), uint32(3), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		o := newOrder()
+		s := domain.Saga{State: domain.SagaStarted, Items: o.StockItems()}
+		err := repo.Store(context.TODO(), o, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(7), o.ID)
+		assert.Equal(t, uint32(4), s.ID)
+		assert.Equal(t, uint32(7), s.OrderID)
+	})
+
+	t.Run("saga fails", func(t *testing.T) {
+		mock.ExpectBegin()
+		prepOrder := mock.ExpectPrepare(orderQuery)
+		prepOrder.ExpectExec().WithArgs(1, 10000, "IDR", domain.StatusPending, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(8, 1))
+		prepOrderItem := mock.ExpectPrepare(orderItemQuery)
+		prepOrderItem.ExpectExec().WithArgs(8, 3, 2, 5000, 10000, "IDR").WillReturnResult(sqlmock.NewResult(10, 1))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(uint32(8), domain.SagaStarted, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnError(sql.ErrConnDone)
+		// The order goes away with the saga, no pending order is left without one
+		mock.ExpectRollback()
+
+		o := newOrder()
+		err := repo.Store(context.TODO(), o, &domain.Saga{State: domain.SagaStarted, Items: o.StockItems()})
+		assert.Equal(t, sql.ErrConnDone, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSagaRepository_FetchRequested(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT id, order_id, state, created_at, updated_at FROM saga WHERE state=? AND updated_at<? ORDER BY id`)
+
+	before := time.Now().Add(-5 * time.Minute)
+	rows := sqlmock.NewRows([]string{"id", "order_id", "state", "created_at", "updated_at"}).
+		AddRow(saga.ID, saga.OrderID, domain.SagaRequested, saga.CreatedAt, saga.UpdatedAt)
+	mock.ExpectQuery(query).WithArgs(domain.SagaRequested, before.Format("2006-01-02 15:04:05")).WillReturnRows(rows)
+
+	sagas, err := repo.FetchRequested(context.TODO(), before)
+	assert.NoError(t, err)
+	assert.Len(t, sagas, 1)
+	assert.Equal(t, domain.SagaRequested, sagas[0].State)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSagaRepository_FetchStalled(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT id, order_id, state, created_at, updated_at FROM saga WHERE state IN (?, ?) AND updated_at<? ORDER BY id`)
+
+	before := time.Now().Add(-30 * time.Second)
+	rows := sqlmock.NewRows([]string{"id", "order_id", "state", "created_at", "updated_at"}).
+		AddRow(saga.ID, saga.OrderID, domain.SagaCompensating, saga.CreatedAt, saga.UpdatedAt)
+	mock.ExpectQuery(query).WithArgs(domain.SagaReserved, domain.SagaCompensating, before.Format("2006-01-02 15:04:05")).WillReturnRows(rows)
+
+	sagas, err := repo.FetchStalled(context.TODO(), before)
+	assert.NoError(t, err)
+	assert.Len(t, sagas, 1)
+	assert.Equal(t, domain.SagaCompensating, sagas[0].State)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSagaRepository_UpdateStateFrom(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`UPDATE saga SET state=?, updated_at=? WHERE id=? AND state=?`)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec(query).WithArgs(domain.SagaCompensating, sqlmock.AnyArg(), saga.ID, domain.SagaRequested).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UpdateStateFrom(context.TODO(), saga.ID, domain.SagaRequested, domain.SagaCompensating)
+		assert.NoError(t, err)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		mock.ExpectExec(query).WithArgs(domain.SagaCompensating, sqlmock.AnyArg(), saga.ID, domain.SagaRequested).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UpdateStateFrom(context.TODO(), saga.ID, domain.SagaRequested, domain.SagaCompensating)
+		assert.Equal(t, domain.ErrSagaStateChanged, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSagaRepository_UpdateState(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewSagaRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`UPDATE saga SET state=?, updated_at=? WHERE id=?`)
+	prep := mock.ExpectPrepare(query)
+	prep.ExpectExec().WithArgs(domain.SagaCompleted, sqlmock.AnyArg(), saga.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.UpdateState(context.TODO(), saga.ID, domain.SagaCompleted)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"order/domain"
+	"platform/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ordersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Number of orders stored and sent to the product service for a stock reservation.",
+	})
+
+	ordersRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_rejected_total",
+		Help: "Number of orders the product service rejected, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(ordersCreated, ordersRejected)
+}
+
+// rejected will count a rejected order, the reasons are bounded so that a new reason of
+// the product service does not create a new series
+func rejected(reply *domain.OrderReply) {
+	reason := "other"
+	switch reply.Reason {
+	case domain.ReasonInsufficientStock, domain.ReasonProductNotFound, domain.ReasonVariantRequired, domain.ReasonInvalidItems:
+		reason = reply.Reason
+	}
+	ordersRejected.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"order/domain"
+	"order/domain/mocks"
+	"order/service"
+	"platform/broker"
+	"platform/metrics"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape will read the value of series from the exposition text, 0 when it is missing
+func scrape(t *testing.T, url, series string) float64 {
+	resp, err := http.Get(url + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), series+" "); value != scanner.Text() {
+			v, err := strconv.ParseFloat(value, 64)
+			require.NoError(t, err)
+			return v
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	e := echo.New()
+	metrics.NewMetricsController(e)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	created := scrape(t, server.URL, `orders_created_total`)
+	insufficient := scrape(t, server.URL, `orders_rejected_total{reason="insufficient_stock"}`)
+	other := scrape(t, server.URL, `orders_rejected_total{reason="other"}`)
+
+	mockSagaRepo := new(mocks.SagaRepository)
+	mockOrderRepo := new(mocks.OrderRepository)
+	mockSagaRepo.On("Store", mock.Anything, mock.AnythingOfType("*domain.Order"), mock.AnythingOfType("*domain.Saga")).Return(nil)
+	mockSagaRepo.On("UpdateStateFrom", mock.Anything, mock.Anything, domain.SagaStarted, domain.SagaRequested).Return(nil)
+	mockSagaRepo.On("GetItems", mock.Anything, mock.Anything).Return([]domain.StockItem{{ProductID: 3, Qty: 2}}, nil)
+	mockSagaRepo.On("GetByOrderID", mock.Anything, mock.Anything).Return(domain.Saga{}, domain.ErrNotFound)
+	mockOrderRepo.On("GetByID", mock.Anything, mock.Anything).Return(domain.Order{Status: domain.StatusPending}, nil)
+	mockOrderRepo.On("UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), new(mocks.ProductClient), time.Second*2)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sc.Start(context.TODO(), &domain.Order{UserID: 1, Items: items}))
+	}
+	require.NoError(t, sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 1, Reason: domain.ReasonInsufficientStock}))
+	require.NoError(t, sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 2, Reason: "discontinued"}))
+	require.NoError(t, sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 3, Accepted: true}))
+
+	assert.Equal(t, created+3, scrape(t, server.URL, `orders_created_total`))
+	assert.Equal(t, insufficient+1, scrape(t, server.URL, `orders_rejected_total{reason="insufficient_stock"}`))
+	assert.Equal(t, other+1, scrape(t, server.URL, `orders_rejected_total{reason="other"}`))
+}
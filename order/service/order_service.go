@@ -3,37 +3,254 @@ package service
 import (
 	"golang.org/x/net/context"
 	"order/domain"
+	"platform/money"
+	"platform/pagination"
 	"time"
 )
 
 type orderService struct {
 	orderRepo domain.OrderRepository
+	saga domain.SagaCoordinator
+	userClient domain.UserClient
+	productClient domain.ProductClient
 	contextTimeout time.Duration
 }
 
-func NewOrderService(order domain.OrderRepository, timeout time.Duration) domain.OrderService {
+func NewOrderService(order domain.OrderRepository, saga domain.SagaCoordinator, user domain.UserClient, product domain.ProductClient, timeout time.Duration) domain.OrderService {
 	return &orderService{
 		orderRepo:      order,
+		saga:           saga,
+		userClient:     user,
+		productClient:  product,
 		contextTimeout: timeout,
 	}
 }
 
-func (os *orderService) Fetch(c context.Context) (orders []domain.Order, err error) {
+func (os *orderService) Fetch(c context.Context, filter domain.OrderFilter, q pagination.Query) (orders []domain.Order, next string, err error) {
 	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
 	defer cancel()
 
-	orders, err = os.orderRepo.Fetch(ctx)
+	// Totals in different currencies can not be compared, so a list sorted by total has one
+	if q.Sort == "total" && filter.Currency == "" {
+		return nil, "", domain.ErrCurrencyRequired
+	}
+
+	orders, next, err = os.orderRepo.Fetch(ctx, filter, q)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	return
 }
 
+func (os *orderService) GetByID(c context.Context, id uint32) (order domain.Order, err error) {
+	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
+	defer cancel()
+
+	order, err = os.orderRepo.GetByID(ctx, id)
+	return
+}
+
+// Store will price the order items, save the order as pending and start the saga reserving
+// their stock. The order is refused with domain.ErrUserNotFound when its user does not
+// exist, with domain.ErrProductNotFound when one of its products does not, and with a
+// *domain.PriceChangedError when an item is no longer sold at its expected price.
 func (os *orderService) Store(c context.Context, order *domain.Order) (err error)  {
 	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
 	defer cancel()
 
-	err = os.orderRepo.Store(ctx, order)
+	err = order.Validate()
+	if err != nil {
+		return
+	}
+
+	_, err = os.userClient.GetUser(ctx, order.UserID)
+	if err != nil {
+		return
+	}
+
+	err = os.price(ctx, order, nil)
+	if err != nil {
+		return
+	}
+
+	err = os.saga.Start(ctx, order)
+	return
+}
+
+// Update will change the order lines, the status can only be changed through UpdateStatus.
+// Only a pending order whose stock is not requested yet can be changed, ErrOrderLocked is
+// returned once the product service may hold stock for the lines it was placed with.
+func (os *orderService) Update(c context.Context, order *domain.Order, id uint32) (err error) {
+	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
+	defer cancel()
+
+	err = order.Validate()
+	if err != nil {
+		return
+	}
+
+	current, err := os.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if !current.Status.Editable() {
+		return domain.ErrOrderLocked
+	}
+
+	if order.UserID != current.UserID {
+		_, err = os.userClient.GetUser(ctx, order.UserID)
+		if err != nil {
+			return
+		}
+	}
+
+	err = os.price(ctx, order, current.Items)
+	if err != nil {
+		return
+	}
+
+	err = os.orderRepo.Update(ctx, order, id)
+	if err != nil {
+		return
+	}
+
+	order.ID = id
+	order.Status = current.Status
+	order.CreatedAt = current.CreatedAt
+	order.UpdatedAt = time.Now()
+	return
+}
+
+// price will set the unit price of every item to the current price of its product and
+// compute the subtotals and the order total, the items of a product already ordered keep
+// their price. An item with an expected price other than its unit price is refused, and
+// so is an order of items priced in different currencies or of a product with variants.
+func (os *orderService) price(ctx context.Context, order *domain.Order, ordered []domain.OrderItem) (err error) {
+	prices := make(map[uint32]money.Money, len(ordered))
+	for _, item := range ordered {
+		prices[item.ProductID] = item.UnitPrice
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		price, ok := prices[item.ProductID]
+		if !ok {
+			product, err := os.productClient.GetProduct(ctx, item.ProductID)
+			if err != nil {
+				return err
+			}
+			if len(product.Variants) > 0 {
+				return domain.ErrVariantRequired
+			}
+			price = product.Price
+		}
+
+		if item.ExpectedPrice != nil && *item.ExpectedPrice != price {
+			return &domain.PriceChangedError{
+				ProductID: item.ProductID,
+				Expected:  *item.ExpectedPrice,
+				Price:     price,
+			}
+		}
+		item.UnitPrice = price
+	}
+
+	return order.ComputeTotal()
+}
+
+// Delete will remove an order once it is delivered, cancelled or has failed, before
+// that its stock may still be held or its saga running and ErrOrderLocked is returned
+func (os *orderService) Delete(c context.Context, id uint32) (err error) {
+	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
+	defer cancel()
+
+	order, err := os.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+	// A final status is never left, so the order can not move on before it is deleted
+	if !order.Status.Final() {
+		return domain.ErrOrderLocked
+	}
+
+	err = os.orderRepo.Delete(ctx, id)
+	return
+}
+
+// Resolve will move a pending order to its final status from the product service reply
+func (os *orderService) Resolve(c context.Context, reply *domain.OrderReply) (err error) {
+	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
+	defer cancel()
+
+	err = os.saga.Resolve(ctx, reply)
 	return
-}
\ No newline at end of file
+}
+
+// UpdateStatus will move the order to status when the order state machine allows it.
+// The stock held for a confirmed order is taken when it gets paid, and given back when
+// it gets cancelled. An order paid after its holds expired has failed instead.
+func (os *orderService) UpdateStatus(c context.Context, id uint32, status domain.OrderStatus) (order domain.Order, err error) {
+	ctx, cancel := context.WithTimeout(c, os.contextTimeout)
+	defer cancel()
+
+	if status == domain.StatusPaid {
+		order, err = os.pay(ctx, id)
+		if err != nil {
+			return
+		}
+	}
+
+	order, err = transition(ctx, os.orderRepo, id, status)
+	if err != nil {
+		return
+	}
+
+	if status == domain.StatusCancelled {
+		err = os.saga.Cancel(ctx, id)
+	}
+	return
+}
+
+// pay will take the stock held for an order that may be paid, an order whose holds have
+// expired is moved to failed
+func (os *orderService) pay(ctx context.Context, id uint32) (order domain.Order, err error) {
+	order, err = os.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+	if !order.Status.CanTransitionTo(domain.StatusPaid) {
+		return order, domain.ErrInvalidTransition
+	}
+
+	err = os.saga.Pay(ctx, id)
+	if err != domain.ErrReservationExpired {
+		return
+	}
+
+	failed, errStatus := transition(ctx, os.orderRepo, id, domain.StatusFailed)
+	if errStatus != nil {
+		return order, errStatus
+	}
+	return failed, err
+}
+
+func transition(ctx context.Context, orderRepo domain.OrderRepository, id uint32, status domain.OrderStatus) (order domain.Order, err error) {
+	order, err = orderRepo.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+
+	if !order.Status.CanTransitionTo(status) {
+		return order, domain.ErrInvalidTransition
+	}
+
+	err = orderRepo.UpdateStatus(ctx, id, order.Status, status)
+	if err != nil {
+		return
+	}
+
+	order.Status = status
+	order.UpdatedAt = time.Now()
+	return
+}
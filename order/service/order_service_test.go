@@ -0,0 +1,417 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"order/domain"
+	"order/domain/mocks"
+	"order/service"
+	"platform/money"
+	"platform/pagination"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func idr(amount int64) money.Money {
+	return money.New(amount, "IDR")
+}
+
+func TestOrderService_Fetch(t *testing.T) {
+	mockOrderRepo := new(mocks.OrderRepository)
+	mockListOrder := []domain.Order{{ID: 1, UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}}}}
+	filter := domain.OrderFilter{UserID: 1}
+	query := pagination.Query{Limit: 20, Sort: "id"}
+
+	t.Run("success", func(t *testing.T) {
+		mockOrderRepo.On("Fetch", mock.Anything, filter, query).Return(mockListOrder, "next", nil).Once()
+		o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+		list, next, err := o.Fetch(context.TODO(), filter, query)
+		assert.NoError(t, err)
+		assert.Len(t, list, len(mockListOrder))
+		assert.Equal(t, "next", next)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockOrderRepo.On("Fetch", mock.Anything, filter, query).Return(nil, "", errors.New("unexpected error")).Once()
+		o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+		list, _, err := o.Fetch(context.TODO(), filter, query)
+		assert.Error(t, err)
+		assert.Len(t, list, 0)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("total without currency", func(t *testing.T) {
+		unusedRepo := new(mocks.OrderRepository)
+		o := service.NewOrderService(unusedRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+		_, _, err := o.Fetch(context.TODO(), filter, pagination.Query{Limit: 20, Sort: "total"})
+		assert.Equal(t, domain.ErrCurrencyRequired, err)
+		unusedRepo.AssertNotCalled(t, "Fetch", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestOrderService_GetByID(t *testing.T) {
+	mockOrderRepo := new(mocks.OrderRepository)
+	o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+	t.Run("success", func(t *testing.T) {
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(domain.Order{ID: 1}, nil).Once()
+
+		res, err := o.GetByID(context.TODO(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1), res.ID)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(2)).Return(domain.Order{}, domain.ErrNotFound).Once()
+
+		_, err := o.GetByID(context.TODO(), 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_Store(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockSaga := new(mocks.SagaCoordinator)
+		mockUserClient := new(mocks.UserClient)
+		mockProductClient := new(mocks.ProductClient)
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}}
+
+		mockUserClient.On("GetUser", mock.Anything, uint32(1)).Return(domain.User{ID: 1}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(3)).Return(domain.Product{ID: 3, Price: idr(5000)}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(4)).Return(domain.Product{ID: 4, Price: idr(12000)}, nil).Once()
+		mockSaga.On("Start", mock.Anything, &order).Return(nil).Once()
+		o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, mockUserClient, mockProductClient, time.Second*2)
+
+		err := o.Store(context.TODO(), &order)
+		assert.NoError(t, err)
+		assert.Equal(t, idr(5000), order.Items[0].UnitPrice)
+		assert.Equal(t, idr(12000), order.Items[1].UnitPrice)
+		assert.Equal(t, idr(10000), order.Items[0].Subtotal)
+		assert.Equal(t, idr(22000), order.Total)
+		mockUserClient.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+		mockSaga.AssertExpectations(t)
+	})
+
+	t.Run("invalid items", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			items []domain.OrderItem
+			err   error
+		}{
+			{"no items", nil, domain.ErrNoItems},
+			{"zero qty", []domain.OrderItem{{ProductID: 3, Qty: 0}}, domain.ErrInvalidQty},
+			{"duplicate product", []domain.OrderItem{{ProductID: 3, Qty: 1}, {ProductID: 3, Qty: 2}}, domain.ErrDuplicateItem},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mockSaga := new(mocks.SagaCoordinator)
+				o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+				err := o.Store(context.TODO(), &domain.Order{UserID: 1, Items: tt.items})
+				assert.Equal(t, tt.err, err)
+				mockSaga.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+			})
+		}
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockSaga := new(mocks.SagaCoordinator)
+		mockUserClient := new(mocks.UserClient)
+		order := domain.Order{UserID: 9, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}}}
+
+		mockUserClient.On("GetUser", mock.Anything, uint32(9)).Return(domain.User{}, domain.ErrUserNotFound).Once()
+		o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, mockUserClient, new(mocks.ProductClient), time.Second*2)
+
+		err := o.Store(context.TODO(), &order)
+		assert.Equal(t, domain.ErrUserNotFound, err)
+		mockUserClient.AssertExpectations(t)
+		mockSaga.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+	})
+
+	t.Run("product not found", func(t *testing.T) {
+		mockSaga := new(mocks.SagaCoordinator)
+		mockUserClient := new(mocks.UserClient)
+		mockProductClient := new(mocks.ProductClient)
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}, {ProductID: 8, Qty: 1}}}
+
+		mockUserClient.On("GetUser", mock.Anything, uint32(1)).Return(domain.User{ID: 1}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(3)).Return(domain.Product{ID: 3, Price: idr(5000)}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(8)).Return(domain.Product{}, domain.ErrProductNotFound).Once()
+		o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, mockUserClient, mockProductClient, time.Second*2)
+
+		err := o.Store(context.TODO(), &order)
+		assert.Equal(t, domain.ErrProductNotFound, err)
+		mockProductClient.AssertExpectations(t)
+		mockSaga.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+	})
+
+	t.Run("product with variants", func(t *testing.T) {
+		mockSaga := new(mocks.SagaCoordinator)
+		mockUserClient := new(mocks.UserClient)
+		mockProductClient := new(mocks.ProductClient)
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 5, Qty: 1}}}
+		parent := domain.Product{ID: 5, Variants: []domain.Product{{ID: 6, Price: idr(12000)}, {ID: 7, Price: idr(15000)}}}
+
+		mockUserClient.On("GetUser", mock.Anything, uint32(1)).Return(domain.User{ID: 1}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(5)).Return(parent, nil).Once()
+		o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, mockUserClient, mockProductClient, time.Second*2)
+
+		err := o.Store(context.TODO(), &order)
+		assert.Equal(t, domain.ErrVariantRequired, err)
+		mockProductClient.AssertExpectations(t)
+		mockSaga.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+	})
+	t.Run("price changed", func(t *testing.T) {
+		mockSaga := new(mocks.SagaCoordinator)
+		mockUserClient := new(mocks.UserClient)
+		mockProductClient := new(mocks.ProductClient)
+		kept, changed := idr(5000), idr(11000)
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2, ExpectedPrice: &kept}, {ProductID: 4, Qty: 1, ExpectedPrice: &changed}}}
+
+		mockUserClient.On("GetUser", mock.Anything, uint32(1)).Return(domain.User{ID: 1}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(3)).Return(domain.Product{ID: 3, Price: idr(5000)}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(4)).Return(domain.Product{ID: 4, Price: idr(12000)}, nil).Once()
+		o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, mockUserClient, mockProductClient, time.Second*2)
+
+		err := o.Store(context.TODO(), &order)
+		assert.True(t, errors.Is(err, domain.ErrPriceChanged))
+		assert.Equal(t, &domain.PriceChangedError{ProductID: 4, Expected: idr(11000), Price: idr(12000)}, err)
+		mockProductClient.AssertExpectations(t)
+		mockSaga.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+	})
+
+	t.Run("mixed currencies", func(t *testing.T) {
+		mockSaga := new(mocks.SagaCoordinator)
+		mockUserClient := new(mocks.UserClient)
+		mockProductClient := new(mocks.ProductClient)
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}}
+
+		mockUserClient.On("GetUser", mock.Anything, uint32(1)).Return(domain.User{ID: 1}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(3)).Return(domain.Product{ID: 3, Price: idr(5000)}, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(4)).Return(domain.Product{ID: 4, Price: money.New(1200, "USD")}, nil).Once()
+		o := service.NewOrderService(new(mocks.OrderRepository), mockSaga, mockUserClient, mockProductClient, time.Second*2)
+
+		err := o.Store(context.TODO(), &order)
+		assert.True(t, errors.Is(err, money.ErrCurrencyMismatch))
+		mockSaga.AssertNotCalled(t, "Start", mock.Anything, mock.Anything)
+	})
+}
+
+func TestOrderService_Update(t *testing.T) {
+	mockOrderRepo := new(mocks.OrderRepository)
+	mockProductClient := new(mocks.ProductClient)
+	o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), mockProductClient, time.Second*2)
+
+	t.Run("success", func(t *testing.T) {
+		current := domain.Order{ID: 1, UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2, UnitPrice: idr(5000)}}, Status: domain.StatusPending}
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 4}, {ProductID: 4, Qty: 1}}, Status: domain.StatusDelivered}
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(current, nil).Once()
+		mockProductClient.On("GetProduct", mock.Anything, uint32(4)).Return(domain.Product{ID: 4, Price: idr(12000)}, nil).Once()
+		mockOrderRepo.On("Update", mock.Anything, &order, uint32(1)).Return(nil).Once()
+
+		err := o.Update(context.TODO(), &order, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusPending, order.Status)
+		// The product already ordered keeps the price it was ordered at
+		assert.Equal(t, idr(5000), order.Items[0].UnitPrice)
+		assert.Equal(t, idr(32000), order.Total)
+		mockOrderRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(2)).Return(domain.Order{}, domain.ErrNotFound).Once()
+
+		err := o.Update(context.TODO(), &domain.Order{Items: []domain.OrderItem{{ProductID: 3, Qty: 1}}}, 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		// Once its stock is held the lines of an order must not change, or a release
+		// would give back more than was held
+		for _, status := range []domain.OrderStatus{domain.StatusConfirmed, domain.StatusPaid, domain.StatusShipped, domain.StatusDelivered, domain.StatusCancelled, domain.StatusFailed} {
+			mockOrderRepo := new(mocks.OrderRepository)
+			mockProductClient := new(mocks.ProductClient)
+			o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), mockProductClient, time.Second*2)
+			current := domain.Order{ID: 1, UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 1, UnitPrice: idr(5000)}}, Status: status}
+			mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(current, nil).Once()
+
+			err := o.Update(context.TODO(), &domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 100}}}, 1)
+			assert.Equal(t, domain.ErrOrderLocked, err, status)
+			mockOrderRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+			mockProductClient.AssertNotCalled(t, "GetProduct", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("requested", func(t *testing.T) {
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), mockProductClient, time.Second*2)
+		current := domain.Order{ID: 1, UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2, UnitPrice: idr(5000)}}, Status: domain.StatusPending}
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(current, nil).Once()
+		// The saga has sent its request, the product service holds the lines the order was placed with
+		mockOrderRepo.On("Update", mock.Anything, mock.AnythingOfType("*domain.Order"), uint32(1)).Return(domain.ErrOrderLocked).Once()
+
+		err := o.Update(context.TODO(), &domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 100}}}, 1)
+		assert.Equal(t, domain.ErrOrderLocked, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("user changed", func(t *testing.T) {
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockUserClient := new(mocks.UserClient)
+		o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), mockUserClient, new(mocks.ProductClient), time.Second*2)
+
+		current := domain.Order{ID: 1, UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2, UnitPrice: idr(5000)}}, Status: domain.StatusPending}
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(current, nil).Twice()
+		mockUserClient.On("GetUser", mock.Anything, uint32(2)).Return(domain.User{ID: 2}, nil).Once()
+		mockUserClient.On("GetUser", mock.Anything, uint32(9)).Return(domain.User{}, domain.ErrUserNotFound).Once()
+
+		order := domain.Order{UserID: 2, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}}}
+		mockOrderRepo.On("Update", mock.Anything, &order, uint32(1)).Return(nil).Once()
+		err := o.Update(context.TODO(), &order, 1)
+		assert.NoError(t, err)
+
+		err = o.Update(context.TODO(), &domain.Order{UserID: 9, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}}}, 1)
+		assert.Equal(t, domain.ErrUserNotFound, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockUserClient.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_Delete(t *testing.T) {
+	t.Run("final", func(t *testing.T) {
+		for _, status := range []domain.OrderStatus{domain.StatusDelivered, domain.StatusCancelled, domain.StatusFailed} {
+			mockOrderRepo := new(mocks.OrderRepository)
+			mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(domain.Order{ID: 1, Status: status}, nil).Once()
+			mockOrderRepo.On("Delete", mock.Anything, uint32(1)).Return(nil).Once()
+			o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+			err := o.Delete(context.TODO(), 1)
+			assert.NoError(t, err, status)
+			mockOrderRepo.AssertExpectations(t)
+		}
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		for _, status := range []domain.OrderStatus{domain.StatusPending, domain.StatusConfirmed, domain.StatusPaid, domain.StatusShipped} {
+			mockOrderRepo := new(mocks.OrderRepository)
+			mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(domain.Order{ID: 1, Status: status}, nil).Once()
+			o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+			err := o.Delete(context.TODO(), 1)
+			assert.Equal(t, domain.ErrOrderLocked, err, status)
+			mockOrderRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(2)).Return(domain.Order{}, domain.ErrNotFound).Once()
+		o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+		err := o.Delete(context.TODO(), 2)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+}
+
+func TestOrderService_Resolve(t *testing.T) {
+	mockOrderRepo := new(mocks.OrderRepository)
+	mockSaga := new(mocks.SagaCoordinator)
+	reply := &domain.OrderReply{OrderID: 1, Accepted: true}
+
+	mockSaga.On("Resolve", mock.Anything, reply).Return(nil).Once()
+	o := service.NewOrderService(mockOrderRepo, mockSaga, new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+
+	err := o.Resolve(context.TODO(), reply)
+	assert.NoError(t, err)
+	mockSaga.AssertExpectations(t)
+}
+
+func TestOrderService_UpdateStatus(t *testing.T) {
+	tests := []struct {
+		from  domain.OrderStatus
+		to    domain.OrderStatus
+		legal bool
+	}{
+		{domain.StatusPending, domain.StatusConfirmed, true},
+		{domain.StatusPending, domain.StatusCancelled, true},
+		{domain.StatusPending, domain.StatusShipped, false},
+		{domain.StatusConfirmed, domain.StatusPaid, true},
+		{domain.StatusConfirmed, domain.StatusDelivered, false},
+		{domain.StatusPaid, domain.StatusShipped, true},
+		{domain.StatusPaid, domain.StatusCancelled, true},
+		{domain.StatusShipped, domain.StatusDelivered, true},
+		{domain.StatusShipped, domain.StatusCancelled, false},
+		{domain.StatusDelivered, domain.StatusCancelled, false},
+		{domain.StatusCancelled, domain.StatusPaid, false},
+		{domain.StatusFailed, domain.StatusConfirmed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			mockOrderRepo := new(mocks.OrderRepository)
+			mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(domain.Order{ID: 1, Status: tt.from}, nil)
+			if tt.legal {
+				mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(1), tt.from, tt.to).Return(nil).Once()
+			}
+
+			mockSaga := new(mocks.SagaCoordinator)
+			if tt.legal && tt.to == domain.StatusCancelled {
+				mockSaga.On("Cancel", mock.Anything, uint32(1)).Return(nil).Once()
+			}
+			if tt.legal && tt.to == domain.StatusPaid {
+				mockSaga.On("Pay", mock.Anything, uint32(1)).Return(nil).Once()
+			}
+
+			o := service.NewOrderService(mockOrderRepo, mockSaga, new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+			order, err := o.UpdateStatus(context.TODO(), 1, tt.to)
+			if tt.legal {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.to, order.Status)
+			} else {
+				assert.Equal(t, domain.ErrInvalidTransition, err)
+				assert.Equal(t, tt.from, order.Status)
+			}
+			mockOrderRepo.AssertExpectations(t)
+			mockSaga.AssertExpectations(t)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(9)).Return(domain.Order{}, domain.ErrNotFound).Once()
+
+		o := service.NewOrderService(mockOrderRepo, new(mocks.SagaCoordinator), new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+		_, err := o.UpdateStatus(context.TODO(), 9, domain.StatusCancelled)
+		assert.Equal(t, domain.ErrNotFound, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("paid after the holds expired", func(t *testing.T) {
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(1)).Return(domain.Order{ID: 1, Status: domain.StatusConfirmed}, nil)
+		mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(1), domain.StatusConfirmed, domain.StatusFailed).Return(nil).Once()
+		mockSaga := new(mocks.SagaCoordinator)
+		mockSaga.On("Pay", mock.Anything, uint32(1)).Return(domain.ErrReservationExpired).Once()
+
+		o := service.NewOrderService(mockOrderRepo, mockSaga, new(mocks.UserClient), new(mocks.ProductClient), time.Second*2)
+		order, err := o.UpdateStatus(context.TODO(), 1, domain.StatusPaid)
+		assert.Equal(t, domain.ErrReservationExpired, err)
+		assert.Equal(t, domain.StatusFailed, order.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockSaga.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"encoding/json"
+	"golang.org/x/net/context"
+	"log"
+	"order/domain"
+	"platform/broker"
+	"time"
+)
+
+// Defaults of the sweep expiring the reservation requests left without a reply and
+// retrying the sagas stalled on the way
+const (
+	DefaultRequestTimeout = 5 * time.Minute
+	DefaultSweepInterval  = 30 * time.Second
+)
+
+type sagaCoordinator struct {
+	sagaRepo       domain.SagaRepository
+	orderRepo      domain.OrderRepository
+	broker         broker.Broker
+	productClient  domain.ProductClient
+	contextTimeout time.Duration
+}
+
+// NewSagaCoordinator will create the coordinator of the order placement saga, every
+// step is recorded in the saga repository so an interrupted saga can be resumed
+func NewSagaCoordinator(saga domain.SagaRepository, order domain.OrderRepository, b broker.Broker, pc domain.ProductClient, timeout time.Duration) domain.SagaCoordinator {
+	return &sagaCoordinator{
+		sagaRepo:       saga,
+		orderRepo:      order,
+		broker:         b,
+		productClient:  pc,
+		contextTimeout: timeout,
+	}
+}
+
+// Start will save the order as pending and ask the product service to reserve the stock
+func (sc *sagaCoordinator) Start(ctx context.Context, order *domain.Order) (err error) {
+	order.Status = domain.StatusPending
+	saga := &domain.Saga{
+		State: domain.SagaStarted,
+		Items: order.StockItems(),
+	}
+	err = sc.sagaRepo.Store(ctx, order, saga)
+	if err != nil {
+		return
+	}
+
+	err = sc.request(ctx, saga)
+	if err != nil {
+		order.Status = domain.StatusFailed
+		return
+	}
+	ordersCreated.Inc()
+	return
+}
+
+// Resolve will record the product service reply and confirm the order, the stock is
+// released again when the order can not be confirmed. The product service sends the same
+// reply again for an order delivered twice, a saga past its request ignores it.
+func (sc *sagaCoordinator) Resolve(ctx context.Context, reply *domain.OrderReply) (err error) {
+	status := domain.StatusFailed
+	if reply.Accepted {
+		status = domain.StatusConfirmed
+	}
+
+	saga, err := sc.sagaRepo.GetByOrderID(ctx, reply.OrderID)
+	if err == domain.ErrNotFound {
+		// Orders placed before sagas were recorded have nothing to compensate
+		if !reply.Accepted {
+			rejected(reply)
+		}
+		_, err = transition(ctx, sc.orderRepo, reply.OrderID, status)
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if saga.State != domain.SagaStarted && saga.State != domain.SagaRequested {
+		return nil
+	}
+
+	if !reply.Accepted {
+		rejected(reply)
+		err = sc.sagaRepo.UpdateStateFrom(ctx, saga.ID, saga.State, domain.SagaAborted)
+		if err == domain.ErrSagaStateChanged {
+			// The request expired meanwhile
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		// Nothing was held, an order cancelled or deleted meanwhile is left as it is
+		_, err = transition(ctx, sc.orderRepo, reply.OrderID, status)
+		if err == domain.ErrInvalidTransition || err == domain.ErrNotFound {
+			err = nil
+		}
+		return
+	}
+
+	// The request may have expired meanwhile, its stock is then released by the expiry
+	err = sc.sagaRepo.UpdateStateFrom(ctx, saga.ID, saga.State, domain.SagaReserved)
+	if err == domain.ErrSagaStateChanged {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	saga.State = domain.SagaReserved
+	return sc.confirm(ctx, &saga)
+}
+
+// Cancel will give back the stock held for a confirmed order, or taken for a paid one,
+// once it has been cancelled, a reservation still in flight is released once the product
+// service replies
+func (sc *sagaCoordinator) Cancel(ctx context.Context, orderID uint32) (err error) {
+	saga, err := sc.sagaRepo.GetByOrderID(ctx, orderID)
+	if err == domain.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if saga.State != domain.SagaCompleted {
+		return nil
+	}
+	return sc.compensate(ctx, &saga)
+}
+
+// Pay will take the stock held for a confirmed order out of the product stock, it fails
+// with ErrReservationExpired when the order was not paid before the holds expired
+func (sc *sagaCoordinator) Pay(ctx context.Context, orderID uint32) (err error) {
+	saga, err := sc.sagaRepo.GetByOrderID(ctx, orderID)
+	if err == domain.ErrNotFound {
+		// Orders placed before sagas were recorded took their stock when they were placed
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if saga.State != domain.SagaCompleted {
+		return domain.ErrInvalidTransition
+	}
+	return sc.productClient.CommitOrder(ctx, orderID)
+}
+
+// Resume will run the remaining step of every saga interrupted by a restart
+func (sc *sagaCoordinator) Resume(c context.Context) (err error) {
+	ctx, cancel := context.WithTimeout(c, sc.contextTimeout)
+	sagas, err := sc.sagaRepo.FetchInFlight(ctx)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	for i := range sagas {
+		saga := &sagas[i]
+		ctx, cancel := context.WithTimeout(c, sc.contextTimeout)
+		switch saga.State {
+		case domain.SagaStarted:
+			err = sc.request(ctx, saga)
+		case domain.SagaReserved:
+			err = sc.confirm(ctx, saga)
+		case domain.SagaCompensating:
+			err = sc.compensate(ctx, saga)
+		default:
+			// Requested sagas are waiting for the product service reply
+			err = nil
+		}
+		cancel()
+		if err != nil {
+			log.Printf("saga %d: resume %s: %v", saga.ID, saga.State, err)
+		}
+	}
+
+	return nil
+}
+
+// ExpireRequests will give up on the sagas whose reservation request has waited longer
+// than timeout for the product service reply, and return how many it gave up on. What the
+// product service may have held for them is released and their orders fail. A reply
+// coming in later is ignored.
+func (sc *sagaCoordinator) ExpireRequests(c context.Context, timeout time.Duration) (expired int, err error) {
+	ctx, cancel := context.WithTimeout(c, sc.contextTimeout)
+	sagas, err := sc.sagaRepo.FetchRequested(ctx, time.Now().Add(-timeout))
+	cancel()
+	if err != nil {
+		return
+	}
+
+	for i := range sagas {
+		saga := &sagas[i]
+		ctx, cancel := context.WithTimeout(c, sc.contextTimeout)
+		err = sc.sagaRepo.UpdateStateFrom(ctx, saga.ID, domain.SagaRequested, domain.SagaCompensating)
+		if err == nil {
+			saga.State = domain.SagaCompensating
+			expired++
+			err = sc.compensate(ctx, saga)
+		}
+		cancel()
+		switch {
+		case err == domain.ErrSagaStateChanged:
+			// The reply came in meanwhile
+		case err != nil:
+			// Left compensating, to be retried by the next sweep
+			log.Printf("saga %d: expire request: %v", saga.ID, err)
+		}
+	}
+
+	return expired, nil
+}
+
+// RetryStalled will run again the confirm or the release of the sagas left reserved or
+// compensating for longer than age, a release the product service failed to answer for
+// instance, and return how many sagas it completed. A saga still moved by a request, whose
+// context has not timed out yet, is left to it.
+func (sc *sagaCoordinator) RetryStalled(c context.Context, age time.Duration) (retried int, err error) {
+	if age < sc.contextTimeout {
+		age = sc.contextTimeout
+	}
+	ctx, cancel := context.WithTimeout(c, sc.contextTimeout)
+	sagas, err := sc.sagaRepo.FetchStalled(ctx, time.Now().Add(-age))
+	cancel()
+	if err != nil {
+		return
+	}
+
+	for i := range sagas {
+		saga := &sagas[i]
+		ctx, cancel := context.WithTimeout(c, sc.contextTimeout)
+		if saga.State == domain.SagaReserved {
+			err = sc.confirm(ctx, saga)
+		} else {
+			err = sc.compensate(ctx, saga)
+		}
+		cancel()
+		if err != nil {
+			log.Printf("saga %d: retry %s: %v", saga.ID, saga.State, err)
+			continue
+		}
+		retried++
+	}
+
+	return retried, nil
+}
+
+// RunSweeper will expire the requests waiting for a reply longer than timeout and retry
+// the sagas stalled for longer than interval, every interval, or DefaultRequestTimeout and
+// DefaultSweepInterval when they are not positive, until ctx is done
+func RunSweeper(ctx context.Context, sc domain.SagaCoordinator, timeout, interval time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := sc.ExpireRequests(ctx, timeout)
+			if err != nil {
+				log.Println(err)
+			}
+			if expired > 0 {
+				log.Printf("expired %d saga requests", expired)
+			}
+
+			retried, err := sc.RetryStalled(ctx, interval)
+			if err != nil {
+				log.Println(err)
+			}
+			if retried > 0 {
+				log.Printf("retried %d stalled sagas", retried)
+			}
+		}
+	}
+}
+
+// request will ask the product service to reserve the items of the saga. The saga is
+// requested before its items are read, an edit of the order replacing them waits for it
+// or is refused, so the items sent are the ones the saga holds. A saga requested by a
+// concurrent resume is left to it, one stopped before its request went out expires.
+func (sc *sagaCoordinator) request(ctx context.Context, saga *domain.Saga) (err error) {
+	err = sc.sagaRepo.UpdateStateFrom(ctx, saga.ID, domain.SagaStarted, domain.SagaRequested)
+	if err == domain.ErrSagaStateChanged {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	saga.State = domain.SagaRequested
+
+	items, err := sc.sagaRepo.GetItems(ctx, saga.ID)
+	if err != nil {
+		return
+	}
+	saga.Items = items
+
+	body, err := json.Marshal(domain.OrderCreated{
+		OrderID: saga.OrderID,
+		Items:   items,
+	})
+	if err != nil {
+		return
+	}
+
+	err = sc.broker.Publish(ctx, domain.OrderQueue, body)
+	if err != nil {
+		// Nobody will ever answer for this order, so it must not stay pending
+		if errState := sc.sagaRepo.UpdateState(ctx, saga.ID, domain.SagaAborted); errState != nil {
+			return errState
+		}
+		if _, errStatus := transition(ctx, sc.orderRepo, saga.OrderID, domain.StatusFailed); errStatus != nil {
+			return errStatus
+		}
+	}
+	return
+}
+
+func (sc *sagaCoordinator) confirm(ctx context.Context, saga *domain.Saga) (err error) {
+	order, err := transition(ctx, sc.orderRepo, saga.OrderID, domain.StatusConfirmed)
+	if err == domain.ErrInvalidTransition && order.Status == domain.StatusConfirmed {
+		// Confirmed by an earlier attempt that could not record it
+		err = nil
+	}
+	if err != nil {
+		log.Printf("saga %d: confirm order %d: %v", saga.ID, saga.OrderID, err)
+		return sc.compensate(ctx, saga)
+	}
+
+	return sc.sagaRepo.UpdateState(ctx, saga.ID, domain.SagaCompleted)
+}
+
+func (sc *sagaCoordinator) compensate(ctx context.Context, saga *domain.Saga) (err error) {
+	if saga.State != domain.SagaCompensating {
+		err = sc.sagaRepo.UpdateState(ctx, saga.ID, domain.SagaCompensating)
+		if err != nil {
+			return
+		}
+		saga.State = domain.SagaCompensating
+	}
+
+	// The stock of every item is given back at once, so a failed release can be retried whole
+	err = sc.productClient.ReleaseOrder(ctx, saga.OrderID)
+	if err != nil {
+		return
+	}
+
+	err = sc.sagaRepo.UpdateState(ctx, saga.ID, domain.SagaCompensated)
+	if err != nil {
+		return
+	}
+	saga.State = domain.SagaCompensated
+
+	// The order may already be cancelled, or deleted once it was, otherwise it is still
+	// pending and has failed
+	_, err = transition(ctx, sc.orderRepo, saga.OrderID, domain.StatusFailed)
+	if err == domain.ErrInvalidTransition || err == domain.ErrNotFound {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,509 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"order/domain"
+	"order/domain/mocks"
+	"order/service"
+	"platform/broker"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func consumeOrders(t *testing.T, b broker.Broker) *[]domain.OrderCreated {
+	events := make([]domain.OrderCreated, 0)
+	err := b.Consume(domain.OrderQueue, func(ctx context.Context, body []byte) error {
+		var event domain.OrderCreated
+		err := json.Unmarshal(body, &event)
+		events = append(events, event)
+		return err
+	})
+	require.NoError(t, err)
+
+	return &events
+}
+
+// items are the lines of the orders the sagas place
+var items = []domain.OrderItem{{ProductID: 3, Qty: 2, UnitPrice: idr(5000)}}
+
+func TestSagaCoordinator_Start(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockSagaRepo.On("Store", mock.Anything, mock.AnythingOfType("*domain.Order"), &domain.Saga{State: domain.SagaStarted, Items: []domain.StockItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}}).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*domain.Order).ID = 7
+				args.Get(2).(*domain.Saga).ID = 4
+				args.Get(2).(*domain.Saga).OrderID = 7
+			}).Return(nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaStarted, domain.SagaRequested).Return(nil).Once()
+		mockSagaRepo.On("GetItems", mock.Anything, uint32(4)).Return([]domain.StockItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}, nil).Once()
+
+		b := broker.NewMemoryBroker()
+		events := consumeOrders(t, b)
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, b, new(mocks.ProductClient), time.Second*2)
+		order := domain.Order{UserID: 1, Items: []domain.OrderItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}}
+		err := sc.Start(context.TODO(), &order)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.StatusPending, order.Status)
+		assert.Equal(t, []domain.OrderCreated{{OrderID: 7, Items: []domain.StockItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}}}, *events)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+	})
+
+	t.Run("requested meanwhile", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockSagaRepo.On("Store", mock.Anything, mock.AnythingOfType("*domain.Order"), mock.AnythingOfType("*domain.Saga")).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*domain.Order).ID = 7
+				args.Get(2).(*domain.Saga).ID = 4
+				args.Get(2).(*domain.Saga).OrderID = 7
+			}).Return(nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaStarted, domain.SagaRequested).Return(domain.ErrSagaStateChanged).Once()
+
+		b := broker.NewMemoryBroker()
+		events := consumeOrders(t, b)
+
+		// A resume sent the request already, it is not sent twice
+		sc := service.NewSagaCoordinator(mockSagaRepo, new(mocks.OrderRepository), b, new(mocks.ProductClient), time.Second*2)
+		err := sc.Start(context.TODO(), &domain.Order{UserID: 1, Items: items})
+
+		assert.NoError(t, err)
+		assert.Empty(t, *events)
+		mockSagaRepo.AssertExpectations(t)
+		mockSagaRepo.AssertNotCalled(t, "GetItems", mock.Anything, mock.Anything)
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockSagaRepo.On("Store", mock.Anything, mock.AnythingOfType("*domain.Order"), mock.AnythingOfType("*domain.Saga")).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*domain.Order).ID = 7
+				args.Get(2).(*domain.Saga).ID = 4
+				args.Get(2).(*domain.Saga).OrderID = 7
+			}).Return(nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaStarted, domain.SagaRequested).Return(nil).Once()
+		mockSagaRepo.On("GetItems", mock.Anything, uint32(4)).Return([]domain.StockItem{{ProductID: 3, Qty: 2}}, nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaAborted).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Status: domain.StatusPending}, nil).Once()
+		mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(7), domain.StatusPending, domain.StatusFailed).Return(nil).Once()
+
+		b := broker.NewMemoryBroker()
+		require.NoError(t, b.Close())
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, b, new(mocks.ProductClient), time.Second*2)
+		order := domain.Order{UserID: 1, Items: items}
+		err := sc.Start(context.TODO(), &order)
+
+		assert.Equal(t, broker.ErrClosed, err)
+		assert.Equal(t, domain.StatusFailed, order.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+	})
+}
+
+func TestSagaCoordinator_Resolve(t *testing.T) {
+	saga := domain.Saga{ID: 4, OrderID: 7, State: domain.SagaRequested}
+
+	t.Run("accepted", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaReserved).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Status: domain.StatusPending}, nil).Once()
+		mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(7), domain.StatusPending, domain.StatusConfirmed).Return(nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompleted).Return(nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: true})
+
+		assert.NoError(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaAborted).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Status: domain.StatusPending}, nil).Once()
+		mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(7), domain.StatusPending, domain.StatusFailed).Return(nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Reason: domain.ReasonInsufficientStock})
+
+		assert.NoError(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("confirm fails", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaReserved).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Items: items, Status: domain.StatusPending}, nil).Twice()
+		mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(7), domain.StatusPending, domain.StatusConfirmed).Return(errors.New("unexpected error")).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensating).Return(nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensated).Return(nil).Once()
+		mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(7), domain.StatusPending, domain.StatusFailed).Return(nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: true})
+
+		assert.NoError(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("cancelled while pending", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaReserved).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Items: items, Status: domain.StatusCancelled}, nil).Twice()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensating).Return(nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensated).Return(nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: true})
+
+		assert.NoError(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("order deleted", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaReserved).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{}, domain.ErrNotFound).Twice()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensating).Return(nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensated).Return(nil).Once()
+
+		// The stock held for an order deleted while its saga ran is still given back
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: true})
+
+		assert.NoError(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("release fails", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaReserved).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Items: items, Status: domain.StatusCancelled}, nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensating).Return(nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(errors.New("connection refused")).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: true})
+
+		assert.Error(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("expired meanwhile", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(saga, nil).Once()
+		mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(4), domain.SagaRequested, domain.SagaReserved).Return(domain.ErrSagaStateChanged).Once()
+
+		// The expiry releases the stock and fails the order, the late reply is dropped
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: true})
+
+		assert.NoError(t, err)
+		mockSagaRepo.AssertExpectations(t)
+		mockOrderRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("sent again", func(t *testing.T) {
+		for _, state := range []domain.SagaState{domain.SagaCompleted, domain.SagaAborted, domain.SagaCompensated} {
+			mockSagaRepo := new(mocks.SagaRepository)
+			mockOrderRepo := new(mocks.OrderRepository)
+			mockProductClient := new(mocks.ProductClient)
+			mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(domain.Saga{ID: 4, OrderID: 7, State: state}, nil).Once()
+
+			// A reply the saga already got must not confirm, fail or release the order again
+			sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+			err := sc.Resolve(context.TODO(), &domain.OrderReply{OrderID: 7, Accepted: state != domain.SagaAborted})
+
+			assert.NoError(t, err)
+			mockSagaRepo.AssertNotCalled(t, "UpdateState", mock.Anything, mock.Anything, mock.Anything)
+			mockSagaRepo.AssertNotCalled(t, "UpdateStateFrom", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockOrderRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, mock.Anything)
+		}
+	})
+}
+
+func TestSagaCoordinator_Cancel(t *testing.T) {
+	t.Run("completed", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).
+			Return(domain.Saga{ID: 4, OrderID: 7, State: domain.SagaCompleted}, nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensating).Return(nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensated).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Items: items, Status: domain.StatusCancelled}, nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Cancel(context.TODO(), 7)
+
+		assert.NoError(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).
+			Return(domain.Saga{ID: 4, OrderID: 7, State: domain.SagaRequested}, nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, new(mocks.OrderRepository), broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Cancel(context.TODO(), 7)
+
+		assert.NoError(t, err)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSagaCoordinator_Pay(t *testing.T) {
+	t.Run("completed", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).
+			Return(domain.Saga{ID: 4, OrderID: 7, State: domain.SagaCompleted}, nil).Once()
+		mockProductClient.On("CommitOrder", mock.Anything, uint32(7)).Return(domain.ErrReservationExpired).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, new(mocks.OrderRepository), broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Pay(context.TODO(), 7)
+
+		assert.Equal(t, domain.ErrReservationExpired, err)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).
+			Return(domain.Saga{ID: 4, OrderID: 7, State: domain.SagaReserved}, nil).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, new(mocks.OrderRepository), broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Pay(context.TODO(), 7)
+
+		assert.Equal(t, domain.ErrInvalidTransition, err)
+		mockProductClient.AssertNotCalled(t, "CommitOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("placed before sagas", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).Return(domain.Saga{}, domain.ErrNotFound).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, new(mocks.OrderRepository), broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Pay(context.TODO(), 7)
+
+		assert.NoError(t, err)
+		mockProductClient.AssertNotCalled(t, "CommitOrder", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSagaCoordinator_Resume(t *testing.T) {
+	mockSagaRepo := new(mocks.SagaRepository)
+	mockOrderRepo := new(mocks.OrderRepository)
+	mockProductClient := new(mocks.ProductClient)
+
+	sagas := []domain.Saga{
+		{ID: 1, OrderID: 11, State: domain.SagaStarted},
+		{ID: 2, OrderID: 12, State: domain.SagaRequested},
+		{ID: 3, OrderID: 13, State: domain.SagaReserved},
+		{ID: 4, OrderID: 14, State: domain.SagaCompensating},
+	}
+	mockSagaRepo.On("FetchInFlight", mock.Anything).Return(sagas, nil).Once()
+
+	// started: the reservation is requested again, for the items of the order when it was placed
+	mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(1), domain.SagaStarted, domain.SagaRequested).Return(nil).Once()
+	mockSagaRepo.On("GetItems", mock.Anything, uint32(1)).Return([]domain.StockItem{{ProductID: 3, Qty: 1}}, nil).Once()
+
+	// reserved: the order is confirmed
+	mockOrderRepo.On("GetByID", mock.Anything, uint32(13)).Return(domain.Order{ID: 13, Status: domain.StatusPending}, nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(13), domain.StatusPending, domain.StatusConfirmed).Return(nil).Once()
+	mockSagaRepo.On("UpdateState", mock.Anything, uint32(3), domain.SagaCompleted).Return(nil).Once()
+
+	// compensating: the stock held for the order is released
+	mockOrderRepo.On("GetByID", mock.Anything, uint32(14)).
+		Return(domain.Order{ID: 14, Items: []domain.OrderItem{{ProductID: 3, Qty: 4}}, Status: domain.StatusPending}, nil).Once()
+	mockProductClient.On("ReleaseOrder", mock.Anything, uint32(14)).Return(nil).Once()
+	mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensated).Return(nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(14), domain.StatusPending, domain.StatusFailed).Return(nil).Once()
+
+	b := broker.NewMemoryBroker()
+	events := consumeOrders(t, b)
+
+	sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, b, mockProductClient, time.Second*2)
+	err := sc.Resume(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.OrderCreated{{OrderID: 11, Items: []domain.StockItem{{ProductID: 3, Qty: 1}}}}, *events)
+	mockOrderRepo.AssertExpectations(t)
+	mockSagaRepo.AssertExpectations(t)
+	mockProductClient.AssertExpectations(t)
+	mockOrderRepo.AssertNotCalled(t, "GetByID", mock.Anything, uint32(11))
+}
+
+func TestSagaCoordinator_ExpireRequests(t *testing.T) {
+	mockSagaRepo := new(mocks.SagaRepository)
+	mockOrderRepo := new(mocks.OrderRepository)
+	mockProductClient := new(mocks.ProductClient)
+
+	sagas := []domain.Saga{
+		{ID: 1, OrderID: 11, State: domain.SagaRequested},
+		{ID: 2, OrderID: 12, State: domain.SagaRequested},
+	}
+	mockSagaRepo.On("FetchRequested", mock.Anything, mock.AnythingOfType("time.Time")).Return(sagas, nil).Once()
+
+	// expired: what the product service may hold is released and the order fails
+	mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(1), domain.SagaRequested, domain.SagaCompensating).Return(nil).Once()
+	mockProductClient.On("ReleaseOrder", mock.Anything, uint32(11)).Return(nil).Once()
+	mockSagaRepo.On("UpdateState", mock.Anything, uint32(1), domain.SagaCompensated).Return(nil).Once()
+	mockOrderRepo.On("GetByID", mock.Anything, uint32(11)).Return(domain.Order{ID: 11, Status: domain.StatusPending}, nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint32(11), domain.StatusPending, domain.StatusFailed).Return(nil).Once()
+
+	// replied meanwhile: left to the reply
+	mockSagaRepo.On("UpdateStateFrom", mock.Anything, uint32(2), domain.SagaRequested, domain.SagaCompensating).Return(domain.ErrSagaStateChanged).Once()
+
+	sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+	expired, err := sc.ExpireRequests(context.TODO(), time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	mockOrderRepo.AssertExpectations(t)
+	mockSagaRepo.AssertExpectations(t)
+	mockProductClient.AssertExpectations(t)
+	mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, uint32(12))
+}
+
+func TestSagaCoordinator_RetryStalled(t *testing.T) {
+	t.Run("release failed", func(t *testing.T) {
+		errRefused := errors.New("connection refused")
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("GetByOrderID", mock.Anything, uint32(7)).
+			Return(domain.Saga{ID: 4, OrderID: 7, State: domain.SagaCompleted}, nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensating).Return(nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(errRefused).Once()
+
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		err := sc.Cancel(context.TODO(), 7)
+		assert.Equal(t, errRefused, err)
+
+		// The next sweep gives the stock of the cancelled order back
+		mockSagaRepo.On("FetchStalled", mock.Anything, mock.AnythingOfType("time.Time")).
+			Return([]domain.Saga{{ID: 4, OrderID: 7, State: domain.SagaCompensating}}, nil).Once()
+		mockProductClient.On("ReleaseOrder", mock.Anything, uint32(7)).Return(nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompensated).Return(nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Status: domain.StatusCancelled}, nil).Once()
+
+		retried, err := sc.RetryStalled(context.TODO(), 30*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, retried)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertExpectations(t)
+		mockOrderRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("confirm not recorded", func(t *testing.T) {
+		mockSagaRepo := new(mocks.SagaRepository)
+		mockOrderRepo := new(mocks.OrderRepository)
+		mockProductClient := new(mocks.ProductClient)
+		mockSagaRepo.On("FetchStalled", mock.Anything, mock.AnythingOfType("time.Time")).
+			Return([]domain.Saga{{ID: 4, OrderID: 7, State: domain.SagaReserved}}, nil).Once()
+		mockOrderRepo.On("GetByID", mock.Anything, uint32(7)).Return(domain.Order{ID: 7, Status: domain.StatusConfirmed}, nil).Once()
+		mockSagaRepo.On("UpdateState", mock.Anything, uint32(4), domain.SagaCompleted).Return(nil).Once()
+
+		// The order was confirmed before the saga could record it, its stock stays held
+		sc := service.NewSagaCoordinator(mockSagaRepo, mockOrderRepo, broker.NewMemoryBroker(), mockProductClient, time.Second*2)
+		retried, err := sc.RetryStalled(context.TODO(), 30*time.Second)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, retried)
+		mockOrderRepo.AssertExpectations(t)
+		mockSagaRepo.AssertExpectations(t)
+		mockProductClient.AssertNotCalled(t, "ReleaseOrder", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRunSweeper(t *testing.T) {
+	mockSaga := new(mocks.SagaCoordinator)
+	retried := make(chan struct{}, 1)
+	mockSaga.On("ExpireRequests", mock.Anything, time.Minute).Return(0, errors.New("unexpected error"))
+	// A failed expiry does not keep the stalled sagas from being retried
+	mockSaga.On("RetryStalled", mock.Anything, time.Millisecond).Return(1, nil).Run(func(mock.Arguments) {
+		select {
+		case retried <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.RunSweeper(ctx, mockSaga, time.Minute, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("the sweeper did not retry the stalled sagas")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the sweeper did not stop once its context was done")
+	}
+}
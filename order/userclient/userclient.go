@@ -0,0 +1,191 @@
+package userclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"net/http"
+	"order/domain"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// sweepSize is the number of cached users from which the expired ones are dropped
+const sweepSize = 1024
+
+// StatusError is an answer of the user service that is none of the domain errors, a 5xx
+// unwraps to domain.ErrUserUnavailable
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("user service: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.StatusCode >= http.StatusInternalServerError {
+		return domain.ErrUserUnavailable
+	}
+	return nil
+}
+
+// UnavailableError is a call that never got an answer of the user service, which says
+// nothing about the user. It is domain.ErrUserUnavailable for errors.Is
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return "user service: " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == domain.ErrUserUnavailable
+}
+
+type Config struct {
+	// BaseURL is the root of the user API, like http://user:9090/api/v1/users
+	BaseURL string
+	// Timeout bounds every call
+	Timeout time.Duration
+	// CacheTTL is how long a user found is remembered, no user is cached when it is 0
+	CacheTTL  time.Duration
+	Transport http.RoundTripper
+}
+
+// NewConfig will read the user service settings from viper, the timeout and the cache
+// TTL are given in seconds
+func NewConfig() Config {
+	return Config{
+		BaseURL:  viper.GetString(`user.url`),
+		Timeout:  time.Duration(viper.GetInt(`user.timeout`)) * time.Second,
+		CacheTTL: time.Duration(viper.GetInt(`user.cachettl`)) * time.Second,
+	}
+}
+
+type entry struct {
+	user    domain.User
+	expires time.Time
+}
+
+type userClient struct {
+	config Config
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[uint32]entry
+}
+
+// NewUserClient will create a client for the user service API, a call times out after
+// 5 seconds unless the config says otherwise
+func NewUserClient(c Config) domain.UserClient {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &userClient{
+		config: c,
+		client: &http.Client{Transport: transport},
+		cache:  make(map[uint32]entry),
+	}
+}
+
+// GetUser will fetch the user with id. A user found is cached for the TTL, a missing
+// one is asked again every time so a user just created can order at once.
+func (uc *userClient) GetUser(c context.Context, id uint32) (user domain.User, err error) {
+	if user, ok := uc.cached(id); ok {
+		return user, nil
+	}
+
+	ctx, cancel := context.WithTimeout(c, uc.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%d", uc.config.BaseURL, id), nil)
+	if err != nil {
+		return
+	}
+	resp, err := uc.client.Do(req)
+	if err != nil {
+		return user, &UnavailableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.Unmarshal(body, &user)
+		if err == nil {
+			uc.store(user)
+		}
+	case http.StatusNotFound:
+		err = domain.ErrUserNotFound
+	default:
+		err = &StatusError{StatusCode: resp.StatusCode, Message: message(body)}
+	}
+	return
+}
+
+func (uc *userClient) cached(id uint32) (domain.User, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	e, ok := uc.cache[id]
+	if !ok {
+		return domain.User{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(uc.cache, id)
+		return domain.User{}, false
+	}
+	return e.user, true
+}
+
+func (uc *userClient) store(user domain.User) {
+	if uc.config.CacheTTL <= 0 {
+		return
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	now := time.Now()
+	if len(uc.cache) >= sweepSize {
+		for id, e := range uc.cache {
+			if now.After(e.expires) {
+				delete(uc.cache, id)
+			}
+		}
+	}
+	uc.cache[user.ID] = entry{user: user, expires: now.Add(uc.config.CacheTTL)}
+}
+
+// message will return the error of the user service, sent as {"err": "..."}
+func message(b []byte) string {
+	var body struct {
+		Err string `json:"err"`
+	}
+	if err := json.Unmarshal(b, &body); err == nil && body.Err != "" {
+		return body.Err
+	}
+	return string(bytes.TrimSpace(b))
+}
@@ -0,0 +1,120 @@
+package userclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"order/domain"
+	"order/userclient"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer will start a fake user service knowing the user 1, answering the others
+// like the user service does
+func newServer(requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/users/1":
+			w.Write([]byte(`{"id":1,"email":"john@example.com"}`))
+		case "/api/v1/users/3":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"err":"database is down"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"err":"sql: no rows in result set"}`))
+		}
+	}))
+}
+
+func TestUserClient_GetUser(t *testing.T) {
+	var requests int32
+	server := newServer(&requests)
+	defer server.Close()
+	uc := userclient.NewUserClient(userclient.Config{BaseURL: server.URL + "/api/v1/users"})
+
+	t.Run("success", func(t *testing.T) {
+		user, err := uc.GetUser(context.TODO(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.User{ID: 1, Email: "john@example.com"}, user)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := uc.GetUser(context.TODO(), 2)
+		assert.Equal(t, domain.ErrUserNotFound, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := uc.GetUser(context.TODO(), 3)
+		var statusErr *userclient.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+		assert.Equal(t, "database is down", statusErr.Message)
+		assert.True(t, errors.Is(err, domain.ErrUserUnavailable), "a 5xx says nothing about the user")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+
+		uc := userclient.NewUserClient(userclient.Config{BaseURL: down.URL})
+		_, err := uc.GetUser(context.TODO(), 1)
+		assert.True(t, errors.Is(err, domain.ErrUserUnavailable))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+		defer slow.Close()
+
+		uc := userclient.NewUserClient(userclient.Config{BaseURL: slow.URL, Timeout: 20 * time.Millisecond})
+		_, err := uc.GetUser(context.TODO(), 1)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.True(t, errors.Is(err, domain.ErrUserUnavailable))
+	})
+}
+
+func TestUserClient_Cache(t *testing.T) {
+	var requests int32
+	server := newServer(&requests)
+	defer server.Close()
+	uc := userclient.NewUserClient(userclient.Config{BaseURL: server.URL + "/api/v1/users", CacheTTL: 50 * time.Millisecond})
+
+	t.Run("hit", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		for i := 0; i < 3; i++ {
+			user, err := uc.GetUser(context.TODO(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, uint32(1), user.ID)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&requests, 0)
+		_, err := uc.GetUser(context.TODO(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("missing user not cached", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		for i := 0; i < 2; i++ {
+			_, err := uc.GetUser(context.TODO(), 2)
+			assert.Equal(t, domain.ErrUserNotFound, err)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+}
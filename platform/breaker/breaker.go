@@ -0,0 +1,187 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"platform/metrics"
+)
+
+type State int
+
+// A breaker is closed while the calls succeed. It opens after Threshold failures in a
+// row and rejects every call for Cooldown, then it is half-open: a single trial call
+// goes through, closing the breaker when it succeeds and opening it again otherwise.
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError is returned for a call rejected by an open breaker, RetryAfter tells when
+// the breaker lets a call through again
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker %s is open, retry in %s", e.Name, e.RetryAfter.Round(time.Second))
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+var (
+	state = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "State of the circuit breaker: 0 closed, 1 open, 2 half-open.",
+	}, []string{"name"})
+
+	rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "circuit_breaker_rejected_total",
+		Help: "Number of calls rejected by an open circuit breaker.",
+	}, []string{"name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(state, rejected)
+}
+
+type Config struct {
+	// Threshold is the number of failures in a row opening the breaker
+	Threshold int
+	// Cooldown is how long the breaker stays open before a trial call
+	Cooldown time.Duration
+}
+
+type Breaker struct {
+	name   string
+	config Config
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	// since is when the breaker opened, or when the trial call started once half-open
+	since time.Time
+	trial bool
+}
+
+// New will create a closed breaker, name tells the breakers apart in the metrics. It
+// opens after 5 failures for 30 seconds unless the config says otherwise.
+func New(name string, c Config) *Breaker {
+	if c.Threshold <= 0 {
+		c.Threshold = 5
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+
+	state.WithLabelValues(name).Set(float64(StateClosed))
+	return &Breaker{
+		name:   name,
+		config: c,
+	}
+}
+
+// Allow will tell whether a call may be made, an *OpenError is returned when it may
+// not. A call that is allowed must be reported with Success or Failure, unless it has
+// been given up by its caller.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state == StateOpen && now.Sub(b.since) >= b.config.Cooldown {
+		b.setState(StateHalfOpen)
+		b.trial = false
+	}
+
+	if b.state == StateHalfOpen {
+		// A trial call that never reported does not keep the breaker half-open forever
+		if !b.trial || now.Sub(b.since) >= b.config.Cooldown {
+			b.trial = true
+			b.since = now
+			return nil
+		}
+	}
+
+	if b.state == StateClosed {
+		return nil
+	}
+
+	rejected.WithLabelValues(b.name).Inc()
+	return &OpenError{Name: b.name, RetryAfter: b.config.Cooldown - now.Sub(b.since)}
+}
+
+// Success will report a call that succeeded, closing the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	b.setState(StateClosed)
+}
+
+// Failure will report a call that failed, the breaker opens after Threshold failures
+// in a row or when the trial call of a half-open breaker fails
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.config.Threshold {
+		b.trial = false
+		b.since = time.Now()
+		b.setState(StateOpen)
+	}
+}
+
+// State will return the state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && time.Since(b.since) >= b.config.Cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Check will report an open breaker as down, to be used as a readiness check
+func (b *Breaker) Check(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := time.Since(b.since); b.state == StateOpen && elapsed < b.config.Cooldown {
+		return &OpenError{Name: b.name, RetryAfter: b.config.Cooldown - elapsed}
+	}
+	return nil
+}
+
+// setState must be called with mu held
+func (b *Breaker) setState(s State) {
+	b.state = s
+	state.WithLabelValues(b.name).Set(float64(s))
+}
@@ -0,0 +1,104 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"platform/breaker"
+	"platform/metrics"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	b := breaker.New("test", breaker.Config{Threshold: 2, Cooldown: 50 * time.Millisecond})
+
+	t.Run("closed", func(t *testing.T) {
+		require.NoError(t, b.Allow())
+		b.Failure()
+		require.NoError(t, b.Allow())
+		b.Success()
+
+		// A success resets the failures in a row
+		require.NoError(t, b.Allow())
+		b.Failure()
+		assert.Equal(t, breaker.StateClosed, b.State())
+		assert.NoError(t, b.Check(context.TODO()))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		require.NoError(t, b.Allow())
+		b.Failure()
+		assert.Equal(t, breaker.StateOpen, b.State())
+
+		err := b.Allow()
+		var openErr *breaker.OpenError
+		require.True(t, errors.As(err, &openErr))
+		assert.True(t, errors.Is(err, breaker.ErrOpen))
+		assert.Equal(t, "test", openErr.Name)
+		assert.True(t, openErr.RetryAfter > 0 && openErr.RetryAfter <= 50*time.Millisecond)
+		assert.True(t, errors.Is(b.Check(context.TODO()), breaker.ErrOpen))
+	})
+
+	t.Run("half-open", func(t *testing.T) {
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, breaker.StateHalfOpen, b.State())
+		assert.NoError(t, b.Check(context.TODO()))
+
+		// A single trial call goes through, and its failure opens the breaker again
+		require.NoError(t, b.Allow())
+		assert.True(t, errors.Is(b.Allow(), breaker.ErrOpen))
+		b.Failure()
+		assert.Equal(t, breaker.StateOpen, b.State())
+
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, b.Allow())
+		b.Success()
+		assert.Equal(t, breaker.StateClosed, b.State())
+		assert.NoError(t, b.Allow())
+	})
+
+	t.Run("trial given up", func(t *testing.T) {
+		b := breaker.New("abandoned", breaker.Config{Threshold: 1, Cooldown: 20 * time.Millisecond})
+		require.NoError(t, b.Allow())
+		b.Failure()
+		time.Sleep(20 * time.Millisecond)
+
+		// The trial call never reports, another one is let through after the cooldown
+		require.NoError(t, b.Allow())
+		assert.Error(t, b.Allow())
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, b.Allow())
+	})
+}
+
+func TestBreaker_Metrics(t *testing.T) {
+	// The counters outlive the test, a name of its own keeps a rerun apart
+	name := fmt.Sprintf("metrics-%d", time.Now().UnixNano())
+	b := breaker.New(name, breaker.Config{Threshold: 1, Cooldown: time.Minute})
+	require.NoError(t, b.Allow())
+	b.Failure()
+	b.Allow()
+	b.Allow()
+
+	e := echo.New()
+	metrics.NewMetricsController(e)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), fmt.Sprintf(`circuit_breaker_state{name="%s"} 1`, name))
+	assert.Contains(t, string(body), fmt.Sprintf(`circuit_breaker_rejected_total{name="%s"} 2`, name))
+}
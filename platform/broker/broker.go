@@ -0,0 +1,48 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned when a message is published on a closed broker
+var ErrClosed = errors.New("broker is closed")
+
+// Handler process a single message delivered from a queue, a message it fails is dropped
+// unless the error asks for it to be delivered again with Requeue
+type Handler func(ctx context.Context, body []byte) error
+
+// RequeueError is the error of a handler that failed on something that may work later,
+// like its database or the broker being down, its message is delivered again
+type RequeueError struct {
+	Err error
+}
+
+func (e *RequeueError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequeueError) Unwrap() error {
+	return e.Err
+}
+
+// Requeue will wrap the error of a handler so that its message is delivered again
+func Requeue(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RequeueError{Err: err}
+}
+
+// IsRequeue reports whether a handler asked for its message to be delivered again
+func IsRequeue(err error) bool {
+	var requeue *RequeueError
+	return errors.As(err, &requeue)
+}
+
+// Broker represent the messaging layer used to exchange events between services
+type Broker interface {
+	Publish(ctx context.Context, queue string, body []byte) error
+	Consume(queue string, handler Handler) error
+	Close() error
+}
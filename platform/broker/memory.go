@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+type memoryBroker struct {
+	mu       sync.Mutex
+	closed   bool
+	handlers map[string]Handler
+	pending  map[string][][]byte
+}
+
+// NewMemoryBroker will create an in-process Broker, messages are handed to the
+// queue consumer synchronously and kept until a consumer is registered. A message is
+// delivered once, a handler asking for it to be requeued only gets its error logged.
+func NewMemoryBroker() Broker {
+	return &memoryBroker{
+		handlers: make(map[string]Handler),
+		pending:  make(map[string][][]byte),
+	}
+}
+
+func (m *memoryBroker) Publish(ctx context.Context, queue string, body []byte) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+
+	handler, ok := m.handlers[queue]
+	if !ok {
+		m.pending[queue] = append(m.pending[queue], body)
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	err := handler(ctx, body)
+	if err != nil {
+		log.Printf("broker: %s: %v", queue, err)
+	}
+	return nil
+}
+
+func (m *memoryBroker) Consume(queue string, handler Handler) error {
+	m.mu.Lock()
+	if _, ok := m.handlers[queue]; ok {
+		m.mu.Unlock()
+		return fmt.Errorf("queue %s already has a consumer", queue)
+	}
+
+	m.handlers[queue] = handler
+	pending := m.pending[queue]
+	delete(m.pending, queue)
+	m.mu.Unlock()
+
+	for _, body := range pending {
+		err := handler(context.Background(), body)
+		if err != nil {
+			log.Printf("broker: %s: %v", queue, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryBroker) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,68 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"platform/broker"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker_Publish(t *testing.T) {
+	b := broker.NewMemoryBroker()
+
+	var received []string
+	err := b.Consume("OrderQueue", func(ctx context.Context, body []byte) error {
+		received = append(received, string(body))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	err = b.Publish(context.TODO(), "OrderQueue", []byte("first"))
+	assert.NoError(t, err)
+	err = b.Publish(context.TODO(), "OrderQueue", []byte("second"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"first", "second"}, received)
+}
+
+func TestMemoryBroker_PendingUntilConsume(t *testing.T) {
+	b := broker.NewMemoryBroker()
+
+	err := b.Publish(context.TODO(), "OrderQueue", []byte("queued"))
+	assert.NoError(t, err)
+
+	var received []string
+	err = b.Consume("OrderQueue", func(ctx context.Context, body []byte) error {
+		received = append(received, string(body))
+		return errors.New("handler errors are not returned to the publisher")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"queued"}, received)
+
+	err = b.Consume("OrderQueue", func(ctx context.Context, body []byte) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestMemoryBroker_Close(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	assert.NoError(t, b.Close())
+
+	err := b.Publish(context.TODO(), "OrderQueue", []byte("late"))
+	assert.Equal(t, broker.ErrClosed, err)
+}
+
+func TestRequeue(t *testing.T) {
+	err := errors.New("database is down")
+
+	assert.Nil(t, broker.Requeue(nil))
+	assert.False(t, broker.IsRequeue(err))
+
+	requeue := broker.Requeue(err)
+	assert.True(t, broker.IsRequeue(requeue))
+	assert.True(t, broker.IsRequeue(fmt.Errorf("order 1: %w", requeue)))
+	assert.True(t, errors.Is(requeue, err))
+	assert.Equal(t, err.Error(), requeue.Error())
+}
@@ -0,0 +1,161 @@
+package broker
+
+import (
+	"context"
+	"log"
+	"platform/tracing"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// requeueDelay is the time a consumer waits before it hands back a message to deliver
+// again, so a handler failing on a database that is down does not spin on it
+const requeueDelay = time.Second
+
+type rabbitMQ struct {
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	ch        *amqp.Channel
+	consumers []consumer
+	closing   bool
+	inFlight  sync.WaitGroup
+}
+
+// consumer is a channel delivering the messages of one queue, tagged with the queue name
+type consumer struct {
+	ch  *amqp.Channel
+	tag string
+}
+
+// NewRabbitMQ will dial the AMQP server and return a Broker publishing to durable queues
+func NewRabbitMQ(url string) (Broker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &rabbitMQ{conn: conn, ch: ch}, nil
+}
+
+func declare(ch *amqp.Channel, queue string) error {
+	_, err := ch.QueueDeclare(queue, true, false, false, false, nil)
+	return err
+}
+
+func (r *rabbitMQ) Publish(ctx context.Context, queue string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := declare(r.ch, queue)
+	if err != nil {
+		return err
+	}
+
+	// The trace context travels in the headers, so the consumer continues the trace
+	headers := amqp.Table{}
+	_, span := tracing.StartPublish(ctx, "rabbitmq", queue, tracing.Headers(headers))
+	err = r.ch.Publish("", queue, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	tracing.End(span, err)
+	return err
+}
+
+func (r *rabbitMQ) Consume(queue string, handler Handler) error {
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	err = declare(ch, queue)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+
+	msgs, err := ch.Consume(queue, queue, false, false, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+
+	r.mu.Lock()
+	r.consumers = append(r.consumers, consumer{ch: ch, tag: queue})
+	r.inFlight.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.inFlight.Done()
+		for d := range msgs {
+			if r.isClosing() {
+				// Delivered before the consumer was cancelled, leave it for the next run
+				d.Nack(false, true)
+				continue
+			}
+
+			ctx, span := tracing.StartConsume(context.Background(), "rabbitmq", queue, tracing.Headers(d.Headers))
+			err := handler(ctx, d.Body)
+			tracing.End(span, err)
+			if IsRequeue(err) {
+				log.Printf("broker: %s: requeue: %v", queue, err)
+				time.Sleep(requeueDelay)
+				d.Nack(false, true)
+				continue
+			}
+			if err != nil {
+				log.Printf("broker: %s: %v", queue, err)
+				d.Nack(false, false)
+				continue
+			}
+			d.Ack(false)
+		}
+	}()
+
+	return nil
+}
+
+func (r *rabbitMQ) isClosing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closing
+}
+
+// Close will stop the consumers and wait for the messages they are handling before the
+// connection is closed, so no message is left half processed
+func (r *rabbitMQ) Close() error {
+	r.mu.Lock()
+	r.closing = true
+	consumers := r.consumers
+	r.mu.Unlock()
+
+	for _, c := range consumers {
+		// Cancelling ends the deliveries, so the consumer goroutine returns
+		if err := c.ch.Cancel(c.tag, false); err != nil {
+			log.Printf("broker: cancel %s: %v", c.tag, err)
+		}
+	}
+	r.inFlight.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range consumers {
+		c.ch.Close()
+	}
+	err := r.ch.Close()
+	if err != nil {
+		return err
+	}
+	return r.conn.Close()
+}
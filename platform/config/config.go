@@ -0,0 +1,37 @@
+package config
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Load will read the service configuration from path into viper
+func Load(path string) (err error) {
+	viper.SetConfigFile(path)
+	err = viper.ReadInConfig()
+	if err != nil {
+		return
+	}
+
+	if viper.GetBool(`debug`) {
+		log.Println("Service RUN on DEBUG mode")
+	}
+	return
+}
+
+// ContextTimeout will return how long the service layer may take to handle a request
+func ContextTimeout() time.Duration {
+	return time.Duration(viper.GetInt("context.timeout")) * time.Second
+}
+
+// ShutdownTimeout will return how long in-flight requests may take to finish once the
+// service is asked to stop, ten seconds when it is not configured
+func ShutdownTimeout() time.Duration {
+	timeout := viper.GetInt("shutdown.timeout")
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return time.Duration(timeout) * time.Second
+}
@@ -0,0 +1,30 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"platform/config"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := ioutil.WriteFile(path, []byte(`{"server": {"address": ":9090"}, "context": {"timeout": 2}}`), 0600)
+	require.NoError(t, err)
+
+	err = config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, ":9090", viper.GetString("server.address"))
+	assert.Equal(t, 2*time.Second, config.ContextTimeout())
+	assert.Equal(t, 10*time.Second, config.ShutdownTimeout(), "the default drain timeout")
+
+	t.Run("missing file", func(t *testing.T) {
+		err := config.Load(filepath.Join(t.TempDir(), "config.json"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"net"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+const defaultLocation = "Asia/Jakarta"
+
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Pass     string
+	Name     string
+	Location string
+
+	// Pool settings, zero keeps the database/sql default
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+}
+
+// NewConfig will read the database settings of the service from viper, the connection
+// idle time and lifetime are given in minutes
+func NewConfig() Config {
+	return Config{
+		Host:            viper.GetString(`database.host`),
+		Port:            viper.GetString(`database.port`),
+		User:            viper.GetString(`database.user`),
+		Pass:            viper.GetString(`database.pass`),
+		Name:            viper.GetString(`database.name`),
+		Location:        viper.GetString(`database.location`),
+		MaxIdleConns:    viper.GetInt(`database.maxidleconns`),
+		MaxOpenConns:    viper.GetInt(`database.maxopenconns`),
+		ConnMaxIdleTime: time.Duration(viper.GetInt(`database.connmaxidletime`)) * time.Minute,
+		ConnMaxLifetime: time.Duration(viper.GetInt(`database.connmaxlifetime`)) * time.Minute,
+	}
+}
+
+// DSN will build the MySQL data source name, the credentials are escaped by the driver
+// so a password may hold any character
+func (c Config) DSN() (dsn string, err error) {
+	location := c.Location
+	if location == "" {
+		location = defaultLocation
+	}
+	loc, err := time.LoadLocation(location)
+	if err != nil {
+		return
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Pass
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(c.Host, c.Port)
+	cfg.DBName = c.Name
+	cfg.ParseTime = true
+	cfg.Loc = loc
+	return cfg.FormatDSN(), nil
+}
+
+// Open will connect to the database and make sure it can be reached. Every statement
+// run within a traced context is recorded as a span of the trace.
+func Open(c Config) (db *sql.DB, err error) {
+	dsn, err := c.DSN()
+	if err != nil {
+		return
+	}
+
+	db, err = otelsql.Open(`mysql`, dsn,
+		otelsql.WithAttributes(semconv.DBSystemMySQL, semconv.DBNameKey.String(c.Name)),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{DisableErrSkip: true}),
+	)
+	if err != nil {
+		return
+	}
+	c.Apply(db)
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return
+}
+
+// Apply will size the connection pool of db
+func (c Config) Apply(db *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+}
@@ -0,0 +1,96 @@
+package database_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"platform/database"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_DSN(t *testing.T) {
+	c := database.Config{
+		Host: "order-db",
+		Port: "3306",
+		User: "root",
+		Pass: "p@ss/w:rd?",
+		Name: "orders",
+	}
+
+	dsn, err := c.DSN()
+	require.NoError(t, err)
+
+	parsed, err := mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "root", parsed.User)
+	assert.Equal(t, "p@ss/w:rd?", parsed.Passwd)
+	assert.Equal(t, "order-db:3306", parsed.Addr)
+	assert.Equal(t, "orders", parsed.DBName)
+	assert.True(t, parsed.ParseTime)
+	assert.Equal(t, "Asia/Jakarta", parsed.Loc.String())
+
+	t.Run("unknown location", func(t *testing.T) {
+		c.Location = "Mars/Olympus_Mons"
+		_, err := c.DSN()
+		assert.Error(t, err)
+	})
+}
+
+func TestConfig_Apply(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	c := database.Config{MaxOpenConns: 7, MaxIdleConns: 3, ConnMaxIdleTime: time.Minute, ConnMaxLifetime: time.Hour}
+	c.Apply(db)
+	assert.Equal(t, 7, db.Stats().MaxOpenConnections)
+}
+
+func TestStatsController(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	database.Config{MaxOpenConns: 1}.Apply(db)
+	mock.MatchExpectationsInOrder(false)
+
+	// Hold the only connection, so the next query has to wait for it
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var one int
+		db.QueryRow("SELECT 1").Scan(&one)
+	}()
+	require.Eventually(t, func() bool {
+		return db.Stats().WaitCount == 1
+	}, time.Second, time.Millisecond)
+
+	e := echo.New()
+	database.NewStatsController(e, db)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/metrics/db", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var stats database.Stats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.MaxOpenConnections)
+	assert.Equal(t, 1, stats.OpenConnections)
+	assert.Equal(t, 1, stats.InUse)
+	assert.Equal(t, 0, stats.Idle)
+	assert.Equal(t, int64(1), stats.WaitCount)
+
+	mock.ExpectRollback()
+	require.NoError(t, tx.Rollback())
+	<-done
+}
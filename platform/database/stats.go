@@ -0,0 +1,51 @@
+package database
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Stats is the state of the connection pool, used to size it from real traffic
+type Stats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitDurationMs     float64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+}
+
+func NewStats(s sql.DBStats) Stats {
+	return Stats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     float64(s.WaitDuration.Microseconds()) / 1000,
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
+
+type StatsController struct {
+	DB *sql.DB
+}
+
+// NewStatsController will expose the connection pool stats of db on /metrics/db
+func NewStatsController(e *echo.Echo, db *sql.DB) {
+	controller := &StatsController{
+		DB: db,
+	}
+	e.GET("/metrics/db", controller.Stats)
+}
+
+func (sc *StatsController) Stats(c echo.Context) error {
+	return c.JSON(http.StatusOK, NewStats(sc.DB.Stats()))
+}
@@ -0,0 +1,19 @@
+module platform
+
+go 1.16
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/XSAM/otelsql v0.14.1
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/labstack/echo/v4 v4.2.2
+	github.com/prometheus/client_golang v1.11.1
+	github.com/spf13/viper v1.7.1
+	github.com/streadway/amqp v1.0.0
+	github.com/stretchr/testify v1.7.1
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+)
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// checkTimeout bounds a single dependency check, so a hanging dependency can not hang /readyz
+const checkTimeout = 2 * time.Second
+
+// Checker probes a dependency of the service, it returns nil when the dependency can be used
+type Checker func(ctx context.Context) error
+
+// Check is a dependency probed by /readyz, an optional one is reported without making the
+// service unready when it is down, the service still answers the requests not needing it
+type Check struct {
+	Name     string
+	Checker  Checker
+	Optional bool
+}
+
+// Result is the outcome of a single dependency check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Optional  bool    `json:"optional,omitempty"`
+}
+
+// Report is the body of a /readyz response
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type HealthController struct {
+	Checks []Check
+}
+
+// NewHealthController will register /healthz, answering as long as the process runs, and
+// /readyz, answering 200 only when every check that is not optional passes
+func NewHealthController(e *echo.Echo, checks ...Check) {
+	controller := &HealthController{
+		Checks: checks,
+	}
+	e.GET("/healthz", controller.Live)
+	e.GET("/readyz", controller.Ready)
+}
+
+func (hc *HealthController) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": StatusUp,
+	})
+}
+
+func (hc *HealthController) Ready(c echo.Context) error {
+	report := Run(c.Request().Context(), hc.Checks...)
+	if report.Status != StatusUp {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// Run will run the checks concurrently, the report is up only when all of them pass but
+// the optional ones
+func Run(ctx context.Context, checks ...Check) Report {
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]Result, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Checker(checkCtx)
+			result := Result{
+				Status:    StatusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Optional:  check.Optional,
+			}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if err != nil && !check.Optional {
+				report.Status = StatusDown
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// DB will check that the database answers a ping
+func DB(db *sql.DB) Checker {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// HTTP will check that url answers a GET without a server error
+func HTTP(client *http.Client, url string) Checker {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s answered %d", url, res.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,143 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"platform/health"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func up(ctx context.Context) error {
+	return nil
+}
+
+func down(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthController(t *testing.T) {
+	t.Run("live", func(t *testing.T) {
+		e := echo.New()
+		health.NewHealthController(e, health.Check{Name: "database", Checker: down})
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code, "liveness does not depend on the dependencies")
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		e := echo.New()
+		health.NewHealthController(e, health.Check{Name: "database", Checker: up})
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, health.StatusUp, report.Status)
+		assert.Equal(t, health.StatusUp, report.Checks["database"].Status)
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		e := echo.New()
+		health.NewHealthController(e,
+			health.Check{Name: "database", Checker: up},
+			health.Check{Name: "product", Checker: down},
+		)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, health.StatusUp, report.Checks["database"].Status)
+		assert.Equal(t, health.StatusDown, report.Checks["product"].Status)
+		assert.Equal(t, "connection refused", report.Checks["product"].Error)
+	})
+}
+
+func TestRun(t *testing.T) {
+	slow := func(ctx context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+
+	start := time.Now()
+	report := health.Run(context.TODO(),
+		health.Check{Name: "a", Checker: slow},
+		health.Check{Name: "b", Checker: slow},
+		health.Check{Name: "c", Checker: slow},
+	)
+	assert.Less(t, int64(time.Since(start)), int64(140*time.Millisecond), "checks run concurrently")
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Len(t, report.Checks, 3)
+	assert.GreaterOrEqual(t, report.Checks["a"].LatencyMs, float64(50))
+
+	t.Run("hanging dependency", func(t *testing.T) {
+		hang := func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		report := health.Run(ctx, health.Check{Name: "hang", Checker: hang})
+		assert.Equal(t, health.StatusDown, report.Status)
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		down := func(ctx context.Context) error {
+			return errors.New("circuit breaker is open")
+		}
+
+		// A dependency only some requests need is reported without making the service unready
+		report := health.Run(context.TODO(),
+			health.Check{Name: "database", Checker: slow},
+			health.Check{Name: "breaker", Checker: down, Optional: true},
+		)
+		assert.Equal(t, health.StatusUp, report.Status)
+		assert.Equal(t, health.Result{Status: health.StatusDown, LatencyMs: report.Checks["breaker"].LatencyMs, Error: "circuit breaker is open", Optional: true}, report.Checks["breaker"])
+	})
+}
+
+func TestDB(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPing()
+	assert.NoError(t, health.DB(db)(context.TODO()))
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	assert.Error(t, health.DB(db)(context.TODO()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHTTP(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	check := health.HTTP(ts.Client(), ts.URL+"/healthz")
+	assert.NoError(t, check(context.TODO()))
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, check(context.TODO()))
+
+	ts.Close()
+	assert.Error(t, check(context.TODO()))
+}
@@ -0,0 +1,229 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// The settings used when the config leaves them at zero
+const (
+	DefaultLockTimeout   = time.Minute
+	DefaultRetention     = 24 * time.Hour
+	DefaultPurgeInterval = time.Hour
+)
+
+var (
+	ErrNotFound      = errors.New("Idempotency key not found")
+	ErrKeyExists     = errors.New("Idempotency key already exists")
+	ErrKeyReused     = errors.New("Idempotency-Key has already been used for another request")
+	ErrKeyInProgress = errors.New("A request with this Idempotency-Key is still in progress")
+)
+
+// Record is the response stored for a request sent with an Idempotency-Key header,
+// StatusCode stays zero while the first request is still being handled
+type Record struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Repository interface {
+	GetByKey(ctx context.Context, key string) (Record, error)
+	Store(ctx context.Context, record *Record) error
+	Update(ctx context.Context, record *Record) error
+	Delete(ctx context.Context, key string) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Config struct {
+	// LockTimeout is how long a key stays claimed by a request whose response was never
+	// stored, a crashed one, a retry after it takes the key over
+	LockTimeout time.Duration
+	// Retention is how long a key is kept, a retry after it is handled as a new request
+	Retention time.Duration
+	// PurgeInterval is the time between two purges of the keys past their retention
+	PurgeInterval time.Duration
+}
+
+// NewConfig will read the idempotency settings of the service from viper, the lock
+// timeout is given in seconds, the retention in hours and the purge interval in minutes
+func NewConfig() Config {
+	return Config{
+		LockTimeout:   time.Duration(viper.GetInt(`idempotency.locktimeout`)) * time.Second,
+		Retention:     time.Duration(viper.GetInt(`idempotency.retention`)) * time.Hour,
+		PurgeInterval: time.Duration(viper.GetInt(`idempotency.purgeinterval`)) * time.Minute,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	if c.LockTimeout <= 0 {
+		c.LockTimeout = DefaultLockTimeout
+	}
+	if c.Retention <= 0 {
+		c.Retention = DefaultRetention
+	}
+	if c.PurgeInterval <= 0 {
+		c.PurgeInterval = DefaultPurgeInterval
+	}
+	return c
+}
+
+// RunPurger will delete the keys past their retention every purge interval, until ctx
+// is done
+func RunPurger(ctx context.Context, repo Repository, c Config) {
+	c = c.withDefaults()
+	ticker := time.NewTicker(c.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := repo.Purge(ctx, time.Now().Add(-c.Retention))
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("purged %d idempotency keys", purged)
+			}
+		}
+	}
+}
+
+// Middleware will handle a POST request sent with an Idempotency-Key header only once. A
+// retry with the same key and body gets the stored response back, a retry with the same
+// key and another body is rejected with 422. Server errors are not stored, so a request
+// that failed that way can be retried with the same key.
+func Middleware(repo Repository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			req := c.Request()
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if key == "" || req.Method != http.MethodPost {
+				return next(c)
+			}
+
+			if len(key) > 255 {
+				return c.JSON(http.StatusBadRequest, echo.Map{
+					"err": HeaderIdempotencyKey + " must not be longer than 255 characters",
+				})
+			}
+
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{
+					"err": err.Error(),
+				})
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			record := &Record{
+				Key:         key,
+				RequestHash: hash(req.Method, req.URL.Path, body),
+			}
+			err = repo.Store(req.Context(), record)
+			if err == ErrKeyExists {
+				return replay(c, repo, record)
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{
+					"err": err.Error(),
+				})
+			}
+
+			res := c.Response()
+			rec := &recorder{ResponseWriter: res.Writer}
+			res.Writer = rec
+
+			err = next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			// The response is already sent, so it is saved even when the client went away
+			ctx := context.Background()
+			if !res.Committed || res.Status >= http.StatusInternalServerError {
+				if errDelete := repo.Delete(ctx, key); errDelete != nil {
+					log.Printf("idempotency key %q: %v", key, errDelete)
+				}
+				return nil
+			}
+
+			record.StatusCode = res.Status
+			record.ContentType = res.Header().Get(echo.HeaderContentType)
+			record.Body = rec.body.Bytes()
+			if errUpdate := repo.Update(ctx, record); errUpdate != nil {
+				log.Printf("idempotency key %q: %v", key, errUpdate)
+			}
+			return nil
+		}
+	}
+}
+
+func replay(c echo.Context, repo Repository, record *Record) error {
+	stored, err := repo.GetByKey(c.Request().Context(), record.Key)
+	if err == ErrNotFound {
+		// The first request failed and released the key in the meantime
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err": ErrKeyInProgress.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": ErrKeyReused.Error(),
+		})
+	}
+	if stored.StatusCode == 0 {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err": ErrKeyInProgress.Error(),
+		})
+	}
+
+	if stored.ContentType == "" {
+		return c.NoContent(stored.StatusCode)
+	}
+	return c.Blob(stored.StatusCode, stored.ContentType, stored.Body)
+}
+
+// hash will fingerprint the request, so a key sent again with another request can be told apart
+func hash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder will keep a copy of the response body while it is written to the client
+type recorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,233 @@
+package idempotency_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"platform/idempotency"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockRepository is a testify mock of idempotency.Repository
+type mockRepository struct {
+	mock.Mock
+}
+
+func (_m *mockRepository) GetByKey(ctx context.Context, key string) (idempotency.Record, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 idempotency.Record
+	if rf, ok := ret.Get(0).(func(context.Context, string) idempotency.Record); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(idempotency.Record)
+	}
+	return r0, ret.Error(1)
+}
+
+func (_m *mockRepository) Store(ctx context.Context, record *idempotency.Record) error {
+	ret := _m.Called(ctx, record)
+	return ret.Error(0)
+}
+
+func (_m *mockRepository) Update(ctx context.Context, record *idempotency.Record) error {
+	ret := _m.Called(ctx, record)
+	return ret.Error(0)
+}
+
+func (_m *mockRepository) Delete(ctx context.Context, key string) error {
+	ret := _m.Called(ctx, key)
+	return ret.Error(0)
+}
+
+func (_m *mockRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ret := _m.Called(ctx, before)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+// newServer will serve POST /products/order/:id with a handler counting how often it runs
+func newServer(repo idempotency.Repository, status int, calls *int) *echo.Echo {
+	e := echo.New()
+	e.Use(idempotency.Middleware(repo))
+	e.POST("/products/order/:id", func(c echo.Context) error {
+		*calls++
+		if status == http.StatusNoContent {
+			return c.NoContent(status)
+		}
+		return c.JSON(status, echo.Map{"name": "Laptop Lenovo"})
+	})
+	return e
+}
+
+func post(e *echo.Echo, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(echo.POST, "/products/order/1", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(idempotency.HeaderIdempotencyKey, key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	body := `{"qty":2}`
+
+	t.Run("without key", func(t *testing.T) {
+		repo := new(mockRepository)
+		calls := 0
+		e := newServer(repo, http.StatusCreated, &calls)
+
+		post(e, "", body)
+		post(e, "", body)
+		assert.Equal(t, 2, calls)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("first request", func(t *testing.T) {
+		repo := new(mockRepository)
+		repo.On("Store", mock.Anything, mock.MatchedBy(func(k *idempotency.Record) bool {
+			return k.Key == "abc" && len(k.RequestHash) == 64 && k.StatusCode == 0
+		})).Return(nil).Once()
+		repo.On("Update", mock.Anything, mock.MatchedBy(func(k *idempotency.Record) bool {
+			return k.StatusCode == http.StatusCreated &&
+				strings.HasPrefix(k.ContentType, echo.MIMEApplicationJSON) &&
+				strings.TrimSpace(string(k.Body)) == `{"name":"Laptop Lenovo"}`
+		})).Return(nil).Once()
+		calls := 0
+		e := newServer(repo, http.StatusCreated, &calls)
+
+		rec := post(e, "abc", body)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, 1, calls)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		var stored *idempotency.Record
+		repo := new(mockRepository)
+		repo.On("Store", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*idempotency.Record)
+		}).Return(nil).Once()
+		repo.On("Update", mock.Anything, mock.Anything).Return(nil).Once()
+		repo.On("Store", mock.Anything, mock.Anything).Return(idempotency.ErrKeyExists)
+		repo.On("GetByKey", mock.Anything, "abc").Return(func(ctx context.Context, key string) idempotency.Record {
+			return *stored
+		}, nil)
+		calls := 0
+		e := newServer(repo, http.StatusCreated, &calls)
+
+		first := post(e, "abc", body)
+		second := post(e, "abc", body)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, first.Code, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, first.Header().Get(echo.HeaderContentType), second.Header().Get(echo.HeaderContentType))
+
+		mismatch := post(e, "abc", `{"qty":5}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("replay without content", func(t *testing.T) {
+		var stored *idempotency.Record
+		repo := new(mockRepository)
+		repo.On("Store", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*idempotency.Record)
+		}).Return(nil).Once()
+		repo.On("Update", mock.Anything, mock.Anything).Return(nil).Once()
+		repo.On("Store", mock.Anything, mock.Anything).Return(idempotency.ErrKeyExists)
+		repo.On("GetByKey", mock.Anything, "abc").Return(func(ctx context.Context, key string) idempotency.Record {
+			return *stored
+		}, nil)
+		calls := 0
+		e := newServer(repo, http.StatusNoContent, &calls)
+
+		post(e, "abc", body)
+		rec := post(e, "abc", body)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		var hash string
+		repo := new(mockRepository)
+		repo.On("Store", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			hash = args.Get(1).(*idempotency.Record).RequestHash
+		}).Return(idempotency.ErrKeyExists).Once()
+		repo.On("GetByKey", mock.Anything, "abc").Return(func(ctx context.Context, key string) idempotency.Record {
+			return idempotency.Record{Key: key, RequestHash: hash}
+		}, nil).Once()
+		calls := 0
+		e := newServer(repo, http.StatusCreated, &calls)
+
+		rec := post(e, "abc", body)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, 0, calls)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		repo := new(mockRepository)
+		repo.On("Store", mock.Anything, mock.Anything).Return(nil).Once()
+		repo.On("Delete", mock.Anything, "abc").Return(nil).Once()
+		calls := 0
+		e := newServer(repo, http.StatusInternalServerError, &calls)
+
+		rec := post(e, "abc", body)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		repo := new(mockRepository)
+		repo.On("Store", mock.Anything, mock.Anything).Return(errors.New("unexpected error")).Once()
+		calls := 0
+		e := newServer(repo, http.StatusCreated, &calls)
+
+		rec := post(e, "abc", body)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, 0, calls)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestRunPurger(t *testing.T) {
+	repo := new(mockRepository)
+	purged := make(chan time.Time, 1)
+	repo.On("Purge", mock.Anything, mock.AnythingOfType("time.Time")).Return(int64(3), nil).Run(func(args mock.Arguments) {
+		select {
+		case purged <- args.Get(1).(time.Time):
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		idempotency.RunPurger(ctx, repo, idempotency.Config{Retention: time.Hour, PurgeInterval: time.Millisecond})
+		close(done)
+	}()
+
+	select {
+	case before := <-purged:
+		assert.WithinDuration(t, time.Now().Add(-time.Hour), before, time.Second)
+	case <-time.After(time.Second):
+		t.Fatal("the purger did not purge the keys")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the purger did not stop once its context was done")
+	}
+}
@@ -0,0 +1,150 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDuplicateEntry is the MySQL error number of a primary key that already exists
+const errDuplicateEntry = 1062
+
+type repository struct {
+	Conn        *sql.DB
+	lockTimeout time.Duration
+}
+
+// NewRepository will keep the records in the idempotency table of the service database,
+// a key left in progress longer than the lock timeout of c can be claimed again
+func NewRepository(db *sql.DB, c Config) Repository {
+	return &repository{Conn: db, lockTimeout: c.withDefaults().LockTimeout}
+}
+
+func (r *repository) GetByKey(ctx context.Context, key string) (record Record, err error) {
+	query := `SELECT idempotency_key, request_hash, status_code, content_type, body, created_at, updated_at FROM idempotency WHERE idempotency_key=?`
+
+	stmt, err := r.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return Record{}, err
+	}
+
+	row := stmt.QueryRowContext(ctx, key)
+	record = Record{}
+
+	err = row.Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.Body,
+		&record.CreatedAt,
+		&record.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+	return
+}
+
+// Store will claim the key for a new request, ErrKeyExists is returned when it was already
+// claimed. A key still in progress past the lock timeout belongs to a request that will
+// never answer, it is taken over instead.
+func (r *repository) Store(ctx context.Context, record *Record) (err error) {
+	query := `INSERT INTO idempotency (idempotency_key, request_hash, status_code, content_type, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := r.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	_, err = stmt.ExecContext(ctx, record.Key, record.RequestHash, record.StatusCode, record.ContentType, record.Body, ts, ts)
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == errDuplicateEntry {
+		err = r.takeOver(ctx, record, t)
+	}
+	if err != nil {
+		return
+	}
+
+	record.CreatedAt = t
+	record.UpdatedAt = t
+	return
+}
+
+// takeOver will claim a key left in progress past the lock timeout, at most one request
+// gets the row as the update checks it is still stale
+func (r *repository) takeOver(ctx context.Context, record *Record, t time.Time) (err error) {
+	query := `UPDATE idempotency SET request_hash=?, created_at=?, updated_at=? WHERE idempotency_key=? AND status_code=0 AND updated_at<?`
+
+	ts := t.Format("2006-01-02 15:04:05")
+	stale := t.Add(-r.lockTimeout).Format("2006-01-02 15:04:05")
+	result, err := r.Conn.ExecContext(ctx, query, record.RequestHash, ts, ts, record.Key, stale)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected != 1 {
+		return ErrKeyExists
+	}
+	return
+}
+
+// Update will save the response of the request that claimed the key
+func (r *repository) Update(ctx context.Context, record *Record) (err error) {
+	query := `UPDATE idempotency SET status_code=?, content_type=?, body=?, updated_at=? WHERE idempotency_key=?`
+
+	stmt, err := r.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	result, err := stmt.ExecContext(ctx, record.StatusCode, record.ContentType, record.Body, ts, record.Key)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected != 1 {
+		err = fmt.Errorf("total affected: %d", rowsAffected)
+		return
+	}
+
+	record.UpdatedAt = t
+	return
+}
+
+// Delete will release the key so the request can be tried again
+func (r *repository) Delete(ctx context.Context, key string) (err error) {
+	query := `DELETE FROM idempotency WHERE idempotency_key=?`
+
+	stmt, err := r.Conn.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+
+	_, err = stmt.ExecContext(ctx, key)
+	return
+}
+
+// Purge will delete the keys last updated before the time given, stale locks included
+func (r *repository) Purge(ctx context.Context, before time.Time) (purged int64, err error) {
+	query := `DELETE FROM idempotency WHERE updated_at<?`
+
+	result, err := r.Conn.ExecContext(ctx, query, before.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return
+	}
+	return result.RowsAffected()
+}
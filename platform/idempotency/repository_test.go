@@ -0,0 +1,189 @@
+package idempotency_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"platform/idempotency"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMock will open a stub database, the expectations are set on the returned sqlmock
+func newMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	return db, mock
+}
+
+var idempotencyKey = &idempotency.Record{
+	Key:         "4b7f2c1e",
+	RequestHash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	StatusCode:  201,
+	ContentType: "application/json; charset=UTF-8",
+	Body:        []byte(`{"name":"Laptop Lenovo"}`),
+}
+
+func TestRepository_GetByKey(t *testing.T) {
+	db, mock := newMock(t)
+	repo := idempotency.NewRepository(db, idempotency.Config{LockTimeout: time.Minute})
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT idempotency_key, request_hash, status_code, content_type, body, created_at, updated_at FROM idempotency WHERE idempotency_key=?`)
+	columns := []string{"idempotency_key", "request_hash", "status_code", "content_type", "body", "created_at", "updated_at"}
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow(idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.StatusCode, idempotencyKey.ContentType, idempotencyKey.Body, idempotencyKey.CreatedAt, idempotencyKey.UpdatedAt)
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(idempotencyKey.Key).WillReturnRows(rows)
+
+		record, err := repo.GetByKey(context.TODO(), idempotencyKey.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, idempotencyKey.Body, record.Body)
+		assert.Equal(t, idempotencyKey.StatusCode, record.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs("unknown").WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.GetByKey(context.TODO(), "unknown")
+		assert.Equal(t, idempotency.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Store(t *testing.T) {
+	db, mock := newMock(t)
+	repo := idempotency.NewRepository(db, idempotency.Config{LockTimeout: time.Minute})
+	defer func() {
+		db.Close()
+	}()
+
+	takeOverQuery := regexp.QuoteMeta(`UPDATE idempotency SET request_hash=?, created_at=?, updated_at=? WHERE idempotency_key=? AND status_code=0 AND updated_at<?`)
+	query := regexp.QuoteMeta(`INSERT INTO idempotency (idempotency_key, request_hash, status_code, content_type, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	record := &idempotency.Record{Key: idempotencyKey.Key, RequestHash: idempotencyKey.RequestHash}
+
+	t.Run("success", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().
+			WithArgs(record.Key, record.RequestHash, 0, "", []byte(nil), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Store(context.TODO(), record)
+		assert.NoError(t, err)
+		assert.False(t, record.CreatedAt.IsZero())
+	})
+
+	t.Run("key exists", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().
+			WithArgs(record.Key, record.RequestHash, 0, "", []byte(nil), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+		mock.ExpectExec(takeOverQuery).
+			WithArgs(record.RequestHash, sqlmock.AnyArg(), sqlmock.AnyArg(), record.Key, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Store(context.TODO(), record)
+		assert.Equal(t, idempotency.ErrKeyExists, err)
+	})
+
+	t.Run("stale lock", func(t *testing.T) {
+		// The request holding the key never answered, a retry past the lock timeout gets it
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().
+			WithArgs(record.Key, record.RequestHash, 0, "", []byte(nil), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+		mock.ExpectExec(takeOverQuery).
+			WithArgs(record.RequestHash, sqlmock.AnyArg(), sqlmock.AnyArg(), record.Key, staleBefore{time.Minute}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Store(context.TODO(), record)
+		assert.NoError(t, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Update(t *testing.T) {
+	db, mock := newMock(t)
+	repo := idempotency.NewRepository(db, idempotency.Config{LockTimeout: time.Minute})
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`UPDATE idempotency SET status_code=?, content_type=?, body=?, updated_at=? WHERE idempotency_key=?`)
+
+	prep := mock.ExpectPrepare(query)
+	prep.ExpectExec().
+		WithArgs(idempotencyKey.StatusCode, idempotencyKey.ContentType, idempotencyKey.Body, sqlmock.AnyArg(), idempotencyKey.Key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Update(context.TODO(), idempotencyKey)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Delete(t *testing.T) {
+	db, mock := newMock(t)
+	repo := idempotency.NewRepository(db, idempotency.Config{LockTimeout: time.Minute})
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`DELETE FROM idempotency WHERE idempotency_key=?`)
+
+	prep := mock.ExpectPrepare(query)
+	prep.ExpectExec().WithArgs(idempotencyKey.Key).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Delete(context.TODO(), idempotencyKey.Key)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Purge(t *testing.T) {
+	db, mock := newMock(t)
+	repo := idempotency.NewRepository(db, idempotency.Config{})
+	defer func() {
+		db.Close()
+	}()
+
+	before := time.Now().Add(-24 * time.Hour)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency WHERE updated_at<?`)).
+		WithArgs(before.Format("2006-01-02 15:04:05")).WillReturnResult(sqlmock.NewResult(0, 4))
+
+	purged, err := repo.Purge(context.TODO(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// staleBefore matches the time a key must have been left at to be stale, the lock
+// timeout before now
+type staleBefore struct {
+	lockTimeout time.Duration
+}
+
+func (s staleBefore) Match(v driver.Value) bool {
+	ts, ok := v.(string)
+	if !ok {
+		return false
+	}
+	stale, err := time.ParseInLocation("2006-01-02 15:04:05", ts, time.Local)
+	if err != nil {
+		return false
+	}
+	want := time.Now().Add(-s.lockTimeout)
+	return stale.After(want.Add(-2*time.Second)) && stale.Before(want.Add(2*time.Second))
+}
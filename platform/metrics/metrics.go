@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"platform/middleware"
+)
+
+// Registry holds every metric of the service, the domain metrics of a service are
+// registered with it next to the ones defined here
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests served.",
+	}, []string{"route", "method", "status"})
+
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests served.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests,
+		duration,
+	)
+}
+
+// Middleware will count the requests and observe their latency, labelled with the
+// route template so /products/:id is a single series whatever the id
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			route := middleware.Route(c)
+			status := strconv.Itoa(c.Response().Status)
+			method := c.Request().Method
+
+			requests.WithLabelValues(route, method, status).Inc()
+			duration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+			return nil
+		}
+	}
+}
+
+// DB will export the connection pool stats of db, name tells the databases apart
+func DB(db *sql.DB, name string) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db, name)
+}
+
+type MetricsController struct {
+	Handler http.Handler
+}
+
+// NewMetricsController will register collectors and expose Registry on /metrics in the
+// Prometheus exposition format
+func NewMetricsController(e *echo.Echo, cs ...prometheus.Collector) {
+	Registry.MustRegister(cs...)
+
+	controller := &MetricsController{
+		Handler: promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}),
+	}
+	e.GET("/metrics", controller.Metrics)
+}
+
+func (mc *MetricsController) Metrics(c echo.Context) error {
+	mc.Handler.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
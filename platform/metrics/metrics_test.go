@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"platform/metrics"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, url string) string {
+	resp, err := http.Get(url + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	widgets := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "widgets_total",
+		Help: "Number of widgets.",
+	})
+
+	e := echo.New()
+	e.Use(metrics.Middleware())
+	e.GET("/products/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return echo.NewHTTPError(http.StatusNotFound, "Record Not Found")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	metrics.NewMetricsController(e, metrics.DB(db, "products"), widgets)
+	widgets.Add(2)
+
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	for _, path := range []string{"/products/1", "/products/2", "/products/0", "/missing"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	body := scrape(t, server.URL)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/products/:id",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/products/:id",status="404"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/products/:id",status="200"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/products/:id",status="200",le="+Inf"} 2`)
+	assert.Contains(t, body, `go_sql_max_open_connections{db_name="products"} 0`)
+	assert.Contains(t, body, `widgets_total 2`)
+	assert.Contains(t, body, `go_goroutines`)
+
+	// The scrape itself is counted by the next one
+	body = scrape(t, server.URL)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/metrics",status="200"} 1`)
+}
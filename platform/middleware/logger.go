@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// accessLog is the line written for every request
+type accessLog struct {
+	Time      string  `json:"time"`
+	RequestID string  `json:"request_id"`
+	TraceID   string  `json:"trace_id,omitempty"`
+	RemoteIP  string  `json:"remote_ip"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	BytesIn   string  `json:"bytes_in"`
+	BytesOut  int64   `json:"bytes_out"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Logger will write one JSON line to out for every request once it has been handled
+func Logger(out io.Writer) echo.MiddlewareFunc {
+	var mu sync.Mutex
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response, so its status is logged
+				c.Error(err)
+			}
+
+			req := c.Request()
+			res := c.Response()
+			line := accessLog{
+				Time:      start.Format(time.RFC3339),
+				RequestID: RequestIDFromContext(req.Context()),
+				RemoteIP:  c.RealIP(),
+				Method:    req.Method,
+				URI:       req.RequestURI,
+				Status:    res.Status,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				BytesIn:   req.Header.Get(echo.HeaderContentLength),
+				BytesOut:  res.Size,
+			}
+			if sc := trace.SpanContextFromContext(req.Context()); sc.HasTraceID() {
+				line.TraceID = sc.TraceID().String()
+			}
+			if err != nil {
+				line.Error = err.Error()
+			}
+
+			b, errMarshal := json.Marshal(line)
+			if errMarshal != nil {
+				log.Println(errMarshal)
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if _, errWrite := out.Write(append(b, '\n')); errWrite != nil {
+				log.Println(errWrite)
+			}
+			return nil
+		}
+	}
+}
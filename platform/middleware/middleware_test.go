@@ -0,0 +1,154 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"platform/middleware"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestID(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, middleware.RequestIDFromContext(c.Request().Context()))
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/", nil))
+
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		assert.Len(t, id, 32)
+		assert.Equal(t, id, rec.Body.String())
+	})
+
+	t.Run("forwarded", func(t *testing.T) {
+		req := httptest.NewRequest(echo.GET, "/", nil)
+		req.Header.Set(echo.HeaderXRequestID, "from-nginx")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, "from-nginx", rec.Header().Get(echo.HeaderXRequestID))
+		assert.Equal(t, "from-nginx", rec.Body.String())
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(middleware.Logger(&out))
+	e.GET("/products/:id", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, echo.Map{"id": c.Param("id")})
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return errors.New("unexpected error")
+	})
+
+	req := httptest.NewRequest(echo.GET, "/products/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/fail", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &line))
+	assert.Equal(t, "abc", line["request_id"])
+	assert.Equal(t, "GET", line["method"])
+	assert.Equal(t, "/products/1", line["uri"])
+	assert.Equal(t, float64(http.StatusOK), line["status"])
+
+	require.NoError(t, json.Unmarshal(lines[1], &line))
+	assert.Equal(t, float64(http.StatusInternalServerError), line["status"])
+	assert.Equal(t, "unexpected error", line["error"])
+}
+
+func TestLogger_TraceID(t *testing.T) {
+	var out bytes.Buffer
+	e := echo.New()
+	e.Use(middleware.Logger(&out))
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+	req := httptest.NewRequest(echo.GET, "/", nil)
+	e.ServeHTTP(httptest.NewRecorder(), req.WithContext(trace.ContextWithSpanContext(req.Context(), sc)))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(echo.GET, "/", nil))
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &line))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+
+	line = nil
+	require.NoError(t, json.Unmarshal(lines[1], &line))
+	assert.NotContains(t, line, "trace_id")
+}
+
+func TestRoute(t *testing.T) {
+	var route string
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route = middleware.Route(c)
+			return next(c)
+		}
+	})
+	e.GET("/products/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(echo.GET, "/products/1", nil))
+	assert.Equal(t, "/products/:id", route)
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(echo.GET, "/missing", nil))
+	assert.Equal(t, middleware.RouteUnmatched, route)
+}
+
+func TestRecover(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.Recover())
+	e.GET("/", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"err":"Internal Server Error"}`, rec.Body.String())
+}
+
+func TestCORS(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.CORS())
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(echo.GET, "/", nil)
+	req.Header.Set(echo.HeaderOrigin, "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, "*", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, echo.HeaderXRequestID, rec.Header().Get(echo.HeaderAccessControlExposeHeaders))
+}
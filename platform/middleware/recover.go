@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Recover will turn a panic in a handler into a 500 response, the panic and its stack
+// are logged with the request ID
+func Recover() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				log.Printf("request %s: panic: %v\n%s", RequestIDFromContext(c.Request().Context()), r, debug.Stack())
+				err = c.JSON(http.StatusInternalServerError, echo.Map{
+					"err": http.StatusText(http.StatusInternalServerError),
+				})
+			}()
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/labstack/echo/v4"
+)
+
+type requestIDKey struct{}
+
+// RequestID will give every request an ID, an X-Request-ID sent by the client or nginx is
+// kept so the request can be followed across services. The ID is sent back in the
+// response and can be read from the request context with RequestIDFromContext.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if id == "" || len(id) > 128 {
+				id = newRequestID()
+				req.Header.Set(echo.HeaderXRequestID, id)
+			}
+
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id)))
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			return next(c)
+		}
+	}
+}
+
+// RequestIDFromContext will return the ID of the request ctx belongs to
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"reflect"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RouteUnmatched is the route of the requests that match no route
+const RouteUnmatched = "unmatched"
+
+// Route will return the path template of the route c matched, so /products/:id is a
+// single route whatever the id. Echo leaves the request path as the route when none
+// matches, its handler is then the one answering 404.
+func Route(c echo.Context) string {
+	route := c.Path()
+	if route == "" || isNotFound(c.Handler()) {
+		return RouteUnmatched
+	}
+	return route
+}
+
+func isNotFound(h echo.HandlerFunc) bool {
+	return reflect.ValueOf(h).Pointer() == reflect.ValueOf(echo.NotFoundHandler).Pointer()
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Command will run `migrate up`, `migrate down [steps]` or `migrate status` and report
+// the outcome to out
+func Command(ctx context.Context, m *Migrator, args []string, out io.Writer) (err error) {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "no migration to apply")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: steps must be a positive number")
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return err
+	}
+
+	return fmt.Errorf("migrate: unknown command %q", args[0])
+}
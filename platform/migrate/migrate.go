@@ -0,0 +1,303 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLocked = errors.New("migrate: another process holds the migration lock")
+	ErrDirty  = errors.New("migrate: the database has a migration this binary does not know")
+)
+
+// fileName matches migration files like 0001_create_product.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema, read from a pair of files such as
+// 0001_create_product.up.sql and 0001_create_product.down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db          *sql.DB
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// New will read the migrations of fsys, ordered by version, to apply them on db
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		LockTimeout: 30 * time.Second,
+	}, nil
+}
+
+// Load will read the migrations at the root of fsys, every version needs an up file
+func Load(fsys fs.FS) (migrations []Migration, err error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: %d_%s has no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return
+}
+
+// Up will apply every migration that has not been applied yet, oldest first
+func (m *Migrator) Up(ctx context.Context) (applied []Migration, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err = m.checkKnown(done); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			err = exec(ctx, conn, migration.Up)
+			if err != nil {
+				return fmt.Errorf("migrate: %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+
+			t := time.Now()
+			ts := t.Format("2006-01-02 15:04:05")
+			_, err = conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`, migration.Version, migration.Name, ts)
+			if err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Down will revert the last steps applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []Migration, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err = m.checkKnown(done); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migrate: %d_%s has no down migration", migration.Version, migration.Name)
+			}
+
+			err = exec(ctx, conn, migration.Down)
+			if err != nil {
+				return fmt.Errorf("migrate: %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version=?`, migration.Version)
+			if err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Status will list every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) (statuses []Status, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			appliedAt, ok := done[migration.Version]
+			statuses = append(statuses, Status{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return m.checkKnown(done)
+	})
+	return
+}
+
+// locked will run fn on a single connection holding a named lock of the database, so two
+// replicas starting at once do not apply the same migration twice
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(CONCAT(DATABASE(), '.schema_migrations'), ?)`, int(m.LockTimeout.Seconds())).Scan(&got)
+	if err != nil {
+		return
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return ErrLocked
+	}
+	defer func() {
+		var released sql.NullInt64
+		errRelease := conn.QueryRowContext(context.Background(), `SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.schema_migrations'))`).Scan(&released)
+		if err == nil {
+			err = errRelease
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return
+	}
+
+	return fn(conn)
+}
+
+// checkKnown will refuse to migrate a database that a newer binary has migrated further
+func (m *Migrator) checkKnown(done map[int64]time.Time) error {
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range done {
+		if !known[version] {
+			return fmt.Errorf("%w: version %d", ErrDirty, version)
+		}
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (done map[int64]time.Time, err error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	done = make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// exec will run the statements of a migration one by one, a statement ends with a
+// semicolon at the end of a line, so the DSN does not need multiStatements
+func exec(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, statement := range split(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func split(script string) (statements []string) {
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return
+}
@@ -0,0 +1,196 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"platform/migrate"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	lockQuery    = regexp.QuoteMeta(`SELECT GET_LOCK(CONCAT(DATABASE(), '.schema_migrations'), ?)`)
+	releaseQuery = regexp.QuoteMeta(`SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.schema_migrations'))`)
+	tableQuery   = regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)
+	appliedQuery = regexp.QuoteMeta(`SELECT version, applied_at FROM schema_migrations`)
+	insertQuery  = regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`)
+	deleteQuery  = regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version=?`)
+)
+
+var migrations = fstest.MapFS{
+	"0001_create_product.up.sql": {Data: []byte(`-- the products on sale
+CREATE TABLE IF NOT EXISTS product (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL
+);
+`)},
+	"0001_create_product.down.sql": {Data: []byte("DROP TABLE IF EXISTS product;\n")},
+	"0002_create_stock_movement.up.sql": {Data: []byte(`CREATE TABLE IF NOT EXISTS stock_movement (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY
+);
+CREATE INDEX stock_movement_product ON stock_movement (id);
+`)},
+	"0002_create_stock_movement.down.sql": {Data: []byte("DROP TABLE IF EXISTS stock_movement;\n")},
+	"README.md":                           {Data: []byte("not a migration")},
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(lockQuery).WithArgs(30).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec(tableQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectRelease(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(releaseQuery).WillReturnRows(sqlmock.NewRows([]string{"release"}).AddRow(1))
+}
+
+func TestLoad(t *testing.T) {
+	list, err := migrate.Load(migrations)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, int64(1), list[0].Version)
+	assert.Equal(t, "create_product", list[0].Name)
+	assert.Equal(t, int64(2), list[1].Version)
+	assert.Contains(t, list[1].Down, "DROP TABLE")
+
+	t.Run("missing up", func(t *testing.T) {
+		_, err := migrate.Load(fstest.MapFS{
+			"0001_create_product.down.sql": {Data: []byte("DROP TABLE product;")},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("version used twice", func(t *testing.T) {
+		_, err := migrate.Load(fstest.MapFS{
+			"0001_create_product.up.sql": {Data: []byte("CREATE TABLE product (id INT);")},
+			"0001_create_order.up.sql":   {Data: []byte("CREATE TABLE `order` (id INT);")},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestMigrator_Up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(appliedQuery).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS stock_movement (\n\tid INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY\n)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX stock_movement_product ON stock_movement (id)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(insertQuery).WithArgs(int64(2), "create_stock_movement", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(mock)
+
+	applied, err := m.Up(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, int64(2), applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_Failure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(appliedQuery).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS product")).WillReturnError(errors.New("access denied"))
+	expectRelease(mock)
+
+	applied, err := m.Up(context.TODO())
+	assert.Error(t, err)
+	assert.Len(t, applied, 0, "a failed migration is not recorded")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Locked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations)
+	require.NoError(t, err)
+	m.LockTimeout = time.Second
+
+	// GET_LOCK answers 0 when another replica kept the lock for the whole timeout
+	mock.ExpectQuery(lockQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+
+	_, err = m.Up(context.TODO())
+	assert.Equal(t, migrate.ErrLocked, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(appliedQuery).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE IF EXISTS stock_movement")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(deleteQuery).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(mock)
+
+	reverted, err := m.Down(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, "create_stock_movement", reverted[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Dirty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(appliedQuery).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(3, time.Now()))
+	expectRelease(mock)
+
+	_, err = m.Up(context.TODO())
+	assert.True(t, errors.Is(err, migrate.ErrDirty))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCommand(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations)
+	require.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(appliedQuery).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)))
+	expectRelease(mock)
+
+	var out bytes.Buffer
+	err = migrate.Command(context.TODO(), m, []string{"status"}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "0001_create_product")
+	assert.Contains(t, out.String(), "2021-03-01T10:00:00Z")
+	assert.Regexp(t, `0002_create_stock_movement\s+pending`, out.String())
+
+	assert.Error(t, migrate.Command(context.TODO(), m, []string{"sideways"}, &out))
+	assert.Error(t, migrate.Command(context.TODO(), m, []string{"down", "zero"}, &out))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
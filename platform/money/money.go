@@ -0,0 +1,112 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currencies do not match")
+	ErrOverflow         = errors.New("amount overflows")
+)
+
+// minorUnits is the number of decimals of the minor unit of the ISO-4217 currencies
+// the services accept, e.g. 100 IDR is 10000 in sen
+var minorUnits = map[string]int{
+	"AUD": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"IDR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"MYR": 2,
+	"SGD": 2,
+	"USD": 2,
+}
+
+// MinorUnits will return the number of decimals of the minor unit of currency
+func MinorUnits(currency string) (int, bool) {
+	n, ok := minorUnits[currency]
+	return n, ok
+}
+
+// Money is an Amount in the minor unit of an ISO-4217 Currency, the zero value has no
+// currency and is not valid
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New will create an amount of currency given in its minor unit
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Validate will fail with ErrUnknownCurrency for a currency that is not supported
+func (m Money) Validate() error {
+	if _, ok := minorUnits[m.Currency]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, m.Currency)
+	}
+	return nil
+}
+
+// Add will return m+o, both must be in the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Mul will return m times n, e.g. the price of n units
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	product := m.Amount * n
+	if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Sum will add up amounts of the same currency, the sum of no amounts is the zero value
+func Sum(amounts ...Money) (sum Money, err error) {
+	if len(amounts) == 0 {
+		return
+	}
+
+	sum = Money{Currency: amounts[0].Currency}
+	for _, m := range amounts {
+		sum, err = sum.Add(m)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+	return
+}
+
+// String will format the amount in the major unit, like IDR 7000.00
+func (m Money) String() string {
+	decimals, ok := minorUnits[m.Currency]
+	if !ok || decimals == 0 {
+		return fmt.Sprintf("%s %d", m.Currency, m.Amount)
+	}
+
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = uint64(-(m.Amount + 1)) + 1
+	}
+	unit := uint64(math.Pow10(decimals))
+	minor := fmt.Sprintf("%d", amount%unit)
+	return fmt.Sprintf("%s %s%d.%s%s", m.Currency, sign, amount/unit, strings.Repeat("0", decimals-len(minor)), minor)
+}
@@ -0,0 +1,76 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"platform/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney_Validate(t *testing.T) {
+	assert.NoError(t, money.New(700000, "IDR").Validate())
+	assert.True(t, errors.Is(money.New(100, "XXX").Validate(), money.ErrUnknownCurrency))
+	assert.True(t, errors.Is(money.Money{}.Validate(), money.ErrUnknownCurrency))
+}
+
+func TestMoney_Add(t *testing.T) {
+	sum, err := money.New(500, "IDR").Add(money.New(250, "IDR"))
+	require.NoError(t, err)
+	assert.Equal(t, money.New(750, "IDR"), sum)
+
+	_, err = money.New(500, "IDR").Add(money.New(250, "USD"))
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch))
+
+	_, err = money.New(math.MaxInt64, "IDR").Add(money.New(1, "IDR"))
+	assert.Equal(t, money.ErrOverflow, err)
+	_, err = money.New(math.MinInt64, "IDR").Add(money.New(-1, "IDR"))
+	assert.Equal(t, money.ErrOverflow, err)
+}
+
+func TestMoney_Mul(t *testing.T) {
+	product, err := money.New(500, "IDR").Mul(3)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1500, "IDR"), product)
+
+	product, err = money.New(500, "IDR").Mul(0)
+	require.NoError(t, err)
+	assert.Equal(t, money.New(0, "IDR"), product)
+
+	_, err = money.New(math.MaxInt64/2+1, "IDR").Mul(2)
+	assert.Equal(t, money.ErrOverflow, err)
+	_, err = money.New(-1, "IDR").Mul(math.MinInt64)
+	assert.Equal(t, money.ErrOverflow, err)
+}
+
+func TestSum(t *testing.T) {
+	sum, err := money.Sum(money.New(500, "IDR"), money.New(250, "IDR"), money.New(50, "IDR"))
+	require.NoError(t, err)
+	assert.Equal(t, money.New(800, "IDR"), sum)
+
+	sum, err = money.Sum()
+	require.NoError(t, err)
+	assert.Equal(t, money.Money{}, sum)
+
+	_, err = money.Sum(money.New(500, "IDR"), money.New(250, "USD"))
+	assert.True(t, errors.Is(err, money.ErrCurrencyMismatch))
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "IDR 7000.05", money.New(700005, "IDR").String())
+	assert.Equal(t, "USD -0.50", money.New(-50, "USD").String())
+	assert.Equal(t, "JPY 1200", money.New(1200, "JPY").String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	b, err := json.Marshal(money.New(700000, "IDR"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":700000,"currency":"IDR"}`, string(b))
+
+	var m money.Money
+	require.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, money.New(700000, "IDR"), m)
+}
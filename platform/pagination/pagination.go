@@ -0,0 +1,160 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// TimeLayout formats the sort value of a time column in a cursor
+const TimeLayout = "2006-01-02 15:04:05"
+
+var (
+	ErrInvalidLimit  = fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Query asks for the page of a list following the After cursor, or the first page. Sort
+// is the column the list is ordered by, rows with the same sort value are ordered by id.
+type Query struct {
+	Limit int
+	Sort  string
+	Desc  bool
+	After *Cursor
+}
+
+// Cursor is the position of the last row of a page, Sort is the order it was taken in
+// so it can not be used with another one
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    uint32 `json:"id"`
+}
+
+// Page is the envelope of every list, NextCursor is empty on the last page
+type Page struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+// Parse will read the ?limit=&sort=&cursor= of a list request. The sort is id or one of
+// sorts, prefixed with a - for the descending order, the limit is 20 when not given.
+func Parse(c echo.Context, sorts ...string) (q Query, err error) {
+	q.Limit = DefaultLimit
+	if limit := c.QueryParam("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 1 || q.Limit > MaxLimit {
+			return Query{}, ErrInvalidLimit
+		}
+	}
+
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "id"
+	}
+	q.Sort = strings.TrimPrefix(sort, "-")
+	q.Desc = q.Sort != sort
+	if q.Sort != "id" && !contains(sorts, q.Sort) {
+		return Query{}, fmt.Errorf("%w: %q", ErrInvalidSort, sort)
+	}
+
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		after, err := decode(cursor)
+		if err != nil || after.Sort != q.order() {
+			return Query{}, ErrInvalidCursor
+		}
+		q.After = &after
+	}
+	return
+}
+
+// Keyset will return the condition selecting the rows after the cursor, empty on the
+// first page, and the ORDER BY and LIMIT clauses. One row more than the limit is asked
+// for, telling whether a next page follows.
+func (q Query) Keyset() (cond string, args []interface{}, orderBy string) {
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if q.Sort == "id" {
+		orderBy = fmt.Sprintf(" ORDER BY id %s LIMIT %d", dir, q.Limit+1)
+		if q.After != nil {
+			cond = "id " + op + " ?"
+			args = []interface{}{q.After.ID}
+		}
+		return
+	}
+
+	orderBy = fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", q.Sort, dir, dir, q.Limit+1)
+	if q.After != nil {
+		cond = fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", q.Sort, op, q.Sort, op)
+		args = []interface{}{q.After.Value, q.After.Value, q.After.ID}
+	}
+	return
+}
+
+// More will tell whether the n rows fetched go beyond the page, the rows past the limit
+// are then dropped and the last row kept gives the next cursor
+func (q Query) More(n int) bool {
+	return n > q.Limit
+}
+
+// Cursor will encode the position of a row from its sort value and id
+func (q Query) Cursor(value string, id uint32) string {
+	if q.Sort == "id" {
+		value = ""
+	}
+	b, _ := json.Marshal(Cursor{Sort: q.order(), Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Where will join the conditions of a query, the empty ones are left out
+func Where(conds ...string) string {
+	kept := make([]string, 0, len(conds))
+	for _, cond := range conds {
+		if cond != "" {
+			kept = append(kept, cond)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(kept, " AND ")
+}
+
+func (q Query) order() string {
+	if q.Desc {
+		return "-" + q.Sort
+	}
+	return q.Sort
+}
+
+func decode(s string) (c Cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &c)
+	return
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
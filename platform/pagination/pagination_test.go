@@ -0,0 +1,99 @@
+package pagination_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"platform/pagination"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, target string, sorts ...string) (pagination.Query, error) {
+	t.Helper()
+	c := echo.New().NewContext(httptest.NewRequest(echo.GET, target, nil), httptest.NewRecorder())
+	return pagination.Parse(c, sorts...)
+}
+
+func TestParse(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		q, err := parse(t, "/users")
+		require.NoError(t, err)
+		assert.Equal(t, pagination.Query{Limit: pagination.DefaultLimit, Sort: "id"}, q)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		q, err := parse(t, "/users?limit=5&sort=-created_at", "created_at")
+		require.NoError(t, err)
+		assert.Equal(t, pagination.Query{Limit: 5, Sort: "created_at", Desc: true}, q)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			target string
+			err    error
+		}{
+			{"/users?limit=0", pagination.ErrInvalidLimit},
+			{"/users?limit=101", pagination.ErrInvalidLimit},
+			{"/users?limit=ten", pagination.ErrInvalidLimit},
+			{"/users?sort=password", pagination.ErrInvalidSort},
+			{"/users?cursor=bm90LWpzb24", pagination.ErrInvalidCursor},
+		}
+
+		for _, tt := range tests {
+			_, err := parse(t, tt.target, "created_at")
+			assert.True(t, errors.Is(err, tt.err), tt.target)
+		}
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		first, err := parse(t, "/users?sort=-created_at", "created_at")
+		require.NoError(t, err)
+		cursor := first.Cursor("2021-05-01 10:00:00", 7)
+
+		q, err := parse(t, "/users?sort=-created_at&cursor="+cursor, "created_at")
+		require.NoError(t, err)
+		assert.Equal(t, &pagination.Cursor{Sort: "-created_at", Value: "2021-05-01 10:00:00", ID: 7}, q.After)
+
+		// A cursor only follows the order it was taken in
+		_, err = parse(t, "/users?sort=created_at&cursor="+cursor, "created_at")
+		assert.Equal(t, pagination.ErrInvalidCursor, err)
+	})
+}
+
+func TestQuery_Keyset(t *testing.T) {
+	t.Run("first page", func(t *testing.T) {
+		cond, args, orderBy := pagination.Query{Limit: 10, Sort: "id"}.Keyset()
+		assert.Empty(t, cond)
+		assert.Empty(t, args)
+		assert.Equal(t, " ORDER BY id ASC LIMIT 11", orderBy)
+	})
+
+	t.Run("by id", func(t *testing.T) {
+		q := pagination.Query{Limit: 10, Sort: "id", After: &pagination.Cursor{Sort: "id", ID: 7}}
+		cond, args, _ := q.Keyset()
+		assert.Equal(t, "id > ?", cond)
+		assert.Equal(t, []interface{}{uint32(7)}, args)
+	})
+
+	t.Run("by column", func(t *testing.T) {
+		q := pagination.Query{Limit: 10, Sort: "email", Desc: true, After: &pagination.Cursor{Sort: "-email", Value: "b@mail.com", ID: 7}}
+		cond, args, orderBy := q.Keyset()
+		assert.Equal(t, "(email < ? OR (email = ? AND id < ?))", cond)
+		assert.Equal(t, []interface{}{"b@mail.com", "b@mail.com", uint32(7)}, args)
+		assert.Equal(t, " ORDER BY email DESC, id DESC LIMIT 11", orderBy)
+	})
+}
+
+func TestQuery_More(t *testing.T) {
+	q := pagination.Query{Limit: 2, Sort: "id"}
+	assert.False(t, q.More(2))
+	assert.True(t, q.More(3))
+}
+
+func TestWhere(t *testing.T) {
+	assert.Equal(t, "", pagination.Where("", ""))
+	assert.Equal(t, " WHERE email = ? AND id > ?", pagination.Where("email = ?", "", "id > ?"))
+}
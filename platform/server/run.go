@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Run will serve e on address until the process gets SIGINT or SIGTERM. It then stops
+// accepting connections and gives the requests in flight up to drain to finish, so
+// what the handlers use, like the database, can be closed once Run has returned.
+func Run(e *echo.Echo, address string, drain time.Duration) (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- e.Start(address)
+	}()
+
+	select {
+	case err = <-errc:
+		// The server did not even start, e.g. the address is already in use
+		return
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the process right away
+	stop()
+	log.Printf("shutting down, draining requests for up to %s", drain)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	err = e.Shutdown(shutdownCtx)
+	if err != nil {
+		return
+	}
+
+	err = <-errc
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,135 @@
+//go:build !windows
+// +build !windows
+
+package server_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"platform/server"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestRun(t *testing.T) {
+	address := freeAddress(t)
+	started := make(chan struct{})
+	finished := make(chan struct{})
+
+	e := server.New()
+	e.GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		close(finished)
+		return c.String(http.StatusOK, "done")
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(e, address, 5*time.Second)
+	}()
+
+	// Wait until the server takes connections
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	resc := make(chan response, 1)
+	go func() {
+		res, err := http.Get("http://" + address + "/slow")
+		if err != nil {
+			resc <- response{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		resc <- response{status: res.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-runErr:
+		// Run must only return once the slow request has been answered
+		assert.NoError(t, err)
+		select {
+		case <-finished:
+		default:
+			t.Fatal("Run returned before the request in flight finished")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after SIGTERM")
+	}
+
+	res := <-resc
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body)
+
+	// New connections are refused once the server is down
+	_, err := net.Dial("tcp", address)
+	assert.Error(t, err)
+}
+
+func TestRun_DrainTimeout(t *testing.T) {
+	address := freeAddress(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	e := server.New()
+	e.GET("/stuck", func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(e, address, 100*time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	go http.Get("http://" + address + "/stuck")
+	<-started
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-runErr:
+		assert.Error(t, err, "a request outliving the drain timeout must be reported")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not give up after the drain timeout")
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"os"
+
+	"github.com/labstack/echo/v4"
+
+	"platform/metrics"
+	"platform/middleware"
+	"platform/tracing"
+)
+
+// New will create the Echo instance of a service with the shared middleware. The order
+// matters: the request ID and the trace come first so every log line has them, the
+// metrics and the logger wrap the recovery so a panic is counted and logged as a 500,
+// and CORS answers preflight requests last.
+func New() *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+
+	e.Use(middleware.RequestID())
+	e.Use(tracing.Middleware())
+	e.Use(metrics.Middleware())
+	e.Use(middleware.Logger(os.Stdout))
+	e.Use(middleware.Recover())
+	e.Use(middleware.CORS())
+
+	return e
+}
@@ -0,0 +1,28 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"platform/server"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	e := server.New()
+	e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(echo.GET, "/panic", nil)
+	req.Header.Set(echo.HeaderOrigin, "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	// The panic is answered with a 500 that still carries the request ID and CORS headers
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+	assert.Equal(t, "*", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers carries the trace context in the headers of a message, an amqp.Table can be
+// converted to it
+type Headers map[string]interface{}
+
+func (h Headers) Get(key string) string {
+	v, _ := h[key].(string)
+	return v
+}
+
+func (h Headers) Set(key, value string) {
+	h[key] = value
+}
+
+func (h Headers) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// StartPublish will start the span of a message sent to queue and put its trace
+// context in headers
+func StartPublish(ctx context.Context, system, queue string, headers Headers) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(ctx, queue+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationKindQueue,
+			semconv.MessagingDestinationKey.String(queue),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+	return ctx, span
+}
+
+// StartConsume will start the span handling a message of queue, the child of the span
+// that published it
+func StartConsume(ctx context.Context, system, queue string, headers Headers) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headers)
+	return tracer().Start(ctx, queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationKindQueue,
+			semconv.MessagingDestinationKey.String(queue),
+			semconv.MessagingOperationProcess,
+		),
+	)
+}
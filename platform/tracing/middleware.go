@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"platform/middleware"
+)
+
+// attributeRequestID ties a span to the access log line of its request
+var attributeRequestID = attribute.Key("http.request_id")
+
+// Middleware will trace every request as a server span, the child of the
+// span of the caller when the request carries a traceparent header. The request
+// context holds the span, so the calls made while handling it are its children.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := middleware.Route(c)
+
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := tracer().Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, req)...),
+			)
+			defer span.End()
+			if id := middleware.RequestIDFromContext(ctx); id != "" {
+				span.SetAttributes(attributeRequestID.String(id))
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+				span.RecordError(err)
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+			return nil
+		}
+	}
+}
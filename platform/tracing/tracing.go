@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exporters the spans can be sent to
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+)
+
+// instrumentation is the name of the tracer every span of the platform is started with
+const instrumentation = "platform/tracing"
+
+var ErrUnknownExporter = errors.New("unknown tracing exporter")
+
+// Memory keeps the spans of the memory exporter, so tests can assert on them
+var Memory = tracetest.NewInMemoryExporter()
+
+type Config struct {
+	Exporter string
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint string
+	Insecure bool
+}
+
+// NewConfig will read the tracing settings of the service from viper
+func NewConfig() Config {
+	return Config{
+		Exporter: viper.GetString(`tracing.exporter`),
+		Endpoint: viper.GetString(`tracing.endpoint`),
+		Insecure: viper.GetBool(`tracing.insecure`),
+	}
+}
+
+// Setup will install the tracer provider of service and the W3C trace context
+// propagator. The returned shutdown flushes the spans not exported yet. Without an
+// exporter no span is recorded, but the trace context is still passed along.
+func Setup(c Config, service string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var processor sdktrace.SpanProcessor
+	switch c.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case ExporterMemory:
+		// Spans are exported as soon as they end, so a test sees them right away
+		processor = sdktrace.NewSimpleSpanProcessor(Memory)
+	default:
+		return nil, ErrUnknownExporter
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// End will record err on span, if any, and end it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
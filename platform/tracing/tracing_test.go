@@ -0,0 +1,164 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"platform/middleware"
+	"platform/tracing"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setup(t *testing.T) {
+	shutdown, err := tracing.Setup(tracing.Config{Exporter: tracing.ExporterMemory}, "test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		shutdown(context.Background())
+		tracing.Memory.Reset()
+	})
+}
+
+func span(t *testing.T, name string) tracetest.SpanStub {
+	for _, s := range tracing.Memory.GetSpans() {
+		if s.Name == name {
+			return s
+		}
+	}
+	require.FailNow(t, "span not found", name)
+	return tracetest.SpanStub{}
+}
+
+func attr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	setup(t)
+
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(tracing.Middleware())
+	e.GET("/products/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return echo.NewHTTPError(http.StatusInternalServerError, "boom")
+		}
+		// The handler runs within the span of the request
+		assert.True(t, trace.SpanContextFromContext(c.Request().Context()).IsValid())
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(echo.GET, "/products/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(echo.HeaderXRequestID, "abc")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	s := span(t, "GET /products/:id")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", s.Parent.SpanID().String())
+	assert.True(t, s.Parent.IsRemote())
+	assert.Equal(t, trace.SpanKindServer, s.SpanKind)
+	assert.Equal(t, int64(http.StatusOK), attr(s, "http.status_code").AsInt64())
+	assert.Equal(t, "/products/:id", attr(s, "http.route").AsString())
+	assert.Equal(t, "abc", attr(s, "http.request_id").AsString())
+	assert.Equal(t, "test", s.Resource.Attributes()[0].Value.AsString())
+
+	tracing.Memory.Reset()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/products/0", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	s = span(t, "GET /products/:id")
+	assert.False(t, s.Parent.IsValid())
+	assert.Equal(t, codes.Error, s.Status.Code)
+	assert.Len(t, s.Events, 1)
+}
+
+func TestTransport(t *testing.T) {
+	setup(t)
+
+	e := echo.New()
+	e.Use(tracing.Middleware())
+	e.POST("/products/release/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "saga")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/products/release/3", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	// saga → HTTP POST → POST /products/release/:id, all in the same trace
+	saga := span(t, "saga")
+	call := span(t, "HTTP POST")
+	handler := span(t, "POST /products/release/:id")
+	assert.Equal(t, trace.SpanKindClient, call.SpanKind)
+	assert.Equal(t, saga.SpanContext.SpanID(), call.Parent.SpanID())
+	assert.Equal(t, call.SpanContext.SpanID(), handler.Parent.SpanID())
+	assert.Equal(t, saga.SpanContext.TraceID(), handler.SpanContext.TraceID())
+	assert.Equal(t, int64(http.StatusNoContent), attr(call, "http.status_code").AsInt64())
+	assert.Empty(t, req.Header.Get("traceparent"), "the request of the caller is left untouched")
+
+	tracing.Memory.Reset()
+	server.Close()
+	req, err = http.NewRequest(http.MethodPost, server.URL+"/products/release/3", nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	assert.Error(t, err)
+	assert.Equal(t, codes.Error, span(t, "HTTP POST").Status.Code)
+}
+
+func TestMessage(t *testing.T) {
+	setup(t)
+
+	headers := tracing.Headers{}
+	_, publish := tracing.StartPublish(context.Background(), "rabbitmq", "order", headers)
+	publish.End()
+	assert.NotEmpty(t, headers.Get("traceparent"))
+
+	ctx, consume := tracing.StartConsume(context.Background(), "rabbitmq", "order", headers)
+	assert.Equal(t, consume.SpanContext(), trace.SpanContextFromContext(ctx))
+	tracing.End(consume, assert.AnError)
+
+	send := span(t, "order send")
+	process := span(t, "order process")
+	assert.Equal(t, trace.SpanKindProducer, send.SpanKind)
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind)
+	assert.Equal(t, send.SpanContext.SpanID(), process.Parent.SpanID())
+	assert.Equal(t, send.SpanContext.TraceID(), process.SpanContext.TraceID())
+	assert.Equal(t, codes.Error, process.Status.Code)
+}
+
+func TestSetup(t *testing.T) {
+	_, err := tracing.Setup(tracing.Config{Exporter: "zipkin"}, "test")
+	assert.Equal(t, tracing.ErrUnknownExporter, err)
+
+	// Without exporter the trace context is still passed along
+	shutdown, err := tracing.Setup(tracing.Config{Exporter: tracing.ExporterNone}, "test")
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	headers := tracing.Headers{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx, span := tracing.StartConsume(context.Background(), "rabbitmq", "order", headers)
+	span.End()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanContextFromContext(ctx).TraceID().String())
+}
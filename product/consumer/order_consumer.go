@@ -0,0 +1,55 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"platform/broker"
+	"product/domain"
+)
+
+// OrderConsumer represent the message handler for orders placed by the order service
+type OrderConsumer struct {
+	Broker             broker.Broker
+	ReservationService domain.ReservationService
+}
+
+// NewOrderConsumer will start consuming the order queue
+func NewOrderConsumer(b broker.Broker, rs domain.ReservationService) error {
+	consumer := &OrderConsumer{
+		Broker:             b,
+		ReservationService: rs,
+	}
+
+	return b.Consume(domain.OrderQueue, consumer.Order)
+}
+
+// Order will hold the stock of every item of a new order and reply whether it was
+// accepted, an order is rejected whole when one of its items can not be held. The stock
+// is only taken once the order is paid, and is available again if it is not paid before
+// the holds expire.
+//
+// An order delivered again gets the reply it got the first time. The message is delivered
+// again when the order could not be handled or its reply not sent, so the order service
+// is not left waiting for a reply.
+func (oc *OrderConsumer) Order(ctx context.Context, body []byte) error {
+	var event domain.OrderCreated
+	err := json.Unmarshal(body, &event)
+	if err != nil {
+		return err
+	}
+
+	reply, err := oc.ReservationService.HoldOrder(ctx, event.OrderID, event.OrderItems())
+	if err == domain.ErrInvalidOrder {
+		return err
+	}
+	if err != nil {
+		return broker.Requeue(err)
+	}
+
+	payload, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	return broker.Requeue(oc.Broker.Publish(ctx, domain.OrderReplyQueue, payload))
+}
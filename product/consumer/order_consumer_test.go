@@ -0,0 +1,117 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"platform/broker"
+	"product/consumer"
+	"product/domain"
+	"product/domain/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func consumeReplies(t *testing.T, b broker.Broker) *[]domain.OrderReply {
+	replies := make([]domain.OrderReply, 0)
+	err := b.Consume(domain.OrderReplyQueue, func(ctx context.Context, body []byte) error {
+		var reply domain.OrderReply
+		err := json.Unmarshal(body, &reply)
+		replies = append(replies, reply)
+		return err
+	})
+	require.NoError(t, err)
+
+	return &replies
+}
+
+func TestOrderConsumer_Order(t *testing.T) {
+	mockReservationService := new(mocks.ReservationService)
+	b := broker.NewMemoryBroker()
+	replies := consumeReplies(t, b)
+
+	err := consumer.NewOrderConsumer(b, mockReservationService)
+	require.NoError(t, err)
+
+	t.Run("accepted", func(t *testing.T) {
+		items := []domain.OrderItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}
+		mockReservationService.On("HoldOrder", mock.Anything, uint32(1), items).Return(domain.OrderReply{OrderID: 1, Accepted: true}, nil).Once()
+
+		err := b.Publish(context.TODO(), domain.OrderQueue, []byte(`{"order_id":1,"items":[{"product_id":3,"qty":2},{"product_id":4,"qty":1}]}`))
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderReply{OrderID: 1, Accepted: true}, (*replies)[len(*replies)-1])
+		mockReservationService.AssertExpectations(t)
+	})
+
+	t.Run("single product", func(t *testing.T) {
+		mockReservationService.On("HoldOrder", mock.Anything, uint32(3), []domain.OrderItem{{ProductID: 3, Qty: 2}}).Return(domain.OrderReply{OrderID: 3, Accepted: true}, nil).Once()
+
+		err := b.Publish(context.TODO(), domain.OrderQueue, []byte(`{"order_id":3,"product_id":3,"qty":2}`))
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderReply{OrderID: 3, Accepted: true}, (*replies)[len(*replies)-1])
+		mockReservationService.AssertExpectations(t)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		items := []domain.OrderItem{{ProductID: 3, Qty: 20}, {ProductID: 4, Qty: 1}}
+		reply := domain.OrderReply{OrderID: 2, Reason: domain.ReasonInsufficientStock}
+		mockReservationService.On("HoldOrder", mock.Anything, uint32(2), items).Return(reply, nil).Once()
+
+		err := b.Publish(context.TODO(), domain.OrderQueue, []byte(`{"order_id":2,"items":[{"product_id":3,"qty":20},{"product_id":4,"qty":1}]}`))
+		assert.NoError(t, err)
+		assert.Equal(t, reply, (*replies)[len(*replies)-1])
+		mockReservationService.AssertExpectations(t)
+	})
+}
+
+func TestOrderConsumer_Order_Error(t *testing.T) {
+	body := []byte(`{"order_id":1,"items":[{"product_id":3,"qty":2}]}`)
+	items := []domain.OrderItem{{ProductID: 3, Qty: 2}}
+
+	t.Run("malformed", func(t *testing.T) {
+		mockReservationService := new(mocks.ReservationService)
+		handler := consumer.OrderConsumer{Broker: broker.NewMemoryBroker(), ReservationService: mockReservationService}
+
+		err := handler.Order(context.TODO(), []byte(`not json`))
+		assert.Error(t, err)
+		assert.False(t, broker.IsRequeue(err))
+		mockReservationService.AssertNotCalled(t, "HoldOrder", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no order id", func(t *testing.T) {
+		mockReservationService := new(mocks.ReservationService)
+		mockReservationService.On("HoldOrder", mock.Anything, uint32(0), items).Return(domain.OrderReply{}, domain.ErrInvalidOrder).Once()
+		handler := consumer.OrderConsumer{Broker: broker.NewMemoryBroker(), ReservationService: mockReservationService}
+
+		err := handler.Order(context.TODO(), []byte(`{"items":[{"product_id":3,"qty":2}]}`))
+		assert.Equal(t, domain.ErrInvalidOrder, err)
+	})
+
+	t.Run("not handled", func(t *testing.T) {
+		mockReservationService := new(mocks.ReservationService)
+		mockReservationService.On("HoldOrder", mock.Anything, uint32(1), items).Return(domain.OrderReply{}, errors.New("Unexpected Error")).Once()
+		b := broker.NewMemoryBroker()
+		replies := consumeReplies(t, b)
+		handler := consumer.OrderConsumer{Broker: b, ReservationService: mockReservationService}
+
+		// The order is delivered again instead of being rejected
+		err := handler.Order(context.TODO(), body)
+		assert.True(t, broker.IsRequeue(err))
+		assert.Empty(t, *replies)
+	})
+
+	t.Run("reply not sent", func(t *testing.T) {
+		mockReservationService := new(mocks.ReservationService)
+		mockReservationService.On("HoldOrder", mock.Anything, uint32(1), items).Return(domain.OrderReply{OrderID: 1, Accepted: true}, nil).Once()
+		b := broker.NewMemoryBroker()
+		require.NoError(t, b.Close())
+		handler := consumer.OrderConsumer{Broker: b, ReservationService: mockReservationService}
+
+		err := handler.Order(context.TODO(), body)
+		assert.True(t, broker.IsRequeue(err))
+		assert.True(t, errors.Is(err, broker.ErrClosed))
+	})
+}
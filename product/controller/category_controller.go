@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"net/http"
+	"product/domain"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CategoryController struct {
+	CategoryService domain.CategoryService
+}
+
+func NewCategoryController(e *echo.Echo, cs domain.CategoryService) {
+	controller := &CategoryController{
+		CategoryService: cs,
+	}
+	group := e.Group("/api/v1")
+	group.GET("/categories", controller.Fetch)
+	group.GET("/categories/:id", controller.GetByID)
+	group.POST("/categories", controller.Store)
+	group.PUT("/categories/:id", controller.Update)
+	group.DELETE("/categories/:id", controller.Delete)
+}
+
+// Fetch will return every category, the tree is rebuilt from their parent_id
+func (ch *CategoryController) Fetch(c echo.Context) error {
+	ctx := c.Request().Context()
+	list, err := ch.CategoryService.Fetch(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func (ch *CategoryController) GetByID(c echo.Context) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	category, err := ch.CategoryService.GetByID(ctx, uint32(paramID))
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+func (ch *CategoryController) Store(c echo.Context) (err error) {
+	var category domain.Category
+	err = c.Bind(&category)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	category.ID = 0
+	ctx := c.Request().Context()
+	err = ch.CategoryService.Store(ctx, &category)
+	if err == domain.ErrInvalidCategory || err == domain.ErrUnknownCategory {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, category)
+}
+
+// Update will rename a category or move it under another parent, or to the root when
+// parent_id is null
+func (ch *CategoryController) Update(c echo.Context) (err error) {
+	var category domain.Category
+	err = c.Bind(&category)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	err = ch.CategoryService.Update(ctx, &category, uint32(paramID))
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err == domain.ErrInvalidCategory || err == domain.ErrUnknownCategory || err == domain.ErrCategoryCycle {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Delete will remove an empty category, one with subcategories or products is answered
+// with a 409
+func (ch *CategoryController) Delete(c echo.Context) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	err = ch.CategoryService.Delete(ctx, uint32(paramID))
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err == domain.ErrCategoryInUse {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
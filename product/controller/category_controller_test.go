@@ -0,0 +1,159 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"product/controller"
+	"product/domain"
+	"product/domain/mocks"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryController_Fetch(t *testing.T) {
+	parentID := uint32(1)
+	mockCategoryService := new(mocks.CategoryService)
+	mockCategoryService.On("Fetch", mock.Anything).Return([]domain.Category{{ID: 1, Name: "Electronics"}, {ID: 2, ParentID: &parentID, Name: "Laptops"}}, nil)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/categories", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	handler := controller.CategoryController{CategoryService: mockCategoryService}
+	err = handler.Fetch(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var list []domain.Category
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list, 2)
+	assert.Equal(t, parentID, *list[1].ParentID)
+	mockCategoryService.AssertExpectations(t)
+}
+
+func TestCategoryController_GetByID(t *testing.T) {
+	mockCategoryService := new(mocks.CategoryService)
+	mockCategoryService.On("GetByID", mock.Anything, uint32(9)).Return(domain.Category{}, domain.ErrNotFound)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/categories/9", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/categories/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("9")
+	handler := controller.CategoryController{CategoryService: mockCategoryService}
+	err = handler.GetByID(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	mockCategoryService.AssertExpectations(t)
+}
+
+func TestCategoryController_Store(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"unknown parent", domain.ErrUnknownCategory, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCategoryService := new(mocks.CategoryService)
+			mockCategoryService.On("Store", mock.Anything, mock.AnythingOfType("*domain.Category")).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/categories", strings.NewReader(`{"name":"Laptops","parent_id":1}`))
+			assert.NoError(t, err)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			handler := controller.CategoryController{CategoryService: mockCategoryService}
+			err = handler.Store(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockCategoryService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCategoryController_Update(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"moved", nil, http.StatusNoContent},
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+		{"under itself", domain.ErrCategoryCycle, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCategoryService := new(mocks.CategoryService)
+			mockCategoryService.On("Update", mock.Anything, mock.AnythingOfType("*domain.Category"), uint32(2)).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.PUT, "/api/v1/categories/2", strings.NewReader(`{"name":"Laptops","parent_id":4}`))
+			assert.NoError(t, err)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/categories/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("2")
+			handler := controller.CategoryController{CategoryService: mockCategoryService}
+			err = handler.Update(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockCategoryService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCategoryController_Delete(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"deleted", nil, http.StatusNoContent},
+		{"in use", domain.ErrCategoryInUse, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCategoryService := new(mocks.CategoryService)
+			mockCategoryService.On("Delete", mock.Anything, uint32(1)).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.DELETE, "/api/v1/categories/1", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/categories/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			handler := controller.CategoryController{CategoryService: mockCategoryService}
+			err = handler.Delete(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockCategoryService.AssertExpectations(t)
+		})
+	}
+}
@@ -2,8 +2,11 @@ package controller_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"platform/money"
+	"platform/pagination"
 	"product/controller"
 	"product/domain"
 	"product/domain/mocks"
@@ -24,10 +27,12 @@ func TestProductController_Fetch(t *testing.T) {
 	mockListProduct := make([]domain.Product, 0)
 	mockListProduct = append(mockListProduct, mockProduct)
 
-	mockProdService.On("Fetch", mock.Anything).Return(mockListProduct, nil)
+	filter := domain.ProductFilter{Name: "Laptop", Currency: "IDR", InStock: true, CategoryID: 2}
+	query := pagination.Query{Limit: 10, Sort: "price", Desc: true}
+	mockProdService.On("Fetch", mock.Anything, filter, query).Return(mockListProduct, "next", nil)
 
 	e := echo.New()
-	req, err := http.NewRequest(echo.GET, "/api/v1/products", strings.NewReader(""))
+	req, err := http.NewRequest(echo.GET, "/api/v1/products?name=Laptop&currency=IDR&in_stock=true&category_id=2&limit=10&sort=-price", strings.NewReader(""))
 	assert.NoError(t, err)
 
 	rec := httptest.NewRecorder()
@@ -36,12 +41,104 @@ func TestProductController_Fetch(t *testing.T) {
 	err = handler.Fetch(c)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var page struct {
+		Data       []domain.Product `json:"data"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, "next", page.NextCursor)
+	mockProdService.AssertExpectations(t)
+}
+
+func TestProductController_Fetch_BadQuery(t *testing.T) {
+	for _, target := range []string{"/api/v1/products?limit=1000", "/api/v1/products?in_stock=maybe", "/api/v1/products?category_id=-1"} {
+		e := echo.New()
+		req, err := http.NewRequest(echo.GET, target, strings.NewReader(""))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := controller.ProductController{ProdService: new(mocks.ProductService)}
+		err = handler.Fetch(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestProductController_Fetch_PriceWithoutCurrency(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	query := pagination.Query{Limit: 20, Sort: "price"}
+	mockProdService.On("Fetch", mock.Anything, domain.ProductFilter{}, query).Return(nil, "", domain.ErrCurrencyRequired)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/products?sort=price", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.Fetch(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockProdService.AssertExpectations(t)
+}
+
+func TestProductController_Search(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	mockListProduct := []domain.Product{{ID: 3, Name: "Lenovo ThinkPad X1"}}
+
+	min, max := int64(100000000), int64(3000000000)
+	search := domain.ProductSearch{Terms: "thinkpad", Currency: "IDR", MinPrice: &min, MaxPrice: &max, InStock: true, Limit: 5}
+	mockProdService.On("Search", mock.Anything, search).Return(mockListProduct, nil)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/products/search?q=thinkpad&currency=IDR&min_price=100000000&max_price=3000000000&in_stock=true&limit=5", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.Search(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var page struct {
+		Data []domain.Product `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 1)
 	mockProdService.AssertExpectations(t)
 }
 
+func TestProductController_Search_BadQuery(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	mockProdService.On("Search", mock.Anything, domain.ProductSearch{Limit: pagination.DefaultLimit}).Return(nil, domain.ErrEmptySearch)
+
+	targets := []string{
+		"/api/v1/products/search",
+		"/api/v1/products/search?q=thinkpad&limit=0",
+		"/api/v1/products/search?q=thinkpad&min_price=cheap",
+		"/api/v1/products/search?q=thinkpad&in_stock=maybe",
+	}
+	for _, target := range targets {
+		e := echo.New()
+		req, err := http.NewRequest(echo.GET, target, strings.NewReader(""))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		handler := controller.ProductController{ProdService: mockProdService}
+		err = handler.Search(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
 func TestProductController_Fetch_Error(t *testing.T) {
 	mockProdService := new(mocks.ProductService)
-	mockProdService.On("Fetch", mock.Anything).Return(nil, nil)
+	mockProdService.On("Fetch", mock.Anything, domain.ProductFilter{}, mock.Anything).Return(nil, "", nil)
 
 	e := echo.New()
 	req, err := http.NewRequest(echo.GET, "/api/v1/products", strings.NewReader(""))
@@ -81,11 +178,68 @@ func TestProductController_GetByID(t *testing.T) {
 	mockProdService.AssertExpectations(t)
 }
 
+func TestProductController_GetByID_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+		{"database down", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProdService := new(mocks.ProductService)
+			mockProdService.On("GetByID", mock.Anything, uint32(9)).Return(domain.Product{}, tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.GET, "/api/v1/products/9", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("api/v1/products/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("9")
+			handler := controller.ProductController{ProdService: mockProdService}
+			err = handler.GetByID(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.code, rec.Code)
+			mockProdService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductController_Update_NotFound(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	mockProdService.On("Update", mock.Anything, mock.AnythingOfType("*domain.Product"), uint32(9)).Return(domain.ErrNotFound).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.PUT, "/api/v1/products/9", strings.NewReader(`{"name":"Laptop","price":{"amount":700000000,"currency":"IDR"},"stock":1}`))
+	assert.NoError(t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/products/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("9")
+
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.Update(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	mockProdService.AssertExpectations(t)
+}
+
 func TestProductController_Store(t *testing.T) {
 	mockProduct := domain.Product{
 		ID:        1,
 		Name:      "Laptop Lenovo Thinkpad",
-		Price:     7000000,
+		Price:     money.New(700000000, "IDR"),
 		Stock:     10,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -117,11 +271,32 @@ func TestProductController_Store(t *testing.T) {
 	mockProdService.AssertExpectations(t)
 }
 
+func TestProductController_Store_InvalidPrice(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	mockProdService.On("Store", mock.Anything, mock.AnythingOfType("*domain.Product")).Return(domain.ErrInvalidPrice).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.POST, "/api/v1/products", strings.NewReader(`{"name":"Laptop","price":{"amount":700000000,"currency":"XXX"},"stock":1}`))
+	assert.NoError(t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/products")
+
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.Store(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	mockProdService.AssertExpectations(t)
+}
+
 func TestProductController_Update(t *testing.T) {
 	mockProduct := domain.Product{
 		ID:        1,
 		Name:      "Laptop Lenovo Thinkpad",
-		Price:     7000000,
+		Price:     money.New(700000000, "IDR"),
 		Stock:     10,
 		CreatedAt: time.Time{},
 		UpdatedAt: time.Time{},
@@ -156,6 +331,29 @@ func TestProductController_Update(t *testing.T) {
 	mockProdService.AssertExpectations(t)
 }
 
+func TestProductController_Update_StockHeld(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	mockProdService.On("Update", mock.Anything, mock.AnythingOfType("*domain.Product"), uint32(1)).Return(domain.ErrStockHeld)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.PUT, "/api/v1/products/1", strings.NewReader(`{"name":"Laptop Lenovo Thinkpad","price":{"amount":700000000,"currency":"IDR"},"stock":2}`))
+	assert.NoError(t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/products/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.Update(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	mockProdService.AssertExpectations(t)
+}
+
 func TestProductController_Delete(t *testing.T) {
 	var mockProduct domain.Product
 	mockProdService := new(mocks.ProductService)
@@ -180,3 +378,98 @@ func TestProductController_Delete(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, rec.Code)
 	mockProdService.AssertExpectations(t)
 }
+
+func TestProductController_Delete_HasVariants(t *testing.T) {
+	mockProdService := new(mocks.ProductService)
+	mockProdService.On("Delete", mock.Anything, uint32(5)).Return(domain.ErrHasVariants)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.DELETE, "/api/v1/products/5", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/products/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("5")
+
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.Delete(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	mockProdService.AssertExpectations(t)
+}
+
+func TestProductController_AdjustStock(t *testing.T) {
+	num := 1
+
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"success", `{"delta":-2,"reason":"damage"}`, nil, http.StatusCreated},
+		{"not found", `{"delta":-2,"reason":"damage"}`, domain.ErrNotFound, http.StatusNotFound},
+		{"invalid adjustment", `{"delta":-2,"reason":"damage"}`, domain.ErrInvalidAdjustment, http.StatusUnprocessableEntity},
+		{"insufficient stock", `{"delta":-2,"reason":"damage"}`, domain.ErrInsufficientStock, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movement := &domain.StockMovement{ProductID: uint32(num), Delta: -2, Reason: domain.StockDamage}
+			mockProdService := new(mocks.ProductService)
+			mockProdService.On("AdjustStock", mock.Anything, movement).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/products/"+strconv.Itoa(num)+"/stock", strings.NewReader(tt.body))
+			assert.NoError(t, err)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/products/:id/stock")
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.Itoa(num))
+
+			handler := controller.ProductController{ProdService: mockProdService}
+			err = handler.AdjustStock(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, rec.Code)
+			mockProdService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductController_FetchMovements(t *testing.T) {
+	num := 1
+	mockMovements := []domain.StockMovement{
+		{ID: 1, ProductID: uint32(num), Delta: 10, Reason: domain.StockInitial},
+		{ID: 2, ProductID: uint32(num), Delta: -2, Reason: domain.StockOrder},
+	}
+
+	mockProdService := new(mocks.ProductService)
+	mockProdService.On("FetchMovements", mock.Anything, uint32(num)).Return(mockMovements, nil).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/products/"+strconv.Itoa(num)+"/stock", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/products/:id/stock")
+	c.SetParamNames("id")
+	c.SetParamValues(strconv.Itoa(num))
+
+	handler := controller.ProductController{ProdService: mockProdService}
+	err = handler.FetchMovements(c)
+	require.NoError(t, err)
+
+	var list []domain.StockMovement
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, list, 2)
+	mockProdService.AssertExpectations(t)
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"product/domain"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The codes of the errors a client can tell apart, they stay the same whatever the message
+const (
+	codeInsufficientStock  = "insufficient_stock"
+	codeReservationExpired = "reservation_expired"
+	codeReservationClosed  = "reservation_closed"
+)
+
+var reservationCodes = map[error]string{
+	domain.ErrInsufficientStock:  codeInsufficientStock,
+	domain.ErrReservationExpired: codeReservationExpired,
+	domain.ErrReservationClosed:  codeReservationClosed,
+}
+
+type ReservationController struct {
+	ReservationService domain.ReservationService
+}
+
+func NewReservationController(e *echo.Echo, rs domain.ReservationService) {
+	controller := &ReservationController{
+		ReservationService: rs,
+	}
+	group := e.Group("/api/v1")
+	group.POST("/products/:id/reservations", controller.Hold)
+	group.GET("/reservations/:id", controller.GetByID)
+	group.POST("/reservations/:id/commit", controller.Commit)
+	group.POST("/reservations/:id/release", controller.Release)
+	// The stock of an order is held by the order consumer, and taken or given back here
+	group.POST("/products/orders/:id/commit", controller.CommitOrder)
+	group.POST("/products/orders/:id/release", controller.ReleaseOrder)
+}
+
+// Hold will reserve qty of a product until the reservation expires, without taking it
+// out of the stock yet
+func (rh *ReservationController) Hold(c echo.Context) (err error) {
+	var po domain.ProductOrder
+	err = c.Bind(&po)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	reservation := domain.Reservation{ProductID: uint32(paramID), Qty: po.Qty}
+	ctx := c.Request().Context()
+
+	err = rh.ReservationService.Hold(ctx, &reservation)
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err == domain.ErrInsufficientStock {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err":  err.Error(),
+			"code": codeInsufficientStock,
+		})
+	}
+	if err == domain.ErrInvalidQty || err == domain.ErrHasVariants {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, reservation)
+}
+
+func (rh *ReservationController) GetByID(c echo.Context) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	reservation, err := rh.ReservationService.GetByID(ctx, uint32(paramID))
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, reservation)
+}
+
+// Commit will take the qty held by a reservation out of the stock
+func (rh *ReservationController) Commit(c echo.Context) error {
+	return rh.close(c, rh.ReservationService.Commit)
+}
+
+// Release will give the qty held by a reservation back to the available stock
+func (rh *ReservationController) Release(c echo.Context) error {
+	return rh.close(c, rh.ReservationService.Release)
+}
+
+// CommitOrder will take the stock held for an order out of the stock, once the order is paid
+func (rh *ReservationController) CommitOrder(c echo.Context) error {
+	return rh.close(c, rh.ReservationService.CommitOrder)
+}
+
+// ReleaseOrder will give back the stock held or taken for an order that is cancelled or failed
+func (rh *ReservationController) ReleaseOrder(c echo.Context) error {
+	return rh.close(c, rh.ReservationService.ReleaseOrder)
+}
+
+// close will answer the commit or release of a reservation, one that is no longer active
+// or has expired is answered with a 409. The id is the one of the reservation, or of the
+// order for the calls made for an order. The errors a client acts on carry a code.
+func (rh *ReservationController) close(c echo.Context, fn func(ctx context.Context, id uint32) error) error {
+	paramID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	err = fn(ctx, uint32(paramID))
+	if err == domain.ErrNotFound {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"err": err.Error(),
+		})
+	}
+	if err == domain.ErrReservationClosed || err == domain.ErrReservationExpired {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"err":  err.Error(),
+			"code": reservationCodes[err],
+		})
+	}
+	if err == domain.ErrInsufficientStock {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"err":  err.Error(),
+			"code": codeInsufficientStock,
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
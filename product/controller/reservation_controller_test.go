@@ -0,0 +1,210 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"product/controller"
+	"product/domain"
+	"product/domain/mocks"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservationController_Hold(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"insufficient stock", domain.ErrInsufficientStock, http.StatusUnprocessableEntity},
+		{"parent product", domain.ErrHasVariants, http.StatusUnprocessableEntity},
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockReservationService := new(mocks.ReservationService)
+			mockReservationService.On("Hold", mock.Anything, &domain.Reservation{ProductID: 1, Qty: 2}).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/products/1/reservations", strings.NewReader(`{"qty":2}`))
+			assert.NoError(t, err)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/products/:id/reservations")
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+			handler := controller.ReservationController{ReservationService: mockReservationService}
+			err = handler.Hold(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockReservationService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReservationController_GetByID(t *testing.T) {
+	mockReservationService := new(mocks.ReservationService)
+	mockReservationService.On("GetByID", mock.Anything, uint32(4)).
+		Return(domain.Reservation{ID: 4, ProductID: 1, Qty: 2, Status: domain.ReservationActive}, nil)
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/reservations/4", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/reservations/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("4")
+	handler := controller.ReservationController{ReservationService: mockReservationService}
+	err = handler.GetByID(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var reservation domain.Reservation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reservation))
+	assert.Equal(t, domain.ReservationActive, reservation.Status)
+	mockReservationService.AssertExpectations(t)
+}
+
+func TestReservationController_GetByID_BadID(t *testing.T) {
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/reservations/abc", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/reservations/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("abc")
+	handler := controller.ReservationController{ReservationService: new(mocks.ReservationService)}
+	err = handler.GetByID(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestReservationController_Commit(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"committed", nil, http.StatusNoContent},
+		{"expired", domain.ErrReservationExpired, http.StatusConflict},
+		{"closed", domain.ErrReservationClosed, http.StatusConflict},
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockReservationService := new(mocks.ReservationService)
+			mockReservationService.On("Commit", mock.Anything, uint32(4)).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/reservations/4/commit", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/reservations/:id/commit")
+			c.SetParamNames("id")
+			c.SetParamValues("4")
+			handler := controller.ReservationController{ReservationService: mockReservationService}
+			err = handler.Commit(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockReservationService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReservationController_Release(t *testing.T) {
+	mockReservationService := new(mocks.ReservationService)
+	mockReservationService.On("Release", mock.Anything, uint32(4)).Return(nil).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.POST, "/api/v1/reservations/4/release", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/reservations/:id/release")
+	c.SetParamNames("id")
+	c.SetParamValues("4")
+	handler := controller.ReservationController{ReservationService: mockReservationService}
+	err = handler.Release(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockReservationService.AssertExpectations(t)
+}
+
+func TestReservationController_CommitOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      int
+		errorCode string
+	}{
+		{"committed", nil, http.StatusNoContent, ""},
+		{"expired", domain.ErrReservationExpired, http.StatusConflict, "reservation_expired"},
+		{"released", domain.ErrReservationClosed, http.StatusConflict, "reservation_closed"},
+		{"insufficient stock", domain.ErrInsufficientStock, http.StatusUnprocessableEntity, "insufficient_stock"},
+		{"not found", domain.ErrNotFound, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockReservationService := new(mocks.ReservationService)
+			mockReservationService.On("CommitOrder", mock.Anything, uint32(7)).Return(tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.POST, "/api/v1/products/orders/7/commit", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/products/orders/:id/commit")
+			c.SetParamNames("id")
+			c.SetParamValues("7")
+			handler := controller.ReservationController{ReservationService: mockReservationService}
+			err = handler.CommitOrder(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.errorCode != "" {
+				// The order service relies on the code, not on the message
+				assert.JSONEq(t, `{"err":"`+tt.err.Error()+`","code":"`+tt.errorCode+`"}`, rec.Body.String())
+			}
+			mockReservationService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReservationController_ReleaseOrder(t *testing.T) {
+	mockReservationService := new(mocks.ReservationService)
+	mockReservationService.On("ReleaseOrder", mock.Anything, uint32(7)).Return(nil).Once()
+
+	e := echo.New()
+	req, err := http.NewRequest(echo.POST, "/api/v1/products/orders/7/release", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/products/orders/:id/release")
+	c.SetParamNames("id")
+	c.SetParamValues("7")
+	handler := controller.ReservationController{ReservationService: mockReservationService}
+	err = handler.ReleaseOrder(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockReservationService.AssertExpectations(t)
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidCategory = errors.New("category name must not be empty")
+	ErrUnknownCategory = errors.New("unknown category")
+	ErrCategoryCycle   = errors.New("a category can not be moved under itself")
+	ErrCategoryInUse   = errors.New("category still has subcategories or products")
+)
+
+// Category groups products in a tree, a category without ParentID is at the root. The
+// products of a category include the ones of its subcategories.
+type Category struct {
+	ID        uint32    `json:"id"`
+	ParentID  *uint32   `json:"parent_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CategoryRepository interface {
+	Fetch(ctx context.Context) ([]Category, error)
+	GetByID(ctx context.Context, id uint32) (Category, error)
+	Store(ctx context.Context, category *Category) error
+	Update(ctx context.Context, category *Category, id uint32) error
+	Delete(ctx context.Context, id uint32) error
+}
+
+type CategoryService interface {
+	Fetch(ctx context.Context) ([]Category, error)
+	GetByID(ctx context.Context, id uint32) (Category, error)
+	Store(ctx context.Context, category *Category) error
+	Update(ctx context.Context, category *Category, id uint32) error
+	Delete(ctx context.Context, id uint32) error
+}
@@ -0,0 +1,81 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"product/domain"
+)
+
+type CategoryRepository struct {
+	mock.Mock
+}
+
+func (_m *CategoryRepository) Fetch(ctx context.Context) ([]domain.Category, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []domain.Category
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Category)
+	}
+
+	return r0, ret.Error(1)
+}
+
+func (_m *CategoryRepository) GetByID(ctx context.Context, id uint32) (domain.Category, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Category
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Category); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Category)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *CategoryRepository) Store(ctx context.Context, category *domain.Category) error {
+	ret := _m.Called(ctx, category)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Category) error); ok {
+		r0 = rf(ctx, category)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *CategoryRepository) Update(ctx context.Context, category *domain.Category, id uint32) error {
+	ret := _m.Called(ctx, category, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Category, uint32) error); ok {
+		r0 = rf(ctx, category, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *CategoryRepository) Delete(ctx context.Context, id uint32) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
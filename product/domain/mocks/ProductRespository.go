@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"github.com/stretchr/testify/mock"
+	"platform/pagination"
 	"golang.org/x/net/context"
 	"product/domain"
 )
@@ -10,26 +11,26 @@ type ProductRepository struct {
 	mock.Mock
 }
 
-func (_m *ProductRepository) Fetch(ctx context.Context) ([]domain.Product, error)  {
-	ret := _m.Called(ctx)
+func (_m *ProductRepository) Fetch(ctx context.Context, filter domain.ProductFilter, q pagination.Query) ([]domain.Product, string, error)  {
+	ret := _m.Called(ctx, filter, q)
 
 	var r0 []domain.Product
-	if rf, ok := ret.Get(0).(func(context.Context) []domain.Product); ok {
-		r0 = rf(ctx)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.Product)
-		}
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Product)
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
-	} else {
-		r1 = ret.Error(1)
+	return r0, ret.String(1), ret.Error(2)
+}
+
+func (_m *ProductRepository) Search(ctx context.Context, search domain.ProductSearch) ([]domain.Product, error)  {
+	ret := _m.Called(ctx, search)
+
+	var r0 []domain.Product
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Product)
 	}
 
-	return r0, r1
+	return r0, ret.Error(1)
 }
 
 func (_m *ProductRepository) GetByID(ctx context.Context, id uint32) (domain.Product, error)  {
@@ -91,15 +92,37 @@ func (_m *ProductRepository) Delete(ctx context.Context, id uint32) error  {
 	return r0
 }
 
-func (_m *ProductRepository) UpdateStock(ctx context.Context, p *domain.Product, id uint32) error {
-	ret := _m.Called(ctx, p)
+func (_m *ProductRepository) AdjustStock(ctx context.Context, movement *domain.StockMovement) error {
+	ret := _m.Called(ctx, movement)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *domain.Product) error); ok {
-		r0 = rf(ctx, p)
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.StockMovement) error); ok {
+		r0 = rf(ctx, movement)
 	} else {
 		r0 = ret.Error(0)
 	}
 
 	return r0
 }
+
+func (_m *ProductRepository) FetchMovements(ctx context.Context, id uint32) ([]domain.StockMovement, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []domain.StockMovement
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) []domain.StockMovement); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.StockMovement)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -3,6 +3,7 @@ package mocks
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"platform/pagination"
 	"product/domain"
 )
 
@@ -10,26 +11,26 @@ type ProductService struct {
 	mock.Mock
 }
 
-func (_m *ProductService) Fetch(ctx context.Context) ([]domain.Product, error)  {
-	ret := _m.Called(ctx)
+func (_m *ProductService) Fetch(ctx context.Context, filter domain.ProductFilter, q pagination.Query) ([]domain.Product, string, error)  {
+	ret := _m.Called(ctx, filter, q)
 
 	var r0 []domain.Product
-	if rf, ok := ret.Get(0).(func(context.Context) []domain.Product); ok {
-		r0 = rf(ctx)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.Product)
-		}
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Product)
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(ctx2 context.Context) error); ok {
-		r1 = rf(ctx)
-	} else {
-		r1 = ret.Error(1)
+	return r0, ret.String(1), ret.Error(2)
+}
+
+func (_m *ProductService) Search(ctx context.Context, search domain.ProductSearch) ([]domain.Product, error)  {
+	ret := _m.Called(ctx, search)
+
+	var r0 []domain.Product
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.Product)
 	}
 
-	return r0, r1
+	return r0, ret.Error(1)
 }
 
 func (_m *ProductService) GetByID(ctx context.Context, id uint32) (domain.Product, error)  {
@@ -91,15 +92,37 @@ func (_m *ProductService) Delete(_a0 context.Context, _a1 uint32) error  {
 	return r0
 }
 
-func (_m *ProductService) UpdateStock(_a0 context.Context, _a1 *domain.Product, _a2 uint32) error  {
-	ret := _m.Called(_a0, _a1, _a2)
+func (_m *ProductService) AdjustStock(ctx context.Context, movement *domain.StockMovement) error {
+	ret := _m.Called(ctx, movement)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *domain.Product, uint32) error); ok {
-		r0 = rf(_a0, _a1, _a2)
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.StockMovement) error); ok {
+		r0 = rf(ctx, movement)
 	} else {
 		r0 = ret.Error(0)
 	}
 
 	return r0
-}
\ No newline at end of file
+}
+
+func (_m *ProductService) FetchMovements(ctx context.Context, id uint32) ([]domain.StockMovement, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []domain.StockMovement
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) []domain.StockMovement); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.StockMovement)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
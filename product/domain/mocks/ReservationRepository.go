@@ -0,0 +1,93 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"product/domain"
+	"time"
+)
+
+type ReservationRepository struct {
+	mock.Mock
+}
+
+func (_m *ReservationRepository) GetByID(ctx context.Context, id uint32) (domain.Reservation, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Reservation
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Reservation); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Reservation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *ReservationRepository) Hold(ctx context.Context, reservation *domain.Reservation) error {
+	ret := _m.Called(ctx, reservation)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Reservation) error); ok {
+		r0 = rf(ctx, reservation)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *ReservationRepository) Commit(ctx context.Context, id uint32, now time.Time) error {
+	ret := _m.Called(ctx, id, now)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationRepository) Release(ctx context.Context, id uint32) error {
+	ret := _m.Called(ctx, id)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationRepository) GetOrderReply(ctx context.Context, orderID uint32) (domain.OrderReply, error) {
+	ret := _m.Called(ctx, orderID)
+
+	return ret.Get(0).(domain.OrderReply), ret.Error(1)
+}
+
+func (_m *ReservationRepository) HoldOrder(ctx context.Context, orderID uint32, items []domain.OrderItem, expiresAt time.Time) error {
+	ret := _m.Called(ctx, orderID, items, expiresAt)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationRepository) RejectOrder(ctx context.Context, orderID uint32, reason string) error {
+	ret := _m.Called(ctx, orderID, reason)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationRepository) CommitOrder(ctx context.Context, orderID uint32, now time.Time) error {
+	ret := _m.Called(ctx, orderID, now)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationRepository) ReleaseOrder(ctx context.Context, orderID uint32) error {
+	ret := _m.Called(ctx, orderID)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationRepository) Expire(ctx context.Context, now time.Time) (int64, error) {
+	ret := _m.Called(ctx, now)
+
+	return ret.Get(0).(int64), ret.Error(1)
+}
@@ -0,0 +1,80 @@
+package mocks
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"product/domain"
+)
+
+type ReservationService struct {
+	mock.Mock
+}
+
+func (_m *ReservationService) GetByID(ctx context.Context, id uint32) (domain.Reservation, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Reservation
+	if rf, ok := ret.Get(0).(func(context.Context, uint32) domain.Reservation); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Reservation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint32) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (_m *ReservationService) Hold(ctx context.Context, reservation *domain.Reservation) error {
+	ret := _m.Called(ctx, reservation)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *domain.Reservation) error); ok {
+		r0 = rf(ctx, reservation)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (_m *ReservationService) Commit(ctx context.Context, id uint32) error {
+	ret := _m.Called(ctx, id)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationService) Release(ctx context.Context, id uint32) error {
+	ret := _m.Called(ctx, id)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationService) HoldOrder(ctx context.Context, orderID uint32, items []domain.OrderItem) (domain.OrderReply, error) {
+	ret := _m.Called(ctx, orderID, items)
+
+	return ret.Get(0).(domain.OrderReply), ret.Error(1)
+}
+
+func (_m *ReservationService) CommitOrder(ctx context.Context, orderID uint32) error {
+	ret := _m.Called(ctx, orderID)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationService) ReleaseOrder(ctx context.Context, orderID uint32) error {
+	ret := _m.Called(ctx, orderID)
+
+	return ret.Error(0)
+}
+
+func (_m *ReservationService) Expire(ctx context.Context) (int64, error) {
+	ret := _m.Called(ctx)
+
+	return ret.Get(0).(int64), ret.Error(1)
+}
@@ -2,16 +2,98 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"platform/money"
+	"platform/pagination"
 	"time"
 )
 
+// Queues used to exchange order events with the order service
+const (
+	OrderQueue      = "OrderQueue"
+	OrderReplyQueue = "OrderReplyQueue"
+)
+
+var (
+	ErrNotFound          = errors.New("Record Not Found")
+	ErrInsufficientStock = errors.New("Insufficient stock")
+	ErrInvalidQty        = errors.New("qty must be positive")
+	ErrNoItems           = errors.New("no items")
+	ErrInvalidOrder      = errors.New("order id must be set")
+	ErrInvalidAdjustment = errors.New("delta does not match the reason")
+	ErrInvalidPrice      = errors.New("price must not be negative and be in a known currency")
+	ErrEmptySearch       = errors.New("search terms must not be empty")
+	ErrInvalidPriceRange = errors.New("a price range needs a currency and min_price not above max_price")
+	ErrCurrencyRequired  = errors.New("sorting by price needs a currency")
+	ErrInvalidAttribute  = errors.New("attribute names must have 1 to 64 characters and values at most 255")
+	ErrInvalidParent     = errors.New("parent must be a product that is not a variant")
+	ErrHasVariants       = errors.New("product has variants, order one of them")
+	ErrParentStock       = errors.New("a parent product holds no stock, move it to one of its variants")
+	ErrStockHeld         = errors.New("stock can not go below the qty held by active reservations")
+)
+
+// StockReason tells why the stock of a product has moved, the first three can be used
+// to adjust the stock by hand, the others are recorded by the service itself
+type StockReason string
+
+const (
+	StockRestock    StockReason = "restock"
+	StockDamage     StockReason = "damage"
+	StockReturn     StockReason = "return"
+	StockInitial    StockReason = "initial"
+	StockCorrection StockReason = "correction"
+	StockOrder      StockReason = "order"
+	StockRelease    StockReason = "release"
+)
+
+// Valid reports whether delta is an adjustment the reason allows, a restock or return
+// adds stock and damage takes it out
+func (r StockReason) Valid(delta int) bool {
+	switch r {
+	case StockRestock, StockReturn:
+		return delta > 0
+	case StockDamage:
+		return delta < 0
+	}
+	return false
+}
+
+// Product is sold at Price while Stock lasts. CategoryID is nil for a product outside the
+// category tree, Attributes are free key/value details like a color or a size.
+//
+// A product with Variants is only a parent grouping its SKUs, e.g. the RAM and storage
+// configurations of a laptop. Each variant is a product of its own with ParentID set,
+// its own price and stock, and is the one that is ordered.
+//
+// Available is the stock left once the active reservations are taken out, it is only
+// read and never written.
 type Product struct {
-	ID        uint32 `json:"id"`
-	Name      string `json:"name"`
-	Price     int    `json:"price"`
-	Stock     int    `json:"stock"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         uint32            `json:"id"`
+	ParentID   *uint32           `json:"parent_id"`
+	Name       string            `json:"name"`
+	Price      money.Money       `json:"price"`
+	Stock      int               `json:"stock"`
+	Available  int               `json:"available"`
+	CategoryID *uint32           `json:"category_id"`
+	Attributes map[string]string `json:"attributes"`
+	Variants   []Product         `json:"variants,omitempty"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Limits of the product_attribute columns
+const (
+	MaxAttributeName  = 64
+	MaxAttributeValue = 255
+)
+
+// StockMovement is a row of the stock ledger, the stock of a product is the sum of its deltas
+type StockMovement struct {
+	ID        uint32      `json:"id"`
+	ProductID uint32      `json:"product_id"`
+	Delta     int         `json:"delta"`
+	Reason    StockReason `json:"reason"`
+	CreatedAt time.Time   `json:"created_at"`
 }
 
 type ProductOrder struct {
@@ -19,20 +101,89 @@ type ProductOrder struct {
 	Qty int    `json:"qty"`
 }
 
+// OrderItem is a qty of a product held for an order
+type OrderItem struct {
+	ProductID uint32 `json:"product_id"`
+	Qty       int    `json:"qty"`
+}
+
+// OrderCreated is consumed from OrderQueue when the order service stores a pending order,
+// ProductID and Qty are only set by order services placing orders of a single product
+type OrderCreated struct {
+	OrderID   uint32      `json:"order_id"`
+	Items     []OrderItem `json:"items"`
+	ProductID uint32      `json:"product_id,omitempty"`
+	Qty       int         `json:"qty,omitempty"`
+}
+
+// OrderItems will return the items of the order, an order of a single product is one item
+func (e *OrderCreated) OrderItems() []OrderItem {
+	if len(e.Items) == 0 && e.ProductID != 0 {
+		return []OrderItem{{ProductID: e.ProductID, Qty: e.Qty}}
+	}
+	return e.Items
+}
+
+// OrderReply is published to OrderReplyQueue with the outcome of the stock reservation,
+// Reason is one of the reason codes below when the order is rejected
+type OrderReply struct {
+	OrderID  uint32 `json:"order_id"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// The reasons an order is rejected for, codes the order service can rely on whatever the
+// message of the error behind them
+const (
+	ReasonInsufficientStock = "insufficient_stock"
+	ReasonProductNotFound   = "product_not_found"
+	ReasonVariantRequired   = "variant_required"
+	ReasonInvalidItems      = "invalid_items"
+)
+
+// ProductSorts are the fields a list of products can be sorted by, besides id
+var ProductSorts = []string{"name", "price", "stock", "created_at"}
+
+// ProductFilter narrows a list of products, Name matches the start of the product names
+// and CategoryID keeps the products of a category and of its subcategories. A field left
+// empty does not filter.
+type ProductFilter struct {
+	Name       string
+	Currency   string
+	InStock    bool
+	CategoryID uint32
+}
+
+// ProductSearch looks up products by the words of their name, the best matches first.
+// MinPrice and MaxPrice bound the price in the minor unit of Currency, a bound left nil
+// does not filter.
+type ProductSearch struct {
+	Terms    string
+	Currency string
+	MinPrice *int64
+	MaxPrice *int64
+	InStock  bool
+	Limit    int
+}
+
 type ProductRepository interface {
-	Fetch(ctx context.Context) (products []Product, err error)
+	Fetch(ctx context.Context, filter ProductFilter, q pagination.Query) (products []Product, next string, err error)
+	Search(ctx context.Context, search ProductSearch) ([]Product, error)
 	GetByID(ctx context.Context, id uint32) (product Product, err error)
 	Store(ctx context.Context, product *Product) error
 	Update(ctx context.Context, product *Product, id uint32) error
 	Delete(ctx context.Context, id uint32) error
-	UpdateStock(ctx context.Context, product *Product, id uint32) error
+	AdjustStock(ctx context.Context, movement *StockMovement) error
+	FetchMovements(ctx context.Context, id uint32) ([]StockMovement, error)
 }
 
 type ProductService interface {
-	Fetch(ctx context.Context) ([]Product, error)
+	Fetch(ctx context.Context, filter ProductFilter, q pagination.Query) ([]Product, string, error)
+	Search(ctx context.Context, search ProductSearch) ([]Product, error)
 	GetByID(ctx context.Context, id uint32) (Product, error)
 	Store(context.Context, *Product) error
 	Update(ctx context.Context, product *Product, id uint32) error
 	Delete(ctx context.Context, id uint32) error
-	UpdateStock(ctx context.Context, product *Product, id uint32) error
+	AdjustStock(ctx context.Context, movement *StockMovement) error
+	FetchMovements(ctx context.Context, id uint32) ([]StockMovement, error)
 }
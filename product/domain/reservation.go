@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrReservationClosed  = errors.New("reservation is no longer active")
+	ErrReservationExpired = errors.New("reservation has expired")
+	ErrOrderHandled       = errors.New("order has already been handled")
+)
+
+// ReservationStatus is where a reservation stands, only an active one holds stock
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationCommitted ReservationStatus = "committed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds Qty of a product until ExpiresAt without taking it out of the stock,
+// the stock is only taken when the reservation is committed. The available stock of a
+// product is its stock minus the qty of its active reservations. The reservations held
+// for an order carry its OrderID and are committed or released together.
+type Reservation struct {
+	ID        uint32            `json:"id"`
+	OrderID   uint32            `json:"order_id,omitempty"`
+	ProductID uint32            `json:"product_id"`
+	Qty       int               `json:"qty"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type ReservationRepository interface {
+	GetByID(ctx context.Context, id uint32) (Reservation, error)
+	Hold(ctx context.Context, reservation *Reservation) error
+	Commit(ctx context.Context, id uint32, now time.Time) error
+	Release(ctx context.Context, id uint32) error
+	GetOrderReply(ctx context.Context, orderID uint32) (OrderReply, error)
+	HoldOrder(ctx context.Context, orderID uint32, items []OrderItem, expiresAt time.Time) error
+	RejectOrder(ctx context.Context, orderID uint32, reason string) error
+	CommitOrder(ctx context.Context, orderID uint32, now time.Time) error
+	ReleaseOrder(ctx context.Context, orderID uint32) error
+	Expire(ctx context.Context, now time.Time) (int64, error)
+}
+
+type ReservationService interface {
+	GetByID(ctx context.Context, id uint32) (Reservation, error)
+	Hold(ctx context.Context, reservation *Reservation) error
+	Commit(ctx context.Context, id uint32) error
+	Release(ctx context.Context, id uint32) error
+	HoldOrder(ctx context.Context, orderID uint32, items []OrderItem) (OrderReply, error)
+	CommitOrder(ctx context.Context, orderID uint32) error
+	ReleaseOrder(ctx context.Context, orderID uint32) error
+	Expire(ctx context.Context) (int64, error)
+}
@@ -7,7 +7,9 @@ require (
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/labstack/echo/v4 v4.2.2
 	github.com/spf13/viper v1.7.1
-	github.com/streadway/amqp v1.0.0 // indirect
-	github.com/stretchr/testify v1.7.0
-	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
+	platform v0.0.0
 )
+
+replace platform => ../platform
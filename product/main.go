@@ -1,85 +1,150 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
 	"log"
-	"net/url"
+	"os"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/viper"
 
+	_broker "platform/broker"
+	"platform/config"
+	"platform/database"
+	"platform/health"
+	"platform/idempotency"
+	"platform/metrics"
+	"platform/migrate"
+	"platform/server"
+	"platform/tracing"
+
+	_productConsumer "product/consumer"
 	_productController "product/controller"
+	_productMigrations "product/migrations"
 	_productRepo "product/repository"
 	_productService "product/service"
 )
 
 func init() {
-	viper.SetConfigFile(`config.json`)
-	err := viper.ReadInConfig()
+	err := config.Load(`config.json`)
 	if err != nil {
 		panic(err)
 	}
-
-	if viper.GetBool(`debug`) {
-		log.Println("Service RUN on DEBUG mode")
-	}
 }
 
 func main() {
-	// Setup database
-	dbHost := viper.GetString(`database.host`)
-	dbPort := viper.GetString(`database.port`)
-	dbUser := viper.GetString(`database.user`)
-	dbPass := viper.GetString(`database.pass`)
-	dbName := viper.GetString(`database.name`)
-	connection := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPass, dbHost, dbPort, dbName)
-	val := url.Values{}
-	val.Add("parseTime", "1")
-	val.Add("loc", "Asia/Jakarta")
-	dsn := fmt.Sprintf("%s?%s", connection, val.Encode())
-	dbConn, err := sql.Open(`mysql`, dsn)
-
+	// Setup tracing, the spans left are flushed once everything else is closed
+	shutdownTracing, err := tracing.Setup(tracing.NewConfig(), `product`)
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = dbConn.Ping()
+
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	// Setup database
+	dbConn, err := database.Open(database.NewConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	defer func() {
 		err := dbConn.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	// Setup migrations, `binary migrate up|down [steps]|status` only runs them
+	migrator, err := migrate.New(dbConn, _productMigrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = migrate.Command(context.Background(), migrator, os.Args[2:], os.Stdout)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if viper.GetBool(`database.migrate`) {
+		applied, err := migrator.Up(context.Background())
 		if err != nil {
 			log.Fatal(err)
 		}
+		for _, migration := range applied {
+			log.Printf("applied migration %04d_%s", migration.Version, migration.Name)
+		}
+	}
+
+	// Setup message broker
+	broker, err := _broker.NewRabbitMQ(viper.GetString(`rabbitmq.url`))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer func() {
+		err := broker.Close()
+		if err != nil {
+			log.Println(err)
+		}
 	}()
 
 	// Setup Echo
-	e := echo.New()
-
-	// Setup middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
+	e := server.New()
 
 	// Setup Product Repository
 	productRepo := _productRepo.NewProductRepository(dbConn)
+	categoryRepo := _productRepo.NewCategoryRepository(dbConn)
+	idempotencyConfig := idempotency.NewConfig()
+	idempotencyRepo := idempotency.NewRepository(dbConn, idempotencyConfig)
+	reservationRepo := _productRepo.NewReservationRepository(dbConn)
+
+	// Handle POST requests retried with the same Idempotency-Key only once
+	e.Use(idempotency.Middleware(idempotencyRepo))
 
 	// Setup Product Service
-	timeoutContext := time.Duration(viper.GetInt("context.timeout")) * time.Second
+	timeoutContext := config.ContextTimeout()
 	productService := _productService.NewProductService(productRepo, timeoutContext)
+	categoryService := _productService.NewCategoryService(categoryRepo, timeoutContext)
+	reservationService := _productService.NewReservationService(reservationRepo,
+		time.Duration(viper.GetInt(`reservation.ttl`))*time.Second, timeoutContext)
 
 	// Setup Product Controller
 	_productController.NewProductController(e, productService)
+	_productController.NewCategoryController(e, categoryService)
+	_productController.NewReservationController(e, reservationService)
+
+	// Setup Health Controller
+	health.NewHealthController(e, health.Check{Name: "database", Checker: health.DB(dbConn)})
+	database.NewStatsController(e, dbConn)
+	metrics.NewMetricsController(e, metrics.DB(dbConn, viper.GetString(`database.name`)))
+
+	// Setup Order Consumer
+	err = _productConsumer.NewOrderConsumer(broker, reservationService)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Expire the reservations left past their ttl until the server stops
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	go _productService.RunReaper(reaperCtx, reservationService,
+		time.Duration(viper.GetInt(`reservation.reapinterval`))*time.Second)
 
-	// CORS
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
-	}))
+	// Purge the idempotency keys past their retention until the server stops
+	purgerCtx, stopPurger := context.WithCancel(context.Background())
+	defer stopPurger()
+	go idempotency.RunPurger(purgerCtx, idempotencyRepo, idempotencyConfig)
 
-	log.Fatal(e.Start(viper.GetString("server.address")))
+	// Serve until SIGTERM, the deferred closes run once the requests in flight are done
+	err = server.Run(e, viper.GetString("server.address"), config.ShutdownTimeout())
+	if err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,9 @@
+-- stock stays signed, the conditional updates compute stock-qty before comparing it
+CREATE TABLE IF NOT EXISTS product (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	price INT NOT NULL,
+	stock INT NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS stock_movement (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	product_id INT UNSIGNED NOT NULL,
+	delta INT NOT NULL,
+	reason VARCHAR(32) NOT NULL,
+	created_at DATETIME NOT NULL,
+	INDEX stock_movement_product (product_id, id)
+);
+
+-- The stock of existing products opens the ledger, so it always adds up to the stock
+INSERT INTO stock_movement (product_id, delta, reason, created_at)
+SELECT id, stock, 'initial', NOW() FROM product WHERE stock <> 0;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS idempotency (
+	idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+	request_hash CHAR(64) NOT NULL,
+	status_code INT NOT NULL DEFAULT 0,
+	content_type VARCHAR(255) NOT NULL DEFAULT '',
+	body MEDIUMBLOB NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
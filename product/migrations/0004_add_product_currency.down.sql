@@ -0,0 +1,4 @@
+UPDATE product SET price=price DIV 100;
+
+ALTER TABLE product DROP COLUMN IF EXISTS currency;
+ALTER TABLE product MODIFY COLUMN price INT NOT NULL;
@@ -0,0 +1,5 @@
+-- Prices were whole rupiah, they are now kept in the minor unit of their currency
+ALTER TABLE product MODIFY COLUMN price BIGINT NOT NULL;
+ALTER TABLE product ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER price;
+
+UPDATE product SET price=price*100;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS product_created ON product;
+DROP INDEX IF EXISTS product_price ON product;
+DROP INDEX IF EXISTS product_name ON product;
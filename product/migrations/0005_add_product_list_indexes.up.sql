@@ -0,0 +1,4 @@
+-- Lists of products are paged by sort column then id
+CREATE INDEX IF NOT EXISTS product_name ON product (name, id);
+CREATE INDEX IF NOT EXISTS product_price ON product (price, id);
+CREATE INDEX IF NOT EXISTS product_created ON product (created_at, id);
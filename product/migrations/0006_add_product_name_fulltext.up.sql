@@ -0,0 +1,2 @@
+-- Products are searched by the words of their name, ranked by relevance
+CREATE FULLTEXT INDEX IF NOT EXISTS product_name_text ON product (name);
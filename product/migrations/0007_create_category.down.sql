@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS product_attribute;
+DROP INDEX IF EXISTS product_category ON product;
+ALTER TABLE product DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS category;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS category (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	parent_id INT UNSIGNED NULL,
+	name VARCHAR(255) NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	INDEX category_parent (parent_id)
+);
+
+ALTER TABLE product ADD COLUMN IF NOT EXISTS category_id INT UNSIGNED NULL AFTER stock;
+CREATE INDEX IF NOT EXISTS product_category ON product (category_id, id);
+
+-- The attributes of a product go away with it
+CREATE TABLE IF NOT EXISTS product_attribute (
+	product_id INT UNSIGNED NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	value VARCHAR(255) NOT NULL,
+	PRIMARY KEY (product_id, name),
+	CONSTRAINT product_attribute_product FOREIGN KEY (product_id) REFERENCES product (id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS product_parent ON product;
+ALTER TABLE product DROP COLUMN IF EXISTS parent_id;
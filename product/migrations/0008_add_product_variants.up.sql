@@ -0,0 +1,3 @@
+-- A variant is a product of its own under a parent product, which is not ordered itself
+ALTER TABLE product ADD COLUMN IF NOT EXISTS parent_id INT UNSIGNED NULL AFTER id;
+CREATE INDEX IF NOT EXISTS product_parent ON product (parent_id, id);
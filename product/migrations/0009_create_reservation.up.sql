@@ -0,0 +1,12 @@
+-- A reservation holds stock until it expires, the stock is only taken once it is committed
+CREATE TABLE IF NOT EXISTS reservation (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	product_id INT UNSIGNED NOT NULL,
+	qty INT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	INDEX reservation_product (product_id, status, expires_at),
+	INDEX reservation_expiry (status, expires_at)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idempotency_updated ON idempotency;
@@ -0,0 +1,2 @@
+-- Old keys are purged by the time they were last updated
+CREATE INDEX IF NOT EXISTS idempotency_updated ON idempotency (updated_at);
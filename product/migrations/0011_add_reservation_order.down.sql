@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reservation_order ON reservation;
+ALTER TABLE reservation DROP COLUMN IF EXISTS order_id;
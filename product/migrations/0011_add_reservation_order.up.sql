@@ -0,0 +1,3 @@
+-- The reservations of an order are held, committed and released together, 0 is no order
+ALTER TABLE reservation ADD COLUMN IF NOT EXISTS order_id INT UNSIGNED NOT NULL DEFAULT 0 AFTER id;
+CREATE INDEX IF NOT EXISTS reservation_order ON reservation (order_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS handled_order;
@@ -0,0 +1,8 @@
+-- The reply sent for every order the service has handled, so an order delivered again
+-- gets the same reply instead of holding its stock twice
+CREATE TABLE IF NOT EXISTS handled_order (
+	order_id INT UNSIGNED NOT NULL PRIMARY KEY,
+	accepted BOOLEAN NOT NULL,
+	reason VARCHAR(64) NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL
+);
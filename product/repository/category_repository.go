@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"product/domain"
+	"time"
+)
+
+type categoryRepository struct {
+	Conn *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) domain.CategoryRepository {
+	return &categoryRepository{
+		Conn: db,
+	}
+}
+
+// Fetch will return every category, the parents before their subcategories when they
+// were created first
+func (cr *categoryRepository) Fetch(ctx context.Context) (categories []domain.Category, err error) {
+	query := `SELECT id, parent_id, name, created_at, updated_at FROM category ORDER BY id`
+
+	rows, err := cr.Conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories = make([]domain.Category, 0)
+	for rows.Next() {
+		c := domain.Category{}
+		err = rows.Scan(&c.ID, &c.ParentID, &c.Name, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (cr *categoryRepository) GetByID(ctx context.Context, id uint32) (category domain.Category, err error) {
+	query := `SELECT id, parent_id, name, created_at, updated_at FROM category WHERE id=?`
+
+	err = cr.Conn.QueryRowContext(ctx, query, id).
+		Scan(&category.ID, &category.ParentID, &category.Name, &category.CreatedAt, &category.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
+	return
+}
+
+func (cr *categoryRepository) Store(ctx context.Context, category *domain.Category) (err error) {
+	query := `INSERT INTO category (parent_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)`
+
+	return transaction(ctx, cr.Conn, func(tx *sql.Tx) (err error) {
+		err = checkCategory(ctx, tx, category.ParentID)
+		if err != nil {
+			return
+		}
+
+		t := time.Now()
+		ts := t.Format("2006-01-02 15:04:05")
+		res, err := tx.ExecContext(ctx, query, category.ParentID, category.Name, ts, ts)
+		if err != nil {
+			return
+		}
+
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return
+		}
+		category.ID = uint32(lastID)
+		category.CreatedAt = t
+		category.UpdatedAt = t
+		return
+	})
+}
+
+// Update will rename a category or move it under another parent, refusing to move it
+// under itself or one of its subcategories
+func (cr *categoryRepository) Update(ctx context.Context, category *domain.Category, id uint32) (err error) {
+	query := `UPDATE category SET parent_id=?, name=?, updated_at=? WHERE id=?`
+
+	return transaction(ctx, cr.Conn, func(tx *sql.Tx) (err error) {
+		err = lockCategory(ctx, tx, id)
+		if err != nil {
+			return
+		}
+
+		if category.ParentID != nil {
+			err = checkParent(ctx, tx, *category.ParentID, id)
+			if err != nil {
+				return
+			}
+		}
+
+		t := time.Now()
+		ts := t.Format("2006-01-02 15:04:05")
+		_, err = tx.ExecContext(ctx, query, category.ParentID, category.Name, ts, id)
+		if err != nil {
+			return
+		}
+		category.ID = id
+		category.UpdatedAt = t
+		return
+	})
+}
+
+// Delete will remove a category that has neither subcategories nor products left
+func (cr *categoryRepository) Delete(ctx context.Context, id uint32) (err error) {
+	return transaction(ctx, cr.Conn, func(tx *sql.Tx) (err error) {
+		err = lockCategory(ctx, tx, id)
+		if err != nil {
+			return
+		}
+
+		var used int
+		err = tx.QueryRowContext(ctx, `SELECT (SELECT COUNT(*) FROM category WHERE parent_id=?) + (SELECT COUNT(*) FROM product WHERE category_id=?)`, id, id).Scan(&used)
+		if err != nil {
+			return
+		}
+		if used > 0 {
+			return domain.ErrCategoryInUse
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM category WHERE id=?`, id)
+		return
+	})
+}
+
+// lockCategory will lock the row of a category until the transaction ends
+func lockCategory(ctx context.Context, tx *sql.Tx, id uint32) (err error) {
+	var locked uint32
+	err = tx.QueryRowContext(ctx, `SELECT id FROM category WHERE id=? FOR UPDATE`, id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
+	return
+}
+
+// checkParent will walk up from parentID to the root, the category id can not be found on
+// the way or it would become its own ancestor. Every category on the way is locked, so a
+// concurrent move can not close a cycle behind the check
+func checkParent(ctx context.Context, tx *sql.Tx, parentID, id uint32) (err error) {
+	query := `SELECT parent_id FROM category WHERE id=? FOR UPDATE`
+
+	next := &parentID
+	for next != nil {
+		if *next == id {
+			return domain.ErrCategoryCycle
+		}
+
+		current := *next
+		next = nil
+		err = tx.QueryRowContext(ctx, query, current).Scan(&next)
+		if err == sql.ErrNoRows {
+			return domain.ErrUnknownCategory
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,179 @@
+package repository_test
+
+import (
+	"context"
+	"product/domain"
+	"product/repository"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	categoryColumns   = []string{"id", "parent_id", "name", "created_at", "updated_at"}
+	lockCategoryQuery = regexp.QuoteMeta(`SELECT id FROM category WHERE id=? FOR UPDATE`)
+)
+
+func TestCategoryRepository_Fetch(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewCategoryRepository(db)
+	defer db.Close()
+
+	rows := sqlmock.NewRows(categoryColumns).
+		AddRow(1, nil, "Electronics", t0, t0).
+		AddRow(2, 1, "Laptops", t0, t0)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, parent_id, name, created_at, updated_at FROM category ORDER BY id`)).WillReturnRows(rows)
+
+	list, err := repo.Fetch(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Nil(t, list[0].ParentID)
+	assert.Equal(t, uint32(1), *list[1].ParentID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCategoryRepository_GetByID(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewCategoryRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta(`SELECT id, parent_id, name, created_at, updated_at FROM category WHERE id=?`)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(query).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows(categoryColumns).AddRow(2, 1, "Laptops", t0, t0))
+
+		category, err := repo.GetByID(context.TODO(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "Laptops", category.Name)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(query).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows(categoryColumns))
+
+		_, err := repo.GetByID(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCategoryRepository_Store(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewCategoryRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta(`INSERT INTO category (parent_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)`)
+	parentID := uint32(1)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(categoryQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(parentID))
+		mock.ExpectExec(query).WithArgs(parentID, "Laptops", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		category := domain.Category{ParentID: &parentID, Name: "Laptops"}
+		err := repo.Store(context.TODO(), &category)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(2), category.ID)
+	})
+
+	t.Run("unknown parent", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(categoryQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		err := repo.Store(context.TODO(), &domain.Category{ParentID: &parentID, Name: "Laptops"})
+		assert.Equal(t, domain.ErrUnknownCategory, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCategoryRepository_Update(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewCategoryRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta(`UPDATE category SET parent_id=?, name=?, updated_at=? WHERE id=?`)
+	ancestorQuery := regexp.QuoteMeta(`SELECT parent_id FROM category WHERE id=? FOR UPDATE`)
+
+	t.Run("move", func(t *testing.T) {
+		parentID := uint32(3)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCategoryQuery).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		// Each ancestor up to the root is locked on the way
+		mock.ExpectQuery(ancestorQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(1))
+		mock.ExpectQuery(ancestorQuery).WithArgs(uint32(1)).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+		mock.ExpectExec(query).WithArgs(parentID, "Laptops", sqlmock.AnyArg(), uint32(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Update(context.TODO(), &domain.Category{ParentID: &parentID, Name: "Laptops"}, 2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("under a subcategory", func(t *testing.T) {
+		parentID := uint32(4)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCategoryQuery).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(ancestorQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(2))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), &domain.Category{ParentID: &parentID, Name: "Laptops"}, 2)
+		assert.Equal(t, domain.ErrCategoryCycle, err)
+	})
+
+	t.Run("unknown parent", func(t *testing.T) {
+		parentID := uint32(9)
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCategoryQuery).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(ancestorQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), &domain.Category{ParentID: &parentID, Name: "Laptops"}, 2)
+		assert.Equal(t, domain.ErrUnknownCategory, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCategoryQuery).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		err := repo.Update(context.TODO(), &domain.Category{Name: "Laptops"}, 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCategoryRepository_Delete(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewCategoryRepository(db)
+	defer db.Close()
+
+	usedQuery := regexp.QuoteMeta(`SELECT (SELECT COUNT(*) FROM category WHERE parent_id=?) + (SELECT COUNT(*) FROM product WHERE category_id=?)`)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCategoryQuery).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(usedQuery).WithArgs(uint32(2), uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM category WHERE id=?`)).WithArgs(uint32(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Delete(context.TODO(), 2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("in use", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockCategoryQuery).WithArgs(uint32(1)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(usedQuery).WithArgs(uint32(1), uint32(1)).WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(3))
+		mock.ExpectRollback()
+
+		err := repo.Delete(context.TODO(), 1)
+		assert.Equal(t, domain.ErrCategoryInUse, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,14 +5,22 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"platform/pagination"
 	"product/domain"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var (
-	t  = time.Now()
-	ts = t.Format("2006-01-02 15:04:05")
-)
+// productColumns are the columns a product is read from, the available stock takes the
+// current time as first argument to leave the expired reservations out
+const productColumns = `id, parent_id, name, price, currency, stock, ` + availableColumn + `, category_id, created_at, updated_at`
+
+const availableColumn = `stock-` + heldColumn
+
+// heldColumn is the qty of a product held by active reservations, the current time is its argument
+const heldColumn = `COALESCE((SELECT SUM(qty) FROM reservation WHERE reservation.product_id=product.id AND reservation.status='active' AND reservation.expires_at>?), 0)`
 
 type productRepository struct {
 	Conn *sql.DB
@@ -24,110 +32,494 @@ func NewProductRepository(db *sql.DB) domain.ProductRepository {
 	}
 }
 
-func (pr *productRepository) Fetch(ctx context.Context) (products []domain.Product, err error) {
-	query := `SELECT id, name, price, stock, created_at, updated_at FROM product`
-	rows, err := pr.Conn.QueryContext(ctx, query)
+// Fetch will return a page of the products matching the filter, and the cursor of the
+// next page when there is one
+func (pr *productRepository) Fetch(ctx context.Context, filter domain.ProductFilter, q pagination.Query) (products []domain.Product, next string, err error) {
+	var conds []string
+	var args []interface{}
+	if filter.Name != "" {
+		conds = append(conds, `name LIKE ?`)
+		args = append(args, likePrefix(filter.Name))
+	}
+	if filter.Currency != "" {
+		conds = append(conds, `currency=?`)
+		args = append(args, filter.Currency)
+	}
+	if filter.InStock {
+		// Stock held by reservations can not be ordered, so it does not count
+		conds = append(conds, `stock>`+heldColumn)
+		args = append(args, now())
+	}
+	if filter.CategoryID != 0 {
+		conds = append(conds, `category_id IN (WITH RECURSIVE subcategory AS (SELECT id FROM category WHERE id=? UNION SELECT c.id FROM category c JOIN subcategory s ON c.parent_id=s.id) SELECT id FROM subcategory)`)
+		args = append(args, filter.CategoryID)
+	}
+
+	keyset, keysetArgs, orderBy := q.Keyset()
+	query := `SELECT ` + productColumns + ` FROM product` + pagination.Where(append(conds, keyset)...) + orderBy
+	args = append([]interface{}{now()}, append(args, keysetArgs...)...)
+	rows, err := pr.Conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, "", err
 	}
-	defer func() {
-		errRow := rows.Close()
-		if errRow != nil {
-			log.Fatal(errRow)
+	defer rows.Close()
+
+	products = make([]domain.Product, 0)
+	for rows.Next() {
+		p := domain.Product{}
+		err = rows.Scan(&p.ID, &p.ParentID, &p.Name, &p.Price.Amount, &p.Price.Currency, &p.Stock, &p.Available, &p.CategoryID, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, "", err
 		}
-	}()
+		products = append(products, p)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if q.More(len(products)) {
+		products = products[:q.Limit]
+		last := products[q.Limit-1]
+		next = q.Cursor(sortValue(last, q.Sort), last.ID)
+	}
+
+	err = pr.fetchAttributes(ctx, products)
+	if err != nil {
+		return nil, "", err
+	}
+	return
+}
+
+// Search will return the products whose name matches the search terms through the
+// FULLTEXT index, ordered by relevance then id
+func (pr *productRepository) Search(ctx context.Context, search domain.ProductSearch) (products []domain.Product, err error) {
+	conds := []string{`MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE)`}
+	args := []interface{}{now(), search.Terms, search.Terms}
+	if search.Currency != "" {
+		conds = append(conds, `currency=?`)
+		args = append(args, search.Currency)
+	}
+	if search.MinPrice != nil {
+		conds = append(conds, `price>=?`)
+		args = append(args, *search.MinPrice)
+	}
+	if search.MaxPrice != nil {
+		conds = append(conds, `price<=?`)
+		args = append(args, *search.MaxPrice)
+	}
+	if search.InStock {
+		conds = append(conds, `stock>`+heldColumn)
+		args = append(args, now())
+	}
+
+	query := `SELECT ` + productColumns + `, MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE) AS score FROM product` +
+		pagination.Where(conds...) + fmt.Sprintf(` ORDER BY score DESC, id ASC LIMIT %d`, search.Limit)
+	rows, err := pr.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	products = make([]domain.Product, 0)
 	for rows.Next() {
 		p := domain.Product{}
-		err = rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CreatedAt, &p.UpdatedAt)
+		var score float64
+		err = rows.Scan(&p.ID, &p.ParentID, &p.Name, &p.Price.Amount, &p.Price.Currency, &p.Stock, &p.Available, &p.CategoryID, &p.CreatedAt, &p.UpdatedAt, &score)
 		if err != nil {
-			log.Fatal(err)
 			return nil, err
 		}
 		products = append(products, p)
 	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
 
+	err = pr.fetchAttributes(ctx, products)
+	if err != nil {
+		return nil, err
+	}
 	return
 }
 
+// fetchAttributes will load the attributes of the products with a single query
+func (pr *productRepository) fetchAttributes(ctx context.Context, products []domain.Product) (err error) {
+	if len(products) == 0 {
+		return
+	}
+
+	index := make(map[uint32]int, len(products))
+	args := make([]interface{}, len(products))
+	for i, p := range products {
+		index[p.ID] = i
+		args[i] = p.ID
+	}
+
+	query := "SELECT product_id, name, value FROM product_attribute WHERE product_id IN (?" +
+		strings.Repeat(", ?", len(products)-1) + ") ORDER BY product_id, name"
+	rows, err := pr.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for i := range products {
+		products[i].Attributes = make(map[string]string)
+	}
+	for rows.Next() {
+		var productID uint32
+		var name, value string
+		err = rows.Scan(&productID, &name, &value)
+		if err != nil {
+			return
+		}
+		products[index[productID]].Attributes[name] = value
+	}
+	return rows.Err()
+}
+
+// sortValue will return the value of the sort field of product, as a cursor holds it
+func sortValue(product domain.Product, sort string) string {
+	switch sort {
+	case "name":
+		return product.Name
+	case "price":
+		return strconv.FormatInt(product.Price.Amount, 10)
+	case "stock":
+		return strconv.Itoa(product.Stock)
+	case "created_at":
+		return product.CreatedAt.Format(pagination.TimeLayout)
+	}
+	return ""
+}
+
+// likePrefix will make a LIKE pattern matching the strings starting with s
+func likePrefix(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
+
 func (pr *productRepository) GetByID(ctx context.Context, id uint32) (product domain.Product, err error) {
-	query := `SELECT id, name, price, stock, created_at, updated_at FROM product WHERE id=?`
+	query := `SELECT ` + productColumns + ` FROM product WHERE id=?`
 
 	stmt, err := pr.Conn.PrepareContext(ctx, query)
 	if err != nil {
 		return domain.Product{}, err
 	}
 
-	row := stmt.QueryRowContext(ctx, id)
+	row := stmt.QueryRowContext(ctx, now(), id)
 	product = domain.Product{}
 
 	err = row.Scan(
 		&product.ID,
+		&product.ParentID,
 		&product.Name,
-		&product.Price,
+		&product.Price.Amount,
+		&product.Price.Currency,
 		&product.Stock,
+		&product.Available,
+		&product.CategoryID,
 		&product.CreatedAt,
 		&product.UpdatedAt)
-	return
-}
-
-func (pr *productRepository) Store(ctx context.Context, product *domain.Product) (err error) {
-	query := `INSERT INTO product (name, price, stock, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
-	stmt, err := pr.Conn.PrepareContext(ctx, query)
+	if err == sql.ErrNoRows {
+		return domain.Product{}, domain.ErrNotFound
+	}
 	if err != nil {
 		return
 	}
 
-	res, err := stmt.ExecContext(ctx, product.Name, product.Price, product.Stock, ts, ts)
+	variants, err := pr.fetchVariants(ctx, id)
 	if err != nil {
 		return
 	}
 
-	lastID, err := res.LastInsertId()
+	// The attributes of the product and of its variants are loaded at once
+	products := append([]domain.Product{product}, variants...)
+	err = pr.fetchAttributes(ctx, products)
 	if err != nil {
 		return
 	}
-	product.ID = uint32(lastID)
+	product = products[0]
+	if len(variants) > 0 {
+		product.Variants = products[1:]
+	}
 	return
 }
 
+// fetchVariants will return the variants of a product, oldest first
+func (pr *productRepository) fetchVariants(ctx context.Context, id uint32) (variants []domain.Product, err error) {
+	query := `SELECT ` + productColumns + ` FROM product WHERE parent_id=? ORDER BY id`
+
+	rows, err := pr.Conn.QueryContext(ctx, query, now(), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := domain.Product{}
+		err = rows.Scan(&p.ID, &p.ParentID, &p.Name, &p.Price.Amount, &p.Price.Currency, &p.Stock, &p.Available, &p.CategoryID, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, p)
+	}
+	return variants, rows.Err()
+}
+
+func (pr *productRepository) Store(ctx context.Context, product *domain.Product) (err error) {
+	query := `INSERT INTO product (parent_id, name, price, currency, stock, category_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	return transaction(ctx, pr.Conn, func(tx *sql.Tx) (err error) {
+		err = checkParentProduct(ctx, tx, product.ParentID)
+		if err != nil {
+			return
+		}
+
+		err = checkCategory(ctx, tx, product.CategoryID)
+		if err != nil {
+			return
+		}
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return
+		}
+
+		t := time.Now()
+		ts := t.Format("2006-01-02 15:04:05")
+		res, err := stmt.ExecContext(ctx, product.ParentID, product.Name, product.Price.Amount, product.Price.Currency, product.Stock, product.CategoryID, ts, ts)
+		if err != nil {
+			return
+		}
+
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return
+		}
+		product.ID = uint32(lastID)
+
+		err = insertAttributes(ctx, tx, product.ID, product.Attributes)
+		if err != nil {
+			return
+		}
+
+		return record(ctx, tx, &domain.StockMovement{
+			ProductID: product.ID,
+			Delta:     product.Stock,
+			Reason:    domain.StockInitial,
+		})
+	})
+}
+
 func (pr *productRepository) Update(ctx context.Context, product *domain.Product, id uint32) (err error) {
-	query := `UPDATE product SET name=?, price=?, stock=?, updated_at=? WHERE id=?`
+	query := `UPDATE product SET name=?, price=?, currency=?, stock=?, category_id=?, updated_at=? WHERE id=?`
+
+	return transaction(ctx, pr.Conn, func(tx *sql.Tx) (err error) {
+		stock, err := lockStock(ctx, tx, id)
+		if err != nil {
+			return
+		}
+
+		err = checkParentStock(ctx, tx, id, product.Stock)
+		if err != nil {
+			return
+		}
+
+		// The stock held by active reservations is promised to orders, it can not be taken away
+		if product.Stock < stock {
+			var held int
+			err = tx.QueryRowContext(ctx, `SELECT `+heldQuery, id, time.Now().Format("2006-01-02 15:04:05")).Scan(&held)
+			if err != nil {
+				return
+			}
+			if product.Stock < held {
+				return domain.ErrStockHeld
+			}
+		}
+
+		err = checkCategory(ctx, tx, product.CategoryID)
+		if err != nil {
+			return
+		}
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return
+		}
+
+		// The row is locked, so it exists even when nothing changed and no row is affected
+		t := time.Now()
+		ts := t.Format("2006-01-02 15:04:05")
+		_, err = stmt.ExecContext(ctx, product.Name, product.Price.Amount, product.Price.Currency, product.Stock, product.CategoryID, ts, id)
+		if err != nil {
+			return
+		}
+
+		// The attributes are replaced as a whole, like the other fields
+		_, err = tx.ExecContext(ctx, `DELETE FROM product_attribute WHERE product_id=?`, id)
+		if err != nil {
+			return
+		}
+		err = insertAttributes(ctx, tx, id, product.Attributes)
+		if err != nil {
+			return
+		}
+
+		return record(ctx, tx, &domain.StockMovement{
+			ProductID: id,
+			Delta:     product.Stock - stock,
+			Reason:    domain.StockCorrection,
+		})
+	})
+}
+
+// Delete will remove a product, a parent can only be removed once its variants are gone
+func (pr *productRepository) Delete(ctx context.Context, id uint32) (err error) {
+	query := `DELETE FROM product WHERE id=?`
+
+	return transaction(ctx, pr.Conn, func(tx *sql.Tx) (err error) {
+		err = checkVariants(ctx, tx, id)
+		if err != nil {
+			return
+		}
+
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return
+		}
+
+		result, err := stmt.ExecContext(ctx, id)
+		if err != nil {
+			return
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return
+		}
+
+		if rowsAffected == 0 {
+			return domain.ErrNotFound
+		}
+		if rowsAffected != 1 {
+			err = fmt.Errorf("total affected: %d", rowsAffected)
+			return
+		}
+
+		return
+	})
+}
+
+// AdjustStock will move the product stock by the movement delta with a single conditional
+// update, so concurrent adjustments can never take the stock below zero, and record the
+// movement in the stock ledger within the same transaction
+func (pr *productRepository) AdjustStock(ctx context.Context, movement *domain.StockMovement) (err error) {
+	return transaction(ctx, pr.Conn, func(tx *sql.Tx) error {
+		return adjust(ctx, tx, movement)
+	})
+}
+
+// FetchMovements will return the stock ledger of a product, oldest movement first
+func (pr *productRepository) FetchMovements(ctx context.Context, id uint32) (movements []domain.StockMovement, err error) {
+	query := `SELECT id, product_id, delta, reason, created_at FROM stock_movement WHERE product_id=? ORDER BY id`
 
 	stmt, err := pr.Conn.PrepareContext(ctx, query)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	result, err := stmt.ExecContext(ctx, product.Name, product.Price, product.Stock, ts, id)
+	rows, err := stmt.QueryContext(ctx, id)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements = make([]domain.StockMovement, 0)
+	for rows.Next() {
+		m := domain.StockMovement{}
+		err = rows.Scan(&m.ID, &m.ProductID, &m.Delta, &m.Reason, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	err = rows.Err()
+	if err != nil || len(movements) > 0 {
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// A product created without stock has no movements yet
+	var productID uint32
+	err = pr.Conn.QueryRowContext(ctx, `SELECT id FROM product WHERE id=?`, id).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	return
+}
+
+// transaction will run fn in a database transaction, committing it only when fn succeeds
+func transaction(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return
 	}
 
-	if rowsAffected != 1 {
-		err = fmt.Errorf("total affected: %d", rowsAffected)
+	err = fn(tx)
+	if err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			log.Println(errRollback)
+		}
 		return
 	}
 
+	return tx.Commit()
+}
+
+// lockStock will return the current stock of a product, locking its row until the
+// transaction ends
+func lockStock(ctx context.Context, tx *sql.Tx, id uint32) (stock int, err error) {
+	err = tx.QueryRowContext(ctx, `SELECT stock FROM product WHERE id=? FOR UPDATE`, id).Scan(&stock)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
 	return
 }
 
-func (pr *productRepository) Delete(ctx context.Context, id uint32) (err error) {
-	query := `DELETE FROM product WHERE id=?`
+// adjust will move the stock of a product by the movement delta within tx, the stock of
+// a parent product can not be ordered, only the one of its variants. Neither an order nor
+// a damage can take the stock held by active reservations, it is promised to orders.
+func adjust(ctx context.Context, tx *sql.Tx, movement *domain.StockMovement) (err error) {
+	query := `UPDATE product SET stock=stock+?, updated_at=? WHERE id=? AND stock+?>=0`
 
-	stmt, err := pr.Conn.PrepareContext(ctx, query)
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	args := []interface{}{movement.Delta, ts, movement.ProductID, movement.Delta}
+	// Released stock goes back to the variant an order took it from, nothing to check
+	if movement.Reason != domain.StockRelease {
+		err = checkParentStock(ctx, tx, movement.ProductID, movement.Delta)
+		if err != nil {
+			return
+		}
+	}
+	if movement.Reason == domain.StockOrder {
+		err = checkVariants(ctx, tx, movement.ProductID)
+		if err != nil {
+			return
+		}
+
+	}
+	if movement.Delta < 0 {
+		query = `UPDATE product SET stock=stock+?, updated_at=? WHERE id=? AND stock+?>=` + heldQuery
+		args = append(args, movement.ProductID, ts)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return
 	}
 
-	result, err := stmt.ExecContext(ctx, id)
+	result, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
 		return
 	}
@@ -138,37 +530,137 @@ func (pr *productRepository) Delete(ctx context.Context, id uint32) (err error)
 	}
 
 	if rowsAffected != 1 {
-		err = fmt.Errorf("total affected: %d", rowsAffected)
+		// Nothing was updated, either the product does not exist or its stock is too low
+		var productID uint32
+		err = tx.QueryRowContext(ctx, `SELECT id FROM product WHERE id=?`, movement.ProductID).Scan(&productID)
+		if err == sql.ErrNoRows {
+			return domain.ErrNotFound
+		}
+		if err != nil {
+			return
+		}
+		return domain.ErrInsufficientStock
+	}
+
+	return record(ctx, tx, movement)
+}
+
+// heldQuery is the qty held by the active reservations of a product at a time
+const heldQuery = `(SELECT COALESCE(SUM(qty), 0) FROM reservation WHERE product_id=? AND status='active' AND expires_at>?)`
+
+// now will format the current time as the DATETIME columns hold it
+func now() string {
+	return time.Now().Format("2006-01-02 15:04:05")
+}
+
+// checkVariants will fail with ErrHasVariants for a product that has variants
+func checkVariants(ctx context.Context, tx *sql.Tx, id uint32) (err error) {
+	var variants int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM product WHERE parent_id=?`, id).Scan(&variants)
+	if err != nil {
 		return
 	}
+	if variants > 0 {
+		return domain.ErrHasVariants
+	}
+	return
+}
 
+// checkParentProduct will lock the parent of a new variant, which must exist and not be a
+// variant itself, so variants are only one level deep. Its stock must be gone as well, it
+// could not be ordered once the product has variants
+func checkParentProduct(ctx context.Context, tx *sql.Tx, parentID *uint32) (err error) {
+	if parentID == nil {
+		return
+	}
+
+	var grandparentID *uint32
+	var stock int
+	err = tx.QueryRowContext(ctx, `SELECT parent_id, stock FROM product WHERE id=? FOR UPDATE`, *parentID).Scan(&grandparentID, &stock)
+	if err == sql.ErrNoRows || (err == nil && grandparentID != nil) {
+		return domain.ErrInvalidParent
+	}
+	if err == nil && stock > 0 {
+		return domain.ErrParentStock
+	}
 	return
 }
 
-func (pr *productRepository) UpdateStock(ctx context.Context, product *domain.Product, id uint32) (err error) {
-	query := `UPDATE product SET stock=?, updated_at=? WHERE id=?`
+// checkParentStock will fail with ErrParentStock when stock is added to a product that
+// has variants, nothing more is checked when the stock does not grow
+func checkParentStock(ctx context.Context, tx *sql.Tx, id uint32, stock int) (err error) {
+	if stock <= 0 {
+		return
+	}
 
-	stmt, err := pr.Conn.PrepareContext(ctx, query)
-	if err != nil {
+	err = checkVariants(ctx, tx, id)
+	if err == domain.ErrHasVariants {
+		err = domain.ErrParentStock
+	}
+	return
+}
+
+// checkCategory will fail with ErrUnknownCategory when the category of a product does not
+// exist, a product without category is fine. The category stays locked until the
+// transaction ends so it can not be deleted under the product
+func checkCategory(ctx context.Context, tx *sql.Tx, categoryID *uint32) (err error) {
+	if categoryID == nil {
 		return
 	}
 
-	t := time.Now()
-	ts := t.Format("2006-01-02 15:04:05")
-	result, err := stmt.ExecContext(ctx, product.Stock, ts, id)
-	if err != nil {
+	err = lockCategory(ctx, tx, *categoryID)
+	if err == domain.ErrNotFound {
+		err = domain.ErrUnknownCategory
+	}
+	return
+}
+
+// insertAttributes will store the attributes of a product with a single insert, in the
+// order of their names
+func insertAttributes(ctx context.Context, tx *sql.Tx, productID uint32, attributes map[string]string) (err error) {
+	if len(attributes) == 0 {
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]interface{}, 0, 3*len(names))
+	for _, name := range names {
+		args = append(args, productID, name, attributes[name])
+	}
+	query := "INSERT INTO product_attribute (product_id, name, value) VALUES (?, ?, ?)" + strings.Repeat(", (?, ?, ?)", len(names)-1)
+	_, err = tx.ExecContext(ctx, query, args...)
+	return
+}
+
+// record will append the movement to the stock ledger, a movement without delta is skipped
+func record(ctx context.Context, tx *sql.Tx, movement *domain.StockMovement) (err error) {
+	if movement.Delta == 0 {
+		return
+	}
+
+	query := `INSERT INTO stock_movement (product_id, delta, reason, created_at) VALUES (?, ?, ?, ?)`
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return
 	}
 
-	if rowsAffected != 1 {
-		err = fmt.Errorf("total affected: %d", rowsAffected)
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	res, err := stmt.ExecContext(ctx, movement.ProductID, movement.Delta, string(movement.Reason), ts)
+	if err != nil {
 		return
 	}
 
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+	movement.ID = uint32(lastID)
+	movement.CreatedAt = t
 	return
 }
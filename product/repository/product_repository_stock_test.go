@@ -0,0 +1,202 @@
+//go:build integration
+// +build integration
+
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"platform/migrate"
+	"platform/pagination"
+	"product/domain"
+	"product/migrations"
+	"product/repository"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStockDB opens the MariaDB database of PRODUCT_TEST_DSN, sqlmock can not show what
+// concurrent updates do to a row. The database is migrated and emptied, then holds a
+// single product with stock, whose id is returned
+func newStockDB(t *testing.T, stock int) (*sql.DB, uint32) {
+	dsn := os.Getenv("PRODUCT_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PRODUCT_TEST_DSN is not set, like root:secret@tcp(localhost:33062)/product_test?parseTime=true")
+	}
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	db.SetMaxOpenConns(16)
+
+	migrator, err := migrate.New(db, migrations.FS)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.TODO())
+	require.NoError(t, err)
+
+	for _, table := range []string{"handled_order", "reservation", "stock_movement", "product_attribute", "product"} {
+		_, err = db.Exec(`DELETE FROM ` + table)
+		require.NoError(t, err)
+	}
+
+	res, err := db.Exec(`INSERT INTO product (name, price, stock, created_at, updated_at) VALUES (?, ?, ?, NOW(), NOW())`, "Laptop Lenovo", 300000000, stock)
+	require.NoError(t, err)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+
+	return db, uint32(id)
+}
+
+func TestReservationRepository_HoldOrder_Concurrent(t *testing.T) {
+	const stock = 100
+	const orders = 500
+
+	db, id := newStockDB(t, stock)
+	defer db.Close()
+	repo := repository.NewReservationRepository(db)
+	expiresAt := time.Now().Add(time.Hour)
+
+	var mu sync.Mutex
+	held, rejected := 0, 0
+	accepted := make([]uint32, 0)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func(orderID uint32, qty int) {
+			defer wg.Done()
+			<-start
+
+			err := repo.HoldOrder(context.TODO(), orderID, []domain.OrderItem{{ProductID: id, Qty: qty}}, expiresAt)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				held += qty
+				accepted = append(accepted, orderID)
+			case domain.ErrInsufficientStock:
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(uint32(i+1), i%3+1)
+	}
+	close(start)
+	wg.Wait()
+
+	assert.LessOrEqual(t, held, stock, "the holds must never exceed the stock")
+	assert.Greater(t, held, stock-3, "rejections must only happen once the stock is nearly all held")
+	assert.NotZero(t, rejected)
+
+	// Every accepted order is paid, concurrently as well
+	start = make(chan struct{})
+	for _, orderID := range accepted {
+		wg.Add(1)
+		go func(orderID uint32) {
+			defer wg.Done()
+			<-start
+			assert.NoError(t, repo.CommitOrder(context.TODO(), orderID, time.Now()))
+		}(orderID)
+	}
+	close(start)
+	wg.Wait()
+
+	var left int
+	err := db.QueryRow(`SELECT stock FROM product WHERE id=?`, id).Scan(&left)
+	require.NoError(t, err)
+	assert.Equal(t, stock, held+left, "every held unit must come out of the stock exactly once")
+
+	var ledger int
+	err = db.QueryRow(`SELECT COALESCE(SUM(delta), 0) FROM stock_movement WHERE product_id=?`, id).Scan(&ledger)
+	require.NoError(t, err)
+	assert.Equal(t, -held, ledger, "the ledger must hold every paid order")
+}
+
+func TestReservationRepository_HoldOrder_Redelivered(t *testing.T) {
+	db, id := newStockDB(t, 10)
+	defer db.Close()
+	repo := repository.NewReservationRepository(db)
+	expiresAt := time.Now().Add(time.Hour)
+
+	// The same order delivered several times at once is held by one of them only
+	var mu sync.Mutex
+	accepted, handled := 0, 0
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			err := repo.HoldOrder(context.TODO(), 1, []domain.OrderItem{{ProductID: id, Qty: 2}}, expiresAt)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				accepted++
+			case domain.ErrOrderHandled:
+				handled++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, 1, accepted)
+	assert.Equal(t, 7, handled)
+
+	var held int
+	err := db.QueryRow(`SELECT COALESCE(SUM(qty), 0) FROM reservation WHERE order_id=1`).Scan(&held)
+	require.NoError(t, err)
+	assert.Equal(t, 2, held)
+
+	reply, err := repo.GetOrderReply(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderReply{OrderID: 1, Accepted: true}, reply)
+}
+
+func TestReservationRepository_Hold_HeldStock(t *testing.T) {
+	db, id := newStockDB(t, 10)
+	defer db.Close()
+	productRepo := repository.NewProductRepository(db)
+	repo := repository.NewReservationRepository(db)
+
+	future := time.Now().Add(time.Hour).Format("2006-01-02 15:04:05")
+	past := time.Now().Add(-time.Hour).Format("2006-01-02 15:04:05")
+	_, err := db.Exec(`INSERT INTO reservation (product_id, qty, status, expires_at, created_at, updated_at) VALUES (?, 6, 'active', ?, NOW(), NOW()), (?, 3, 'active', ?, NOW(), NOW()), (?, 4, 'committed', ?, NOW(), NOW())`,
+		id, future, id, past, id, future)
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	err = repo.Hold(context.TODO(), &domain.Reservation{ProductID: id, Qty: 5, ExpiresAt: expiresAt})
+	assert.Equal(t, domain.ErrInsufficientStock, err, "the active reservation holds 6 of the 10")
+
+	err = repo.Hold(context.TODO(), &domain.Reservation{ProductID: id, Qty: 4, ExpiresAt: expiresAt})
+	assert.NoError(t, err, "expired and committed reservations hold nothing")
+
+	product, err := productRepo.GetByID(context.TODO(), id)
+	require.NoError(t, err)
+	assert.Equal(t, 10, product.Stock)
+	assert.Equal(t, 0, product.Available)
+
+	list, _, err := productRepo.Fetch(context.TODO(), domain.ProductFilter{InStock: true}, pagination.Query{Limit: 20, Sort: "id"})
+	require.NoError(t, err)
+	assert.Empty(t, list, "a product whose stock is all held is not in stock")
+
+	err = productRepo.AdjustStock(context.TODO(), &domain.StockMovement{ProductID: id, Delta: -1, Reason: domain.StockDamage})
+	assert.Equal(t, domain.ErrInsufficientStock, err, "a damage can not take the held stock")
+
+	product.Stock = 9
+	err = productRepo.Update(context.TODO(), &product, id)
+	assert.Equal(t, domain.ErrStockHeld, err, "an edit can not take the held stock")
+}
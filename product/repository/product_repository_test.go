@@ -6,6 +6,8 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"log"
+	"platform/money"
+	"platform/pagination"
 	"product/domain"
 	"product/repository"
 	"regexp"
@@ -14,14 +16,29 @@ import (
 )
 
 var (
-	t       = time.Now()
-	ts      = t.Format("2006-01-02 15:04:05")
+	t0      = time.Now()
 	product = &domain.Product{
 		ID:    1,
 		Name:  "Laptop Lenovo",
-		Price: 3000000,
+		Price: money.New(300000000, "IDR"),
 		Stock: 10,
 	}
+
+	lockQuery     = regexp.QuoteMeta(`SELECT stock FROM product WHERE id=? FOR UPDATE`)
+	existsQuery   = regexp.QuoteMeta(`SELECT id FROM product WHERE id=?`)
+	adjustQuery   = regexp.QuoteMeta(`UPDATE product SET stock=stock+?, updated_at=? WHERE id=? AND stock+?>=0`)
+	orderQuery    = regexp.QuoteMeta(`UPDATE product SET stock=stock+?, updated_at=? WHERE id=? AND stock+?>=(SELECT COALESCE(SUM(qty), 0) FROM reservation WHERE product_id=? AND status='active' AND expires_at>?)`)
+	movementQuery = regexp.QuoteMeta(`INSERT INTO stock_movement (product_id, delta, reason, created_at) VALUES (?, ?, ?, ?)`)
+	categoryQuery = regexp.QuoteMeta(`SELECT id FROM category WHERE id=? FOR UPDATE`)
+	variantsQuery = regexp.QuoteMeta(`SELECT COUNT(*) FROM product WHERE parent_id=?`)
+
+	// The available stock is counted for the time the query runs, its first argument
+	productColumns = "id, parent_id, name, price, currency, stock, stock-" + heldColumn + ", category_id, created_at, updated_at"
+	// The in stock filter leaves out the stock held by reservations, it takes the time as well
+	heldColumn = "COALESCE((SELECT SUM(qty) FROM reservation WHERE reservation.product_id=product.id AND reservation.status='active' AND reservation.expires_at>?), 0)"
+
+	attributeQuery   = regexp.QuoteMeta(`SELECT product_id, name, value FROM product_attribute WHERE product_id IN (`)
+	attributeColumns = []string{"product_id", "name", "value"}
 )
 
 func NewMock() (*sql.DB, sqlmock.Sqlmock) {
@@ -40,17 +57,98 @@ func TestProductRepository_Fetch(t *testing.T) {
 		db.Close()
 	}()
 
-	query := "SELECT id, name, price, quantity, created_at, updated_at FROM product"
+	columns := []string{"id", "parent_id", "name", "price", "currency", "stock", "available", "category_id", "created_at", "updated_at"}
 
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "stock", "created_at", "updated_at"}).
-		AddRow(product.ID, product.Name, product.Price, product.Stock, product.CreatedAt, product.UpdatedAt)
+	t.Run("success", func(t *testing.T) {
+		query := regexp.QuoteMeta("SELECT " + productColumns + " FROM product ORDER BY id ASC LIMIT 21")
 
-	mock.ExpectQuery(query).WillReturnRows(rows)
+		rows := sqlmock.NewRows(columns).
+			AddRow(product.ID, nil, product.Name, product.Price.Amount, product.Price.Currency, product.Stock, product.Stock, nil, product.CreatedAt, product.UpdatedAt)
 
-	prod, err := repo.Fetch(context.TODO())
-	assert.NotEmpty(t, prod)
-	assert.NoError(t, err)
-	assert.Len(t, prod, 1)
+		mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg()).WillReturnRows(rows)
+		mock.ExpectQuery(attributeQuery).WithArgs(product.ID).
+			WillReturnRows(sqlmock.NewRows(attributeColumns).AddRow(product.ID, "color", "black"))
+
+		prod, next, err := repo.Fetch(context.TODO(), domain.ProductFilter{}, pagination.Query{Limit: 20, Sort: "id"})
+		assert.NotEmpty(t, prod)
+		assert.NoError(t, err)
+		assert.Len(t, prod, 1)
+		assert.Nil(t, prod[0].CategoryID)
+		assert.Equal(t, map[string]string{"color": "black"}, prod[0].Attributes)
+		assert.Empty(t, next)
+	})
+
+	t.Run("filtered next page", func(t *testing.T) {
+		query := regexp.QuoteMeta("SELECT " + productColumns + " FROM product WHERE name LIKE ? AND currency=? AND stock>" + heldColumn + " AND category_id IN (WITH RECURSIVE subcategory AS (SELECT id FROM category WHERE id=? UNION SELECT c.id FROM category c JOIN subcategory s ON c.parent_id=s.id) SELECT id FROM subcategory) AND (price > ? OR (price = ? AND id > ?)) ORDER BY price ASC, id ASC LIMIT 2")
+
+		rows := sqlmock.NewRows(columns).
+			AddRow(4, nil, "Laptop 50%", 500000, "IDR", 3, 3, 7, t0, t0).
+			AddRow(5, nil, "Laptop 50%", 600000, "IDR", 1, 1, 8, t0, t0)
+
+		q := pagination.Query{Limit: 1, Sort: "price", After: &pagination.Cursor{Sort: "price", Value: "400000", ID: 2}}
+		mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), `Laptop 50\%%`, "IDR", sqlmock.AnyArg(), uint32(2), "400000", "400000", 2).WillReturnRows(rows)
+		// Only the attributes of the products of the page are loaded
+		mock.ExpectQuery(attributeQuery).WithArgs(4).WillReturnRows(sqlmock.NewRows(attributeColumns))
+
+		prod, next, err := repo.Fetch(context.TODO(), domain.ProductFilter{Name: "Laptop 50%", Currency: "IDR", InStock: true, CategoryID: 2}, q)
+		assert.NoError(t, err)
+		assert.Len(t, prod, 1)
+		assert.Equal(t, q.Cursor("500000", 4), next)
+		assert.Equal(t, uint32(7), *prod[0].CategoryID)
+		assert.Empty(t, prod[0].Attributes)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestProductRepository_Fetch_Error(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewProductRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta("SELECT " + productColumns + " FROM product ORDER BY id ASC LIMIT 21")
+	mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+
+	_, _, err := repo.Fetch(context.TODO(), domain.ProductFilter{}, pagination.Query{Limit: 20, Sort: "id"})
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProductRepository_Search(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewProductRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	columns := []string{"id", "parent_id", "name", "price", "currency", "stock", "available", "category_id", "created_at", "updated_at", "score"}
+
+	t.Run("ranked", func(t *testing.T) {
+		query := regexp.QuoteMeta("SELECT " + productColumns + ", MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE) AS score FROM product WHERE MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE) ORDER BY score DESC, id ASC LIMIT 20")
+
+		rows := sqlmock.NewRows(columns).
+			AddRow(3, nil, "Lenovo ThinkPad X1", 2500000000, "IDR", 2, 2, nil, t0, t0, 1.8).
+			AddRow(1, nil, "ThinkPad Charger", 50000000, "IDR", 0, 0, nil, t0, t0, 0.9)
+		mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), "thinkpad", "thinkpad").WillReturnRows(rows)
+		mock.ExpectQuery(attributeQuery).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows(attributeColumns))
+
+		list, err := repo.Search(context.TODO(), domain.ProductSearch{Terms: "thinkpad", Limit: 20})
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, uint32(3), list[0].ID)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		query := regexp.QuoteMeta("SELECT " + productColumns + ", MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE) AS score FROM product WHERE MATCH(name) AGAINST(? IN NATURAL LANGUAGE MODE) AND currency=? AND price>=? AND price<=? AND stock>" + heldColumn + " ORDER BY score DESC, id ASC LIMIT 5")
+
+		min, max := int64(100000000), int64(3000000000)
+		mock.ExpectQuery(query).WithArgs(sqlmock.AnyArg(), "thinkpad", "thinkpad", "IDR", min, max, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(columns))
+
+		list, err := repo.Search(context.TODO(), domain.ProductSearch{Terms: "thinkpad", Currency: "IDR", MinPrice: &min, MaxPrice: &max, InStock: true, Limit: 5})
+		assert.NoError(t, err)
+		assert.Len(t, list, 0)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestProductRepository_GetByID(t *testing.T) {
@@ -60,17 +158,61 @@ func TestProductRepository_GetByID(t *testing.T) {
 		db.Close()
 	}()
 
-	query := "SELECT id, name, price, quantity, created_at, updated_at FROM product WHERE id=\\?"
+	query := regexp.QuoteMeta("SELECT " + productColumns + " FROM product WHERE id=?")
 
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "stock", "created_at", "updated_at"}).
-		AddRow(product.ID, product.Name, product.Price, product.Stock, product.CreatedAt, product.UpdatedAt)
+	columns := []string{"id", "parent_id", "name", "price", "currency", "stock", "available", "category_id", "created_at", "updated_at"}
+	variantQuery := regexp.QuoteMeta("SELECT " + productColumns + " FROM product WHERE parent_id=? ORDER BY id")
 
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectQuery().WithArgs(product.ID).WillReturnRows(rows)
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow(product.ID, nil, product.Name, product.Price.Amount, product.Price.Currency, product.Stock, 7, 3, product.CreatedAt, product.UpdatedAt)
 
-	p, err := repo.GetByID(context.TODO(), product.ID)
-	assert.NotNil(t, p)
-	assert.NoError(t, err)
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(sqlmock.AnyArg(), product.ID).WillReturnRows(rows)
+		mock.ExpectQuery(variantQuery).WithArgs(sqlmock.AnyArg(), product.ID).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery(attributeQuery).WithArgs(product.ID).
+			WillReturnRows(sqlmock.NewRows(attributeColumns).AddRow(product.ID, "color", "black").AddRow(product.ID, "ram", "16GB"))
+
+		p, err := repo.GetByID(context.TODO(), product.ID)
+		assert.NotNil(t, p)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(3), *p.CategoryID)
+		assert.Equal(t, 7, p.Available, "3 of the 10 are held by reservations")
+		assert.Equal(t, map[string]string{"color": "black", "ram": "16GB"}, p.Attributes)
+		assert.Nil(t, p.Variants)
+	})
+
+	t.Run("with variants", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow(5, nil, "Laptop Lenovo Thinkpad", 0, "IDR", 0, 0, 3, t0, t0)
+		variants := sqlmock.NewRows(columns).
+			AddRow(6, 5, "Laptop Lenovo Thinkpad 8GB", 1200000000, "IDR", 4, 4, 3, t0, t0).
+			AddRow(7, 5, "Laptop Lenovo Thinkpad 16GB", 1500000000, "IDR", 2, 2, 3, t0, t0)
+
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(sqlmock.AnyArg(), uint32(5)).WillReturnRows(rows)
+		mock.ExpectQuery(variantQuery).WithArgs(sqlmock.AnyArg(), uint32(5)).WillReturnRows(variants)
+		mock.ExpectQuery(attributeQuery).WithArgs(5, 6, 7).
+			WillReturnRows(sqlmock.NewRows(attributeColumns).AddRow(6, "ram", "8GB").AddRow(7, "ram", "16GB"))
+
+		p, err := repo.GetByID(context.TODO(), 5)
+		assert.NoError(t, err)
+		assert.Empty(t, p.Attributes)
+		assert.Len(t, p.Variants, 2)
+		assert.Equal(t, uint32(5), *p.Variants[1].ParentID)
+		assert.Equal(t, "16GB", p.Variants[1].Attributes["ram"])
+		assert.Equal(t, int64(1500000000), p.Variants[1].Price.Amount)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(sqlmock.AnyArg(), uint32(9)).WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.GetByID(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestProductRepository_Store(t *testing.T) {
@@ -79,15 +221,91 @@ func TestProductRepository_Store(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 
-	query := regexp.QuoteMeta(`INSERT INTO product (name, price, stock, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`)
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().WithArgs(product.Name, product.Price, product.Stock, ts, ts).WillReturnResult(sqlmock.NewResult(1, 1))
-
+	query := regexp.QuoteMeta(`INSERT INTO product (parent_id, name, price, currency, stock, category_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	parentQuery := regexp.QuoteMeta(`SELECT parent_id, stock FROM product WHERE id=? FOR UPDATE`)
+	attributes := regexp.QuoteMeta(`INSERT INTO product_attribute (product_id, name, value) VALUES (?, ?, ?), (?, ?, ?)`)
 	pr := repository.NewProductRepository(db)
 
-	err = pr.Store(context.TODO(), product)
-	assert.NoError(t, err)
-	assert.Equal(t, uint32(1), product.ID)
+	t.Run("success", func(t *testing.T) {
+		categoryID := uint32(3)
+		p := *product
+		p.CategoryID = &categoryID
+		p.Attributes = map[string]string{"ram": "16GB", "color": "black"}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(categoryQuery).WithArgs(categoryID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(categoryID))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(nil, p.Name, p.Price.Amount, p.Price.Currency, p.Stock, categoryID, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(attributes).WithArgs(uint32(1), "color", "black", uint32(1), "ram", "16GB").WillReturnResult(sqlmock.NewResult(0, 2))
+		ledger := mock.ExpectPrepare(movementQuery)
+		ledger.ExpectExec().WithArgs(uint32(1), p.Stock, "initial", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = pr.Store(context.TODO(), &p)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1), p.ID)
+	})
+
+	t.Run("unknown category", func(t *testing.T) {
+		categoryID := uint32(9)
+		p := *product
+		p.CategoryID = &categoryID
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(categoryQuery).WithArgs(categoryID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		err = pr.Store(context.TODO(), &p)
+		assert.Equal(t, domain.ErrUnknownCategory, err)
+	})
+
+	t.Run("variant", func(t *testing.T) {
+		parentID := uint32(5)
+		p := *product
+		p.ParentID = &parentID
+		p.Attributes = map[string]string{"ram": "16GB"}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(parentQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "stock"}).AddRow(nil, 0))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(parentID, p.Name, p.Price.Amount, p.Price.Currency, p.Stock, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO product_attribute (product_id, name, value) VALUES (?, ?, ?)`)).WithArgs(uint32(6), "ram", "16GB").WillReturnResult(sqlmock.NewResult(0, 1))
+		ledger := mock.ExpectPrepare(movementQuery)
+		ledger.ExpectExec().WithArgs(uint32(6), p.Stock, "initial", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err = pr.Store(context.TODO(), &p)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(6), p.ID)
+	})
+
+	t.Run("variant of a variant", func(t *testing.T) {
+		parentID := uint32(6)
+		p := *product
+		p.ParentID = &parentID
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(parentQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "stock"}).AddRow(5, 0))
+		mock.ExpectRollback()
+
+		err = pr.Store(context.TODO(), &p)
+		assert.Equal(t, domain.ErrInvalidParent, err)
+	})
+
+	t.Run("parent with stock", func(t *testing.T) {
+		parentID := uint32(1)
+		p := *product
+		p.ParentID = &parentID
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(parentQuery).WithArgs(parentID).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "stock"}).AddRow(nil, 10))
+		mock.ExpectRollback()
+
+		err = pr.Store(context.TODO(), &p)
+		assert.Equal(t, domain.ErrParentStock, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestProductRepository_Update(t *testing.T) {
@@ -96,14 +314,48 @@ func TestProductRepository_Update(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 
-	query := regexp.QuoteMeta(`UPDATE product SET name=?, price=?, stock=?, updated_at=? WHERE id=?`)
+	query := regexp.QuoteMeta(`UPDATE product SET name=?, price=?, currency=?, stock=?, category_id=?, updated_at=? WHERE id=?`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(4))
+	mock.ExpectQuery(variantsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
 	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().WithArgs(product.Name, product.Price, product.Stock, ts, product.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectExec().WithArgs(product.Name, product.Price.Amount, product.Price.Currency, product.Stock, nil, sqlmock.AnyArg(), product.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM product_attribute WHERE product_id=?`)).WithArgs(product.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+	ledger := mock.ExpectPrepare(movementQuery)
+	ledger.ExpectExec().WithArgs(product.ID, product.Stock-4, "correction", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
 
 	pr := repository.NewProductRepository(db)
 
 	err = pr.Update(context.TODO(), product, product.ID)
 	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+	mock.ExpectRollback()
+
+	err = pr.Update(context.TODO(), product, 9)
+	assert.Equal(t, domain.ErrNotFound, err)
+
+	// A parent keeps its stock at zero, only its variants are ordered
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(uint32(5)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(0))
+	mock.ExpectQuery(variantsQuery).WithArgs(uint32(5)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(2))
+	mock.ExpectRollback()
+
+	err = pr.Update(context.TODO(), product, 5)
+	assert.Equal(t, domain.ErrParentStock, err)
+
+	// Lowering the stock below the qty active reservations hold is refused
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(15))
+	mock.ExpectQuery(variantsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+	mock.ExpectQuery(heldQuery).WithArgs(product.ID, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(12))
+	mock.ExpectRollback()
+
+	err = pr.Update(context.TODO(), product, product.ID)
+	assert.Equal(t, domain.ErrStockHeld, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestProductRepository_Delete(t *testing.T) {
@@ -115,26 +367,124 @@ func TestProductRepository_Delete(t *testing.T) {
 
 	query := regexp.QuoteMeta(`DELETE FROM product WHERE id=?`)
 
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(1)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
-	num := uint32(1)
-	err := repo.Delete(context.TODO(), num)
-	assert.NoError(t, err)
+		num := uint32(1)
+		err := repo.Delete(context.TODO(), num)
+		assert.NoError(t, err)
+	})
+
+	t.Run("parent product", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(5)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(2))
+		mock.ExpectRollback()
+
+		err := repo.Delete(context.TODO(), 5)
+		assert.Equal(t, domain.ErrHasVariants, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectExec().WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.Delete(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestProductRepository_UpdateStock(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
+func TestProductRepository_AdjustStock(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewProductRepository(db)
+	defer func() {
+		db.Close()
+	}()
 
-	query := regexp.QuoteMeta(`UPDATE product SET stock=?, updated_at=? WHERE id=?`)
-	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().WithArgs(product.Stock, ts, product.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	movement := &domain.StockMovement{ProductID: product.ID, Delta: 5, Reason: domain.StockRestock}
 
-	pr := repository.NewProductRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectQuery(variantsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+	prep := mock.ExpectPrepare(adjustQuery)
+	prep.ExpectExec().WithArgs(5, sqlmock.AnyArg(), product.ID, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	ledger := mock.ExpectPrepare(movementQuery)
+	ledger.ExpectExec().WithArgs(product.ID, 5, "restock", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectCommit()
 
-	err = pr.UpdateStock(context.TODO(), product, product.ID)
+	err := repo.AdjustStock(context.TODO(), movement)
 	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), movement.ID)
+	assert.False(t, movement.CreatedAt.IsZero())
+
+	// Restocking a parent is refused, the stock belongs to its variants
+	mock.ExpectBegin()
+	mock.ExpectQuery(variantsQuery).WithArgs(uint32(5)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(2))
+	mock.ExpectRollback()
+
+	err = repo.AdjustStock(context.TODO(), &domain.StockMovement{ProductID: 5, Delta: 5, Reason: domain.StockRestock})
+	assert.Equal(t, domain.ErrParentStock, err)
+
+	// A damage can not take the stock held by active reservations
+	mock.ExpectBegin()
+	prep = mock.ExpectPrepare(orderQuery)
+	prep.ExpectExec().WithArgs(-2, sqlmock.AnyArg(), product.ID, -2, product.ID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(existsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(product.ID))
+	mock.ExpectRollback()
+
+	err = repo.AdjustStock(context.TODO(), &domain.StockMovement{ProductID: product.ID, Delta: -2, Reason: domain.StockDamage})
+	assert.Equal(t, domain.ErrInsufficientStock, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProductRepository_FetchMovements(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewProductRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := regexp.QuoteMeta(`SELECT id, product_id, delta, reason, created_at FROM stock_movement WHERE product_id=? ORDER BY id`)
+	columns := []string{"id", "product_id", "delta", "reason", "created_at"}
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow(1, product.ID, 10, "initial", t0).
+			AddRow(2, product.ID, -2, "order", t0)
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(product.ID).WillReturnRows(rows)
+
+		list, err := repo.FetchMovements(context.TODO(), product.ID)
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, domain.StockOrder, list[1].Reason)
+	})
+
+	t.Run("without movements", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery(existsQuery).WithArgs(uint32(2)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+		list, err := repo.FetchMovements(context.TODO(), 2)
+		assert.NoError(t, err)
+		assert.Len(t, list, 0)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		prep := mock.ExpectPrepare(query)
+		prep.ExpectQuery().WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery(existsQuery).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err := repo.FetchMovements(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"product/domain"
+	"sort"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errDuplicateEntry is the MySQL error number of a primary key that already exists
+const errDuplicateEntry = 1062
+
+type reservationRepository struct {
+	Conn *sql.DB
+}
+
+func NewReservationRepository(db *sql.DB) domain.ReservationRepository {
+	return &reservationRepository{
+		Conn: db,
+	}
+}
+
+func (rr *reservationRepository) GetByID(ctx context.Context, id uint32) (reservation domain.Reservation, err error) {
+	query := `SELECT id, order_id, product_id, qty, status, expires_at, created_at, updated_at FROM reservation WHERE id=?`
+
+	err = rr.Conn.QueryRowContext(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.OrderID,
+		&reservation.ProductID,
+		&reservation.Qty,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
+	return
+}
+
+// Hold will reserve qty of a product until the reservation expires, failing when the
+// stock left once the active reservations are taken out is too low
+func (rr *reservationRepository) Hold(ctx context.Context, reservation *domain.Reservation) (err error) {
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) error {
+		return hold(ctx, tx, reservation)
+	})
+}
+
+// Commit will take the qty of an active reservation out of the stock and record it in
+// the stock ledger as an order
+func (rr *reservationRepository) Commit(ctx context.Context, id uint32, now time.Time) (err error) {
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) (err error) {
+		reservation, live, err := lockReservation(ctx, tx, id, now)
+		if err != nil {
+			return
+		}
+		if !live {
+			return domain.ErrReservationExpired
+		}
+
+		// The reservation stops holding the stock before it is taken, so the order does
+		// not wait for its own hold
+		err = closeReservation(ctx, tx, id, domain.ReservationCommitted)
+		if err != nil {
+			return
+		}
+
+		return adjust(ctx, tx, &domain.StockMovement{
+			ProductID: reservation.ProductID,
+			Delta:     -reservation.Qty,
+			Reason:    domain.StockOrder,
+		})
+	})
+}
+
+// Release will give the qty held by an active reservation back to the available stock,
+// an expired reservation the reaper has not closed yet can still be released
+func (rr *reservationRepository) Release(ctx context.Context, id uint32) (err error) {
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) (err error) {
+		_, _, err = lockReservation(ctx, tx, id, time.Now())
+		if err != nil {
+			return
+		}
+
+		return closeReservation(ctx, tx, id, domain.ReservationReleased)
+	})
+}
+
+// GetOrderReply will return the reply recorded for an order that was already handled,
+// ErrNotFound when it was not
+func (rr *reservationRepository) GetOrderReply(ctx context.Context, orderID uint32) (reply domain.OrderReply, err error) {
+	query := `SELECT order_id, accepted, reason FROM handled_order WHERE order_id=?`
+
+	err = rr.Conn.QueryRowContext(ctx, query, orderID).Scan(&reply.OrderID, &reply.Accepted, &reply.Reason)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
+	return
+}
+
+// HoldOrder will reserve the qty of every item for an order until expiresAt, within a
+// single transaction so either all the items are held or none. The products are locked in
+// the order of their id, so two orders sharing products can not deadlock. The order is
+// recorded as accepted in the same transaction, ErrOrderHandled is returned when it
+// already was handled and nothing is held again.
+func (rr *reservationRepository) HoldOrder(ctx context.Context, orderID uint32, items []domain.OrderItem, expiresAt time.Time) (err error) {
+	sorted := make([]domain.OrderItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ProductID < sorted[j].ProductID
+	})
+
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) (err error) {
+		err = handleOrder(ctx, tx, domain.OrderReply{OrderID: orderID, Accepted: true})
+		if err != nil {
+			return
+		}
+
+		for _, item := range sorted {
+			err = hold(ctx, tx, &domain.Reservation{
+				OrderID:   orderID,
+				ProductID: item.ProductID,
+				Qty:       item.Qty,
+				ExpiresAt: expiresAt,
+			})
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// RejectOrder will record an order as rejected for reason, ErrOrderHandled is returned
+// when it already was handled
+func (rr *reservationRepository) RejectOrder(ctx context.Context, orderID uint32, reason string) (err error) {
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) error {
+		return handleOrder(ctx, tx, domain.OrderReply{OrderID: orderID, Reason: reason})
+	})
+}
+
+// CommitOrder will take the qty of every reservation of an order out of the stock, all at
+// once or none. The order is paid for only once, a retry after the commit does nothing.
+func (rr *reservationRepository) CommitOrder(ctx context.Context, orderID uint32, now time.Time) (err error) {
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) (err error) {
+		reservations, err := lockOrder(ctx, tx, orderID, now)
+		if err != nil {
+			return
+		}
+
+		active := make([]domain.Reservation, 0, len(reservations))
+		for _, r := range reservations {
+			switch {
+			case r.Status == domain.ReservationCommitted:
+			case r.Status == domain.ReservationExpired || r.Status == domain.ReservationActive && !r.live:
+				return domain.ErrReservationExpired
+			case r.Status != domain.ReservationActive:
+				return domain.ErrReservationClosed
+			default:
+				active = append(active, r.Reservation)
+			}
+		}
+
+		// Every hold of the order stops holding its stock before any is taken, so the
+		// order does not wait for its own holds
+		for _, r := range active {
+			err = closeReservation(ctx, tx, r.ID, domain.ReservationCommitted)
+			if err != nil {
+				return
+			}
+		}
+		for _, r := range active {
+			err = adjust(ctx, tx, &domain.StockMovement{
+				ProductID: r.ProductID,
+				Delta:     -r.Qty,
+				Reason:    domain.StockOrder,
+			})
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// ReleaseOrder will give back everything held or taken for an order, the stock held by
+// its active reservations is available again and the stock taken by its committed ones is
+// put back. Releasing an order twice, or one that holds nothing, does nothing.
+func (rr *reservationRepository) ReleaseOrder(ctx context.Context, orderID uint32) (err error) {
+	return transaction(ctx, rr.Conn, func(tx *sql.Tx) (err error) {
+		reservations, err := lockOrder(ctx, tx, orderID, time.Now())
+		if err == domain.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+
+		for _, r := range reservations {
+			if r.Status != domain.ReservationActive && r.Status != domain.ReservationCommitted {
+				continue
+			}
+
+			err = closeReservation(ctx, tx, r.ID, domain.ReservationReleased)
+			if err != nil {
+				return
+			}
+			if r.Status != domain.ReservationCommitted {
+				continue
+			}
+
+			err = adjust(ctx, tx, &domain.StockMovement{
+				ProductID: r.ProductID,
+				Delta:     r.Qty,
+				Reason:    domain.StockRelease,
+			})
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Expire will close the active reservations that expired by now, their stock is
+// available again, and return how many were closed
+func (rr *reservationRepository) Expire(ctx context.Context, now time.Time) (int64, error) {
+	query := `UPDATE reservation SET status=?, updated_at=? WHERE status=? AND expires_at<=?`
+
+	ts := now.Format("2006-01-02 15:04:05")
+	result, err := rr.Conn.ExecContext(ctx, query, string(domain.ReservationExpired), ts, string(domain.ReservationActive), ts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// lockReservation will return an active reservation and whether it is still live at now,
+// locking its row until the transaction ends. The expiry is compared by the database,
+// which holds the times as they were written.
+func lockReservation(ctx context.Context, tx *sql.Tx, id uint32, now time.Time) (reservation domain.Reservation, live bool, err error) {
+	query := `SELECT id, product_id, qty, status, expires_at>? FROM reservation WHERE id=? FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, query, now.Format("2006-01-02 15:04:05"), id).
+		Scan(&reservation.ID, &reservation.ProductID, &reservation.Qty, &reservation.Status, &live)
+	if err == sql.ErrNoRows {
+		return reservation, false, domain.ErrNotFound
+	}
+	if err == nil && reservation.Status != domain.ReservationActive {
+		err = domain.ErrReservationClosed
+	}
+	return
+}
+
+// hold will insert an active reservation once the product has enough available stock,
+// the product row is locked so concurrent holds and orders of the product are counted
+// one after the other
+func hold(ctx context.Context, tx *sql.Tx, reservation *domain.Reservation) (err error) {
+	query := `INSERT INTO reservation (order_id, product_id, qty, status, expires_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	stock, err := lockStock(ctx, tx, reservation.ProductID)
+	if err != nil {
+		return
+	}
+
+	err = checkVariants(ctx, tx, reservation.ProductID)
+	if err != nil {
+		return
+	}
+
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
+	var held int
+	err = tx.QueryRowContext(ctx, `SELECT `+heldQuery, reservation.ProductID, ts).Scan(&held)
+	if err != nil {
+		return
+	}
+	if stock-held < reservation.Qty {
+		return domain.ErrInsufficientStock
+	}
+
+	res, err := tx.ExecContext(ctx, query, reservation.OrderID, reservation.ProductID, reservation.Qty,
+		string(domain.ReservationActive), reservation.ExpiresAt.Format("2006-01-02 15:04:05"), ts, ts)
+	if err != nil {
+		return
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+	reservation.ID = uint32(lastID)
+	reservation.Status = domain.ReservationActive
+	reservation.CreatedAt = t
+	reservation.UpdatedAt = t
+	return
+}
+
+// handleOrder will record the reply of an order, the order id is the primary key so a
+// second reply of the same order waits for the first transaction and fails with
+// ErrOrderHandled once it is committed
+func handleOrder(ctx context.Context, tx *sql.Tx, reply domain.OrderReply) (err error) {
+	query := `INSERT INTO handled_order (order_id, accepted, reason, created_at) VALUES (?, ?, ?, ?)`
+
+	_, err = tx.ExecContext(ctx, query, reply.OrderID, reply.Accepted, reply.Reason, now())
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == errDuplicateEntry {
+		err = domain.ErrOrderHandled
+	}
+	return
+}
+
+// orderReservation is a reservation of an order locked by lockOrder, live while it has
+// not expired
+type orderReservation struct {
+	domain.Reservation
+	live bool
+}
+
+// lockOrder will return every reservation of an order whatever its status, locking their
+// rows until the transaction ends, ErrNotFound when the order holds nothing
+func lockOrder(ctx context.Context, tx *sql.Tx, orderID uint32, now time.Time) (reservations []orderReservation, err error) {
+	query := `SELECT id, product_id, qty, status, expires_at>? FROM reservation WHERE order_id=? ORDER BY product_id, id FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, now.Format("2006-01-02 15:04:05"), orderID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := orderReservation{Reservation: domain.Reservation{OrderID: orderID}}
+		err = rows.Scan(&r.ID, &r.ProductID, &r.Qty, &r.Status, &r.live)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	err = rows.Err()
+	if err == nil && len(reservations) == 0 {
+		err = domain.ErrNotFound
+	}
+	return
+}
+
+// closeReservation will move a locked reservation to its final status
+func closeReservation(ctx context.Context, tx *sql.Tx, id uint32, status domain.ReservationStatus) (err error) {
+	ts := time.Now().Format("2006-01-02 15:04:05")
+	_, err = tx.ExecContext(ctx, `UPDATE reservation SET status=?, updated_at=? WHERE id=?`, string(status), ts, id)
+	return
+}
@@ -0,0 +1,457 @@
+package repository_test
+
+import (
+	"context"
+	"product/domain"
+	"product/repository"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	heldQuery              = regexp.QuoteMeta(`SELECT (SELECT COALESCE(SUM(qty), 0) FROM reservation WHERE product_id=? AND status='active' AND expires_at>?)`)
+	lockReservationQuery   = regexp.QuoteMeta(`SELECT id, product_id, qty, status, expires_at>? FROM reservation WHERE id=? FOR UPDATE`)
+	lockReservationColumns = []string{"id", "product_id", "qty", "status", "live"}
+	closeReservationQuery  = regexp.QuoteMeta(`UPDATE reservation SET status=?, updated_at=? WHERE id=?`)
+	holdQuery              = regexp.QuoteMeta(`INSERT INTO reservation (order_id, product_id, qty, status, expires_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	lockOrderQuery         = regexp.QuoteMeta(`SELECT id, product_id, qty, status, expires_at>? FROM reservation WHERE order_id=? ORDER BY product_id, id FOR UPDATE`)
+	handleOrderQuery       = regexp.QuoteMeta(`INSERT INTO handled_order (order_id, accepted, reason, created_at) VALUES (?, ?, ?, ?)`)
+	orderReplyQuery        = regexp.QuoteMeta(`SELECT order_id, accepted, reason FROM handled_order WHERE order_id=?`)
+)
+
+func TestReservationRepository_GetByID(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta(`SELECT id, order_id, product_id, qty, status, expires_at, created_at, updated_at FROM reservation WHERE id=?`)
+	columns := []string{"id", "order_id", "product_id", "qty", "status", "expires_at", "created_at", "updated_at"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(query).WithArgs(uint32(4)).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(4, 7, product.ID, 2, "active", t0, t0, t0))
+
+		reservation, err := repo.GetByID(context.TODO(), 4)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ReservationActive, reservation.Status)
+		assert.Equal(t, 2, reservation.Qty)
+		assert.Equal(t, uint32(7), reservation.OrderID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(query).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.GetByID(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_Hold(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+		mock.ExpectQuery(variantsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		mock.ExpectQuery(heldQuery).WithArgs(product.ID, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(7))
+		mock.ExpectExec(holdQuery).WithArgs(0, product.ID, 3, "active", expiresAt.Format("2006-01-02 15:04:05"), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectCommit()
+
+		reservation := domain.Reservation{ProductID: product.ID, Qty: 3, ExpiresAt: expiresAt}
+		err := repo.Hold(context.TODO(), &reservation)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(4), reservation.ID)
+		assert.Equal(t, domain.ReservationActive, reservation.Status)
+	})
+
+	t.Run("insufficient stock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+		mock.ExpectQuery(variantsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		mock.ExpectQuery(heldQuery).WithArgs(product.ID, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(8))
+		mock.ExpectRollback()
+
+		reservation := domain.Reservation{ProductID: product.ID, Qty: 3, ExpiresAt: expiresAt}
+		err := repo.Hold(context.TODO(), &reservation)
+		assert.Equal(t, domain.ErrInsufficientStock, err)
+	})
+
+	t.Run("parent product", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(5)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(0))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(5)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(2))
+		mock.ExpectRollback()
+
+		reservation := domain.Reservation{ProductID: 5, Qty: 1, ExpiresAt: expiresAt}
+		err := repo.Hold(context.TODO(), &reservation)
+		assert.Equal(t, domain.ErrHasVariants, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"stock"}))
+		mock.ExpectRollback()
+
+		reservation := domain.Reservation{ProductID: 9, Qty: 1, ExpiresAt: expiresAt}
+		err := repo.Hold(context.TODO(), &reservation)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_Commit(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	now := time.Now()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockReservationQuery).WithArgs(now.Format("2006-01-02 15:04:05"), uint32(4)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(4, product.ID, 3, "active", true))
+		// The reservation stops holding its qty before the order takes it
+		mock.ExpectExec(closeReservationQuery).WithArgs("committed", sqlmock.AnyArg(), uint32(4)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(variantsQuery).WithArgs(product.ID).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		prep := mock.ExpectPrepare(orderQuery)
+		prep.ExpectExec().WithArgs(-3, sqlmock.AnyArg(), product.ID, -3, product.ID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		ledger := mock.ExpectPrepare(movementQuery)
+		ledger.ExpectExec().WithArgs(product.ID, -3, "order", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.Commit(context.TODO(), 4, now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockReservationQuery).WithArgs(now.Format("2006-01-02 15:04:05"), uint32(5)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(5, product.ID, 3, "active", false))
+		mock.ExpectRollback()
+
+		err := repo.Commit(context.TODO(), 5, now)
+		assert.Equal(t, domain.ErrReservationExpired, err)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockReservationQuery).WithArgs(now.Format("2006-01-02 15:04:05"), uint32(6)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(6, product.ID, 3, "released", true))
+		mock.ExpectRollback()
+
+		err := repo.Commit(context.TODO(), 6, now)
+		assert.Equal(t, domain.ErrReservationClosed, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockReservationQuery).WithArgs(now.Format("2006-01-02 15:04:05"), uint32(9)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns))
+		mock.ExpectRollback()
+
+		err := repo.Commit(context.TODO(), 9, now)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_Release(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		// An expired reservation the reaper has not closed yet is released all the same
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockReservationQuery).WithArgs(sqlmock.AnyArg(), uint32(4)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(4, product.ID, 3, "active", false))
+		mock.ExpectExec(closeReservationQuery).WithArgs("released", sqlmock.AnyArg(), uint32(4)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Release(context.TODO(), 4)
+		assert.NoError(t, err)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockReservationQuery).WithArgs(sqlmock.AnyArg(), uint32(6)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(6, product.ID, 3, "committed", true))
+		mock.ExpectRollback()
+
+		err := repo.Release(context.TODO(), 6)
+		assert.Equal(t, domain.ErrReservationClosed, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_HoldOrder(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	expiresAt := time.Now().Add(15 * time.Minute)
+	ts := expiresAt.Format("2006-01-02 15:04:05")
+	// The products are locked by increasing ID whatever the order of the items
+	items := []domain.OrderItem{{ProductID: 4, Qty: 1}, {ProductID: 3, Qty: 2}}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(handleOrderQuery).WithArgs(uint32(7), true, "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(3)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(3)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		mock.ExpectQuery(heldQuery).WithArgs(uint32(3), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(0))
+		mock.ExpectExec(holdQuery).WithArgs(uint32(7), uint32(3), 2, "active", ts, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(4)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(5))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(4)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		mock.ExpectQuery(heldQuery).WithArgs(uint32(4), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(4))
+		mock.ExpectExec(holdQuery).WithArgs(uint32(7), uint32(4), 1, "active", ts, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		err := repo.HoldOrder(context.TODO(), 7, items, expiresAt)
+		assert.NoError(t, err)
+	})
+
+	t.Run("insufficient stock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(handleOrderQuery).WithArgs(uint32(8), true, "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(3)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(3)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		mock.ExpectQuery(heldQuery).WithArgs(uint32(3), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(0))
+		mock.ExpectExec(holdQuery).WithArgs(uint32(8), uint32(3), 2, "active", ts, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(lockQuery).WithArgs(uint32(4)).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(5))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(4)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		mock.ExpectQuery(heldQuery).WithArgs(uint32(4), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"held"}).AddRow(5))
+		// The hold of the first product is dropped with the transaction, and the order is
+		// not recorded as accepted
+		mock.ExpectRollback()
+
+		err := repo.HoldOrder(context.TODO(), 8, items, expiresAt)
+		assert.Equal(t, domain.ErrInsufficientStock, err)
+	})
+
+	t.Run("already handled", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(handleOrderQuery).WithArgs(uint32(9), true, "", sqlmock.AnyArg()).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '9' for key 'PRIMARY'"})
+		mock.ExpectRollback()
+
+		err := repo.HoldOrder(context.TODO(), 9, items, expiresAt)
+		assert.Equal(t, domain.ErrOrderHandled, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_RejectOrder(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(handleOrderQuery).WithArgs(uint32(8), false, domain.ReasonInsufficientStock, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.RejectOrder(context.TODO(), 8, domain.ReasonInsufficientStock)
+		assert.NoError(t, err)
+	})
+
+	t.Run("already handled", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(handleOrderQuery).WithArgs(uint32(8), false, domain.ReasonInsufficientStock, sqlmock.AnyArg()).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '8' for key 'PRIMARY'"})
+		mock.ExpectRollback()
+
+		err := repo.RejectOrder(context.TODO(), 8, domain.ReasonInsufficientStock)
+		assert.Equal(t, domain.ErrOrderHandled, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_GetOrderReply(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"order_id", "accepted", "reason"}).AddRow(8, false, domain.ReasonInsufficientStock)
+		mock.ExpectQuery(orderReplyQuery).WithArgs(uint32(8)).WillReturnRows(rows)
+
+		reply, err := repo.GetOrderReply(context.TODO(), 8)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderReply{OrderID: 8, Reason: domain.ReasonInsufficientStock}, reply)
+	})
+
+	t.Run("not handled", func(t *testing.T) {
+		mock.ExpectQuery(orderReplyQuery).WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"order_id", "accepted", "reason"}))
+
+		_, err := repo.GetOrderReply(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_CommitOrder(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	now := time.Now()
+	ts := now.Format("2006-01-02 15:04:05")
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(ts, uint32(7)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(1, 3, 2, "active", true).AddRow(2, 4, 1, "active", true))
+		// The holds of the order stop holding their qty before the order takes it
+		mock.ExpectExec(closeReservationQuery).WithArgs("committed", sqlmock.AnyArg(), uint32(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(closeReservationQuery).WithArgs("committed", sqlmock.AnyArg(), uint32(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(3)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		prep := mock.ExpectPrepare(orderQuery)
+		prep.ExpectExec().WithArgs(-2, sqlmock.AnyArg(), uint32(3), -2, uint32(3), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		ledger := mock.ExpectPrepare(movementQuery)
+		ledger.ExpectExec().WithArgs(uint32(3), -2, "order", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(variantsQuery).WithArgs(uint32(4)).WillReturnRows(sqlmock.NewRows([]string{"variants"}).AddRow(0))
+		prep = mock.ExpectPrepare(orderQuery)
+		prep.ExpectExec().WithArgs(-1, sqlmock.AnyArg(), uint32(4), -1, uint32(4), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		ledger = mock.ExpectPrepare(movementQuery)
+		ledger.ExpectExec().WithArgs(uint32(4), -1, "order", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		err := repo.CommitOrder(context.TODO(), 7, now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("already committed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(ts, uint32(7)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(1, 3, 2, "committed", true).AddRow(2, 4, 1, "committed", true))
+		mock.ExpectCommit()
+
+		err := repo.CommitOrder(context.TODO(), 7, now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		// One hold past its expiry the reaper has not closed yet is enough to fail the order
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(ts, uint32(8)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(3, 3, 2, "active", true).AddRow(4, 4, 1, "active", false))
+		mock.ExpectRollback()
+
+		err := repo.CommitOrder(context.TODO(), 8, now)
+		assert.Equal(t, domain.ErrReservationExpired, err)
+	})
+
+	t.Run("released", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(ts, uint32(9)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(5, 3, 2, "released", true))
+		mock.ExpectRollback()
+
+		err := repo.CommitOrder(context.TODO(), 9, now)
+		assert.Equal(t, domain.ErrReservationClosed, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(ts, uint32(10)).WillReturnRows(sqlmock.NewRows(lockReservationColumns))
+		mock.ExpectRollback()
+
+		err := repo.CommitOrder(context.TODO(), 10, now)
+		assert.Equal(t, domain.ErrNotFound, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_ReleaseOrder(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	t.Run("held", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(sqlmock.AnyArg(), uint32(7)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(1, 3, 2, "active", true).AddRow(2, 4, 1, "expired", false))
+		mock.ExpectExec(closeReservationQuery).WithArgs("released", sqlmock.AnyArg(), uint32(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.ReleaseOrder(context.TODO(), 7)
+		assert.NoError(t, err)
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		// The stock taken by a paid order is put back
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(sqlmock.AnyArg(), uint32(8)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(3, 3, 2, "committed", true))
+		mock.ExpectExec(closeReservationQuery).WithArgs("released", sqlmock.AnyArg(), uint32(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+		prep := mock.ExpectPrepare(adjustQuery)
+		prep.ExpectExec().WithArgs(2, sqlmock.AnyArg(), uint32(3), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		ledger := mock.ExpectPrepare(movementQuery)
+		ledger.ExpectExec().WithArgs(uint32(3), 2, "release", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.ReleaseOrder(context.TODO(), 8)
+		assert.NoError(t, err)
+	})
+
+	t.Run("released twice", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(sqlmock.AnyArg(), uint32(8)).
+			WillReturnRows(sqlmock.NewRows(lockReservationColumns).AddRow(3, 3, 2, "released", true))
+		mock.ExpectCommit()
+
+		err := repo.ReleaseOrder(context.TODO(), 8)
+		assert.NoError(t, err)
+	})
+
+	t.Run("nothing held", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockOrderQuery).WithArgs(sqlmock.AnyArg(), uint32(9)).WillReturnRows(sqlmock.NewRows(lockReservationColumns))
+		mock.ExpectCommit()
+
+		err := repo.ReleaseOrder(context.TODO(), 9)
+		assert.NoError(t, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReservationRepository_Expire(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewReservationRepository(db)
+	defer db.Close()
+
+	now := time.Now()
+	ts := now.Format("2006-01-02 15:04:05")
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE reservation SET status=?, updated_at=? WHERE status=? AND expires_at<=?`)).
+		WithArgs("expired", ts, "active", ts).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	expired, err := repo.Expire(context.TODO(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), expired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
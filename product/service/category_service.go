@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"product/domain"
+	"strings"
+	"time"
+)
+
+type categoryService struct {
+	categoryRepo   domain.CategoryRepository
+	contextTimeout time.Duration
+}
+
+func NewCategoryService(category domain.CategoryRepository, timeout time.Duration) domain.CategoryService {
+	return &categoryService{
+		categoryRepo:   category,
+		contextTimeout: timeout,
+	}
+}
+
+func (cs *categoryService) Fetch(c context.Context) ([]domain.Category, error) {
+	ctx, cancel := context.WithTimeout(c, cs.contextTimeout)
+	defer cancel()
+
+	return cs.categoryRepo.Fetch(ctx)
+}
+
+func (cs *categoryService) GetByID(c context.Context, id uint32) (domain.Category, error) {
+	ctx, cancel := context.WithTimeout(c, cs.contextTimeout)
+	defer cancel()
+
+	return cs.categoryRepo.GetByID(ctx, id)
+}
+
+func (cs *categoryService) Store(c context.Context, category *domain.Category) (err error) {
+	ctx, cancel := context.WithTimeout(c, cs.contextTimeout)
+	defer cancel()
+
+	err = validCategory(category)
+	if err != nil {
+		return
+	}
+
+	return cs.categoryRepo.Store(ctx, category)
+}
+
+// Update will rename or move a category, a category can not be its own parent
+func (cs *categoryService) Update(c context.Context, category *domain.Category, id uint32) (err error) {
+	ctx, cancel := context.WithTimeout(c, cs.contextTimeout)
+	defer cancel()
+
+	err = validCategory(category)
+	if err != nil {
+		return
+	}
+	if category.ParentID != nil && *category.ParentID == id {
+		return domain.ErrCategoryCycle
+	}
+
+	return cs.categoryRepo.Update(ctx, category, id)
+}
+
+func (cs *categoryService) Delete(c context.Context, id uint32) error {
+	ctx, cancel := context.WithTimeout(c, cs.contextTimeout)
+	defer cancel()
+
+	return cs.categoryRepo.Delete(ctx, id)
+}
+
+// validCategory will trim the name of the category, which must not be left empty
+func validCategory(category *domain.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return domain.ErrInvalidCategory
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"context"
+	"product/domain"
+	"product/domain/mocks"
+	"product/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCategoryService_Fetch(t *testing.T) {
+	mockCategoryRepo := new(mocks.CategoryRepository)
+	mockCategoryRepo.On("Fetch", mock.Anything).Return([]domain.Category{{ID: 1, Name: "Electronics"}}, nil).Once()
+
+	c := service.NewCategoryService(mockCategoryRepo, time.Second*2)
+	list, err := c.Fetch(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	mockCategoryRepo.AssertExpectations(t)
+}
+
+func TestCategoryService_Store(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockCategoryRepo := new(mocks.CategoryRepository)
+		mockCategoryRepo.On("Store", mock.Anything, &domain.Category{Name: "Laptops"}).Return(nil).Once()
+
+		c := service.NewCategoryService(mockCategoryRepo, time.Second*2)
+		err := c.Store(context.TODO(), &domain.Category{Name: " Laptops "})
+		assert.NoError(t, err)
+		mockCategoryRepo.AssertExpectations(t)
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		mockCategoryRepo := new(mocks.CategoryRepository)
+
+		c := service.NewCategoryService(mockCategoryRepo, time.Second*2)
+		err := c.Store(context.TODO(), &domain.Category{Name: "  "})
+		assert.Equal(t, domain.ErrInvalidCategory, err)
+		mockCategoryRepo.AssertNotCalled(t, "Store", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCategoryService_Update(t *testing.T) {
+	parentID := uint32(1)
+
+	t.Run("success", func(t *testing.T) {
+		mockCategoryRepo := new(mocks.CategoryRepository)
+		mockCategoryRepo.On("Update", mock.Anything, mock.AnythingOfType("*domain.Category"), uint32(2)).Return(nil).Once()
+
+		c := service.NewCategoryService(mockCategoryRepo, time.Second*2)
+		err := c.Update(context.TODO(), &domain.Category{ParentID: &parentID, Name: "Laptops"}, 2)
+		assert.NoError(t, err)
+		mockCategoryRepo.AssertExpectations(t)
+	})
+
+	t.Run("own parent", func(t *testing.T) {
+		mockCategoryRepo := new(mocks.CategoryRepository)
+
+		c := service.NewCategoryService(mockCategoryRepo, time.Second*2)
+		err := c.Update(context.TODO(), &domain.Category{ParentID: &parentID, Name: "Electronics"}, 1)
+		assert.Equal(t, domain.ErrCategoryCycle, err)
+		mockCategoryRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCategoryService_Delete(t *testing.T) {
+	mockCategoryRepo := new(mocks.CategoryRepository)
+	mockCategoryRepo.On("Delete", mock.Anything, uint32(1)).Return(domain.ErrCategoryInUse).Once()
+
+	c := service.NewCategoryService(mockCategoryRepo, time.Second*2)
+	err := c.Delete(context.TODO(), 1)
+	assert.Equal(t, domain.ErrCategoryInUse, err)
+	mockCategoryRepo.AssertExpectations(t)
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"platform/money"
+	"platform/pagination"
 	"product/domain"
+	"strings"
 	"time"
 )
 
@@ -18,18 +21,40 @@ func NewProductService(product domain.ProductRepository, timeout time.Duration)
 	}
 }
 
-func (ps *productService) Fetch(c context.Context) (products []domain.Product, err error)  {
+func (ps *productService) Fetch(c context.Context, filter domain.ProductFilter, q pagination.Query) (products []domain.Product, next string, err error)  {
 	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
 	defer cancel()
 
-	products, err = ps.productRepo.Fetch(ctx)
+	// Prices in different currencies can not be compared, so a list sorted by price has one
+	if q.Sort == "price" && filter.Currency == "" {
+		return nil, "", domain.ErrCurrencyRequired
+	}
+
+	products, next, err = ps.productRepo.Fetch(ctx, filter, q)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	return
 }
 
+// Search will return the products whose name matches the search terms, the best matches first
+func (ps *productService) Search(c context.Context, search domain.ProductSearch) (products []domain.Product, err error)  {
+	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
+	defer cancel()
+
+	search.Terms = strings.TrimSpace(search.Terms)
+	if search.Terms == "" {
+		return nil, domain.ErrEmptySearch
+	}
+	err = validPriceRange(search)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.productRepo.Search(ctx, search)
+}
+
 func (ps *productService) GetByID(c context.Context, id uint32) (product domain.Product, err error)  {
 	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
 	defer cancel()
@@ -45,6 +70,15 @@ func (ps *productService) Store(c context.Context, product *domain.Product) (err
 	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
 	defer cancel()
 
+	err = validPrice(product.Price)
+	if err != nil {
+		return
+	}
+	err = validAttributes(product.Attributes)
+	if err != nil {
+		return
+	}
+
 	err = ps.productRepo.Store(ctx, product)
 	return 
 }
@@ -53,6 +87,15 @@ func (ps *productService) Update(c context.Context, product *domain.Product, id
 	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
 	defer cancel()
 
+	err = validPrice(product.Price)
+	if err != nil {
+		return
+	}
+	err = validAttributes(product.Attributes)
+	if err != nil {
+		return
+	}
+
 	product.UpdatedAt = time.Now()
 	return ps.productRepo.Update(ctx, product, id)
 }
@@ -65,10 +108,71 @@ func (ps *productService) Delete(c context.Context, id uint32) (err error)  {
 	return
 }
 
-func (ps productService) UpdateStock(c context.Context, product *domain.Product, id uint32) (err error)  {
+func validPrice(price money.Money) error {
+	if price.Amount < 0 || price.Validate() != nil {
+		return domain.ErrInvalidPrice
+	}
+	return nil
+}
+
+// validAttributes will check that the attributes fit the product_attribute columns
+func validAttributes(attributes map[string]string) error {
+	for name, value := range attributes {
+		if name == "" || len(name) > domain.MaxAttributeName || len(value) > domain.MaxAttributeValue {
+			return domain.ErrInvalidAttribute
+		}
+	}
+	return nil
+}
+
+// validPriceRange will check that the bounds of a search are in a known currency and in order
+func validPriceRange(search domain.ProductSearch) error {
+	if search.MinPrice == nil && search.MaxPrice == nil {
+		return nil
+	}
+	if money.New(0, search.Currency).Validate() != nil {
+		return domain.ErrInvalidPriceRange
+	}
+	if search.MinPrice != nil && search.MaxPrice != nil && *search.MinPrice > *search.MaxPrice {
+		return domain.ErrInvalidPriceRange
+	}
+	return nil
+}
+
+func validItems(items []domain.OrderItem) error {
+	if len(items) == 0 {
+		return domain.ErrNoItems
+	}
+	for _, item := range items {
+		if item.Qty <= 0 {
+			return domain.ErrInvalidQty
+		}
+	}
+	return nil
+}
+
+// AdjustStock will move the product stock by a restock, damage or return and record it
+// in the stock ledger
+func (ps *productService) AdjustStock(c context.Context, movement *domain.StockMovement) (err error) {
+	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
+	defer cancel()
+
+	if !movement.Reason.Valid(movement.Delta) {
+		return domain.ErrInvalidAdjustment
+	}
+
+	err = ps.productRepo.AdjustStock(ctx, movement)
+	return
+}
+
+func (ps *productService) FetchMovements(c context.Context, id uint32) (movements []domain.StockMovement, err error) {
 	ctx, cancel := context.WithTimeout(c, ps.contextTimeout)
 	defer cancel()
 
-	product.UpdatedAt = time.Now()
-	return ps.productRepo.Update(ctx, product, id)
-}
\ No newline at end of file
+	movements, err = ps.productRepo.FetchMovements(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
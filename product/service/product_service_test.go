@@ -5,9 +5,13 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"platform/money"
+	"platform/pagination"
 	"product/domain"
 	"product/domain/mocks"
 	"product/service"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -16,36 +20,85 @@ func TestProductService_Fetch(t *testing.T) {
 	mockProductRepo := new(mocks.ProductRepository)
 	mockProduct := domain.Product{
 		Name:      "Laptop Lenovo",
-		Price:     3000000,
+		Price:     money.New(300000000, "IDR"),
 	}
 
 	mockListProduct := make([]domain.Product, 0)
 	mockListProduct = append(mockListProduct, mockProduct)
+	filter := domain.ProductFilter{InStock: true}
+	query := pagination.Query{Limit: 20, Sort: "id"}
 
 	t.Run("success", func(t *testing.T) {
-		mockProductRepo.On("Fetch", mock.Anything).Return(mockListProduct, nil).Once()
+		mockProductRepo.On("Fetch", mock.Anything, filter, query).Return(mockListProduct, "next", nil).Once()
 		p := service.NewProductService(mockProductRepo, time.Second*2)
-		list, err := p.Fetch(context.TODO())
+		list, next, err := p.Fetch(context.TODO(), filter, query)
 		assert.NoError(t, err)
 		assert.Len(t, list, len(mockListProduct))
+		assert.Equal(t, "next", next)
 
 		mockProductRepo.AssertExpectations(t)
 	})
 
 	t.Run("error", func(t *testing.T) {
-		mockProductRepo.On("Fetch", mock.Anything).Return(nil, errors.New("unexpected error")).Once()
+		mockProductRepo.On("Fetch", mock.Anything, filter, query).Return(nil, "", errors.New("unexpected error")).Once()
 		p := service.NewProductService(mockProductRepo, time.Second*2)
-		list, err := p.Fetch(context.TODO())
+		list, _, err := p.Fetch(context.TODO(), filter, query)
 
 		assert.Error(t, err)
 		assert.Len(t, list, 0)
 		mockProductRepo.AssertExpectations(t)
 	})
+
+	t.Run("price without currency", func(t *testing.T) {
+		unusedRepo := new(mocks.ProductRepository)
+		p := service.NewProductService(unusedRepo, time.Second*2)
+		_, _, err := p.Fetch(context.TODO(), filter, pagination.Query{Limit: 20, Sort: "price"})
+
+		assert.Equal(t, domain.ErrCurrencyRequired, err)
+		unusedRepo.AssertNotCalled(t, "Fetch", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestProductService_Search(t *testing.T) {
+	mockProductRepo := new(mocks.ProductRepository)
+	mockListProduct := []domain.Product{{Name: "Lenovo ThinkPad X1", Price: money.New(2500000000, "IDR")}}
+	min, max := int64(100000000), int64(3000000000)
+
+	t.Run("success", func(t *testing.T) {
+		search := domain.ProductSearch{Terms: "thinkpad", Currency: "IDR", MinPrice: &min, MaxPrice: &max, Limit: 20}
+		mockProductRepo.On("Search", mock.Anything, search).Return(mockListProduct, nil).Once()
+		p := service.NewProductService(mockProductRepo, time.Second*2)
+
+		search.Terms = " thinkpad "
+		list, err := p.Search(context.TODO(), search)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		mockProductRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			search domain.ProductSearch
+			err    error
+		}{
+			{domain.ProductSearch{Terms: "  "}, domain.ErrEmptySearch},
+			{domain.ProductSearch{Terms: "thinkpad", MinPrice: &min}, domain.ErrInvalidPriceRange},
+			{domain.ProductSearch{Terms: "thinkpad", Currency: "IDR", MinPrice: &max, MaxPrice: &min}, domain.ErrInvalidPriceRange},
+		}
+
+		unusedRepo := new(mocks.ProductRepository)
+		p := service.NewProductService(unusedRepo, time.Second*2)
+		for _, tt := range tests {
+			_, err := p.Search(context.TODO(), tt.search)
+			assert.Equal(t, tt.err, err)
+		}
+		unusedRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+	})
 }
 
 func TestProductService_GetByID(t *testing.T) {
 	mockProductRepo := new(mocks.ProductRepository)
-	mockProduct := domain.Product{Name: "Laptop Lenovo", Price: 3000000}
+	mockProduct := domain.Product{Name: "Laptop Lenovo", Price: money.New(300000000, "IDR")}
 
 	t.Run("success", func(t *testing.T) {
 		mockProductRepo.On("GetByID", mock.Anything, mock.AnythingOfType("uint32")).Return(mockProduct, nil).Once()
@@ -70,7 +123,7 @@ func TestProductService_GetByID(t *testing.T) {
 
 func TestProductService_Store(t *testing.T) {
 	mockProductRepo := new(mocks.ProductRepository)
-	mockProduct := domain.Product{Name: "Laptop Lenovo", Price: 3000000}
+	mockProduct := domain.Product{Name: "Laptop Lenovo", Price: money.New(300000000, "IDR")}
 	tempMockProduct := mockProduct
 	tempMockProduct.ID = 0
 
@@ -84,11 +137,29 @@ func TestProductService_Store(t *testing.T) {
 		assert.Equal(t, mockProduct.Name, tempMockProduct.Name)
 		mockProductRepo.AssertExpectations(t)
 	})
+	t.Run("invalid price", func(t *testing.T) {
+		p := service.NewProductService(mockProductRepo, time.Second*2)
+
+		for _, price := range []money.Money{money.New(-100, "IDR"), money.New(100, "XXX"), {}} {
+			err := p.Store(context.TODO(), &domain.Product{Name: "Laptop Lenovo", Price: price})
+			assert.Equal(t, domain.ErrInvalidPrice, err)
+		}
+		mockProductRepo.AssertExpectations(t)
+	})
+	t.Run("invalid attribute", func(t *testing.T) {
+		p := service.NewProductService(mockProductRepo, time.Second*2)
+
+		for _, attributes := range []map[string]string{{"": "black"}, {strings.Repeat("a", 65): "black"}, {"color": strings.Repeat("a", 256)}} {
+			err := p.Store(context.TODO(), &domain.Product{Name: "Laptop Lenovo", Price: money.New(100, "IDR"), Attributes: attributes})
+			assert.Equal(t, domain.ErrInvalidAttribute, err)
+		}
+		mockProductRepo.AssertExpectations(t)
+	})
 }
 
 func TestProductService_Update(t *testing.T) {
 	mockProductRepo := new(mocks.ProductRepository)
-	mockProduct := domain.Product{ID: 1, Name: "Laptop Lenovo", Price: 3000000}
+	mockProduct := domain.Product{ID: 1, Name: "Laptop Lenovo", Price: money.New(300000000, "IDR")}
 
 	t.Run("success", func(t *testing.T) {
 		mockProductRepo.On("Update", mock.Anything, &mockProduct).Once().Return(nil)
@@ -102,7 +173,7 @@ func TestProductService_Update(t *testing.T) {
 
 func TestProductService_Delete(t *testing.T) {
 	mockProductRepo := new(mocks.ProductRepository)
-	mockProduct := domain.Product{ID: 1, Name: "Laptop Lenovo", Price: 3000000}
+	mockProduct := domain.Product{ID: 1, Name: "Laptop Lenovo", Price: money.New(300000000, "IDR")}
 
 	t.Run("success", func(t *testing.T) {
 		mockProductRepo.On("Delete", mock.Anything, mock.AnythingOfType("uint32")).Return(nil).Once()
@@ -114,16 +185,63 @@ func TestProductService_Delete(t *testing.T) {
 	})
 }
 
-func TestProductService_UpdateStock(t *testing.T) {
+func TestProductService_AdjustStock(t *testing.T) {
+	tests := []struct {
+		reason domain.StockReason
+		delta  int
+		valid  bool
+	}{
+		{domain.StockRestock, 10, true},
+		{domain.StockRestock, -10, false},
+		{domain.StockReturn, 1, true},
+		{domain.StockReturn, 0, false},
+		{domain.StockDamage, -2, true},
+		{domain.StockDamage, 2, false},
+		{domain.StockOrder, -2, false},
+		{domain.StockCorrection, 5, false},
+		{"stolen", -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.reason)+" "+strconv.Itoa(tt.delta), func(t *testing.T) {
+			mockProductRepo := new(mocks.ProductRepository)
+			movement := &domain.StockMovement{ProductID: 1, Delta: tt.delta, Reason: tt.reason}
+			if tt.valid {
+				mockProductRepo.On("AdjustStock", mock.Anything, movement).Return(nil).Once()
+			}
+			p := service.NewProductService(mockProductRepo, time.Second*2)
+
+			err := p.AdjustStock(context.TODO(), movement)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, domain.ErrInvalidAdjustment, err)
+			}
+			mockProductRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductService_FetchMovements(t *testing.T) {
 	mockProductRepo := new(mocks.ProductRepository)
-	mockProduct := domain.Product{ID: 1, Stock: 15}
+	mockMovements := []domain.StockMovement{{ID: 1, ProductID: 1, Delta: 10, Reason: domain.StockInitial}}
+	p := service.NewProductService(mockProductRepo, time.Second*2)
 
 	t.Run("success", func(t *testing.T) {
-		mockProductRepo.On("Update", mock.Anything, &mockProduct).Once().Return(nil)
-		p := service.NewProductService(mockProductRepo, time.Second*2)
+		mockProductRepo.On("FetchMovements", mock.Anything, uint32(1)).Return(mockMovements, nil).Once()
 
-		err := p.UpdateStock(context.TODO(), &mockProduct, mockProduct.ID)
+		list, err := p.FetchMovements(context.TODO(), 1)
 		assert.NoError(t, err)
+		assert.Equal(t, mockMovements, list)
 		mockProductRepo.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+
+	t.Run("not found", func(t *testing.T) {
+		mockProductRepo.On("FetchMovements", mock.Anything, uint32(9)).Return(nil, domain.ErrNotFound).Once()
+
+		list, err := p.FetchMovements(context.TODO(), 9)
+		assert.Equal(t, domain.ErrNotFound, err)
+		assert.Len(t, list, 0)
+		mockProductRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"log"
+	"product/domain"
+	"time"
+)
+
+// Defaults of a reservation service configured without them
+const (
+	DefaultReservationTTL = 15 * time.Minute
+	DefaultReapInterval   = 30 * time.Second
+)
+
+type reservationService struct {
+	reservationRepo domain.ReservationRepository
+	ttl             time.Duration
+	contextTimeout  time.Duration
+}
+
+// NewReservationService will create a service holding stock for ttl, or for
+// DefaultReservationTTL when ttl is not positive
+func NewReservationService(reservation domain.ReservationRepository, ttl, timeout time.Duration) domain.ReservationService {
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
+	}
+	return &reservationService{
+		reservationRepo: reservation,
+		ttl:             ttl,
+		contextTimeout:  timeout,
+	}
+}
+
+func (rs *reservationService) GetByID(c context.Context, id uint32) (domain.Reservation, error) {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	return rs.reservationRepo.GetByID(ctx, id)
+}
+
+// Hold will reserve the qty of a product for the ttl of the service
+func (rs *reservationService) Hold(c context.Context, reservation *domain.Reservation) (err error) {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	if reservation.Qty <= 0 {
+		return domain.ErrInvalidQty
+	}
+
+	reservation.ExpiresAt = time.Now().Add(rs.ttl)
+	return rs.reservationRepo.Hold(ctx, reservation)
+}
+
+// Commit will take the qty held by a reservation that has not expired out of the stock
+func (rs *reservationService) Commit(c context.Context, id uint32) error {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	return rs.reservationRepo.Commit(ctx, id, time.Now())
+}
+
+func (rs *reservationService) Release(c context.Context, id uint32) error {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	return rs.reservationRepo.Release(ctx, id)
+}
+
+// HoldOrder will reserve the qty of every item for an order for the ttl of the service,
+// all the items are held or none, and return the reply to send for the order. An order is
+// handled once, when it is delivered again the reply recorded the first time is returned
+// and nothing is held twice. An error is only returned when the order could not be
+// handled yet and should be tried again.
+func (rs *reservationService) HoldOrder(c context.Context, orderID uint32, items []domain.OrderItem) (reply domain.OrderReply, err error) {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	if orderID == 0 {
+		return reply, domain.ErrInvalidOrder
+	}
+
+	reply, err = rs.reservationRepo.GetOrderReply(ctx, orderID)
+	if err != domain.ErrNotFound {
+		return
+	}
+
+	err = validItems(items)
+	if err == nil {
+		err = rs.reservationRepo.HoldOrder(ctx, orderID, items, time.Now().Add(rs.ttl))
+	}
+
+	reply = domain.OrderReply{OrderID: orderID, Accepted: true}
+	if reason, ok := rejectReason(err); ok {
+		reply = domain.OrderReply{OrderID: orderID, Reason: reason}
+		err = rs.reservationRepo.RejectOrder(ctx, orderID, reason)
+	}
+	if err == domain.ErrOrderHandled {
+		// Another delivery of the order was handled in the meantime
+		return rs.reservationRepo.GetOrderReply(ctx, orderID)
+	}
+	if err != nil {
+		return domain.OrderReply{}, err
+	}
+	return
+}
+
+// rejectReason will return the reason code of an error that rejects an order for good,
+// false for an error the order may get past when it is handled again
+func rejectReason(err error) (string, bool) {
+	switch err {
+	case domain.ErrInsufficientStock:
+		return domain.ReasonInsufficientStock, true
+	case domain.ErrNotFound:
+		return domain.ReasonProductNotFound, true
+	case domain.ErrHasVariants:
+		return domain.ReasonVariantRequired, true
+	case domain.ErrNoItems, domain.ErrInvalidQty:
+		return domain.ReasonInvalidItems, true
+	}
+	return "", false
+}
+
+// CommitOrder will take the stock held for an order, failing when its holds have expired
+func (rs *reservationService) CommitOrder(c context.Context, orderID uint32) error {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	// The reservations made outside of an order have no order id
+	if orderID == 0 {
+		return domain.ErrNotFound
+	}
+	return rs.reservationRepo.CommitOrder(ctx, orderID, time.Now())
+}
+
+// ReleaseOrder will give back the stock held or taken for an order
+func (rs *reservationService) ReleaseOrder(c context.Context, orderID uint32) error {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	if orderID == 0 {
+		return domain.ErrNotFound
+	}
+	return rs.reservationRepo.ReleaseOrder(ctx, orderID)
+}
+
+// Expire will close the reservations that expired, returning how many were closed
+func (rs *reservationService) Expire(c context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(c, rs.contextTimeout)
+	defer cancel()
+
+	return rs.reservationRepo.Expire(ctx, time.Now())
+}
+
+// RunReaper will expire the reservations every interval, or every DefaultReapInterval
+// when interval is not positive, until ctx is done
+func RunReaper(ctx context.Context, rs domain.ReservationService, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := rs.Expire(ctx)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("expired %d reservations", expired)
+			}
+		}
+	}
+}
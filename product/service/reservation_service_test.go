@@ -0,0 +1,196 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"product/domain"
+	"product/domain/mocks"
+	"product/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReservationService_Hold(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+		mockReservationRepo.On("Hold", mock.Anything, mock.AnythingOfType("*domain.Reservation")).Return(nil).Once()
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		reservation := domain.Reservation{ProductID: 1, Qty: 2}
+		err := r.Hold(context.TODO(), &reservation)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), reservation.ExpiresAt, time.Second)
+		mockReservationRepo.AssertExpectations(t)
+	})
+
+	t.Run("default ttl", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+		mockReservationRepo.On("Hold", mock.Anything, mock.AnythingOfType("*domain.Reservation")).Return(nil).Once()
+
+		r := service.NewReservationService(mockReservationRepo, 0, time.Second*2)
+		reservation := domain.Reservation{ProductID: 1, Qty: 2}
+		err := r.Hold(context.TODO(), &reservation)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(service.DefaultReservationTTL), reservation.ExpiresAt, time.Second)
+	})
+
+	t.Run("invalid qty", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		err := r.Hold(context.TODO(), &domain.Reservation{ProductID: 1, Qty: 0})
+		assert.Equal(t, domain.ErrInvalidQty, err)
+		mockReservationRepo.AssertNotCalled(t, "Hold", mock.Anything, mock.Anything)
+	})
+}
+
+func TestReservationService_Commit(t *testing.T) {
+	mockReservationRepo := new(mocks.ReservationRepository)
+	mockReservationRepo.On("Commit", mock.Anything, uint32(4), mock.AnythingOfType("time.Time")).Return(domain.ErrReservationExpired).Once()
+
+	r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+	err := r.Commit(context.TODO(), 4)
+	assert.Equal(t, domain.ErrReservationExpired, err)
+	mockReservationRepo.AssertExpectations(t)
+}
+
+func TestReservationService_HoldOrder(t *testing.T) {
+	items := []domain.OrderItem{{ProductID: 3, Qty: 2}, {ProductID: 4, Qty: 1}}
+
+	t.Run("success", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+		mockReservationRepo.On("GetOrderReply", mock.Anything, uint32(7)).Return(domain.OrderReply{}, domain.ErrNotFound).Once()
+		mockReservationRepo.On("HoldOrder", mock.Anything, uint32(7), items, mock.MatchedBy(func(expiresAt time.Time) bool {
+			return time.Until(expiresAt) > 59*time.Second && time.Until(expiresAt) <= time.Minute
+		})).Return(nil).Once()
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		reply, err := r.HoldOrder(context.TODO(), 7, items)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderReply{OrderID: 7, Accepted: true}, reply)
+		mockReservationRepo.AssertExpectations(t)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			items  []domain.OrderItem
+			err    error
+			reason string
+		}{
+			{"insufficient stock", items, domain.ErrInsufficientStock, domain.ReasonInsufficientStock},
+			{"product not found", items, domain.ErrNotFound, domain.ReasonProductNotFound},
+			{"variant required", items, domain.ErrHasVariants, domain.ReasonVariantRequired},
+			{"no items", nil, nil, domain.ReasonInvalidItems},
+			{"invalid qty", []domain.OrderItem{{ProductID: 3, Qty: 0}}, nil, domain.ReasonInvalidItems},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				mockReservationRepo := new(mocks.ReservationRepository)
+				mockReservationRepo.On("GetOrderReply", mock.Anything, uint32(8)).Return(domain.OrderReply{}, domain.ErrNotFound).Once()
+				if c.err != nil {
+					mockReservationRepo.On("HoldOrder", mock.Anything, uint32(8), c.items, mock.AnythingOfType("time.Time")).Return(c.err).Once()
+				}
+				mockReservationRepo.On("RejectOrder", mock.Anything, uint32(8), c.reason).Return(nil).Once()
+
+				r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+				reply, err := r.HoldOrder(context.TODO(), 8, c.items)
+				assert.NoError(t, err)
+				assert.Equal(t, domain.OrderReply{OrderID: 8, Reason: c.reason}, reply)
+				mockReservationRepo.AssertExpectations(t)
+			})
+		}
+	})
+
+	t.Run("handled before", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+		stored := domain.OrderReply{OrderID: 8, Reason: domain.ReasonInsufficientStock}
+		mockReservationRepo.On("GetOrderReply", mock.Anything, uint32(8)).Return(stored, nil).Once()
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		reply, err := r.HoldOrder(context.TODO(), 8, items)
+		assert.NoError(t, err)
+		assert.Equal(t, stored, reply)
+		mockReservationRepo.AssertNotCalled(t, "HoldOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("handled meanwhile", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+		stored := domain.OrderReply{OrderID: 7, Accepted: true}
+		mockReservationRepo.On("GetOrderReply", mock.Anything, uint32(7)).Return(domain.OrderReply{}, domain.ErrNotFound).Once()
+		mockReservationRepo.On("HoldOrder", mock.Anything, uint32(7), items, mock.AnythingOfType("time.Time")).Return(domain.ErrOrderHandled).Once()
+		mockReservationRepo.On("GetOrderReply", mock.Anything, uint32(7)).Return(stored, nil).Once()
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		reply, err := r.HoldOrder(context.TODO(), 7, items)
+		assert.NoError(t, err)
+		assert.Equal(t, stored, reply)
+		mockReservationRepo.AssertExpectations(t)
+	})
+
+	t.Run("unexpected error", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+		mockReservationRepo.On("GetOrderReply", mock.Anything, uint32(7)).Return(domain.OrderReply{}, domain.ErrNotFound).Once()
+		mockReservationRepo.On("HoldOrder", mock.Anything, uint32(7), items, mock.AnythingOfType("time.Time")).Return(errors.New("Unexpected Error")).Once()
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		_, err := r.HoldOrder(context.TODO(), 7, items)
+		assert.Error(t, err)
+		mockReservationRepo.AssertNotCalled(t, "RejectOrder", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid order", func(t *testing.T) {
+		mockReservationRepo := new(mocks.ReservationRepository)
+
+		r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+		_, err := r.HoldOrder(context.TODO(), 0, items)
+		assert.Equal(t, domain.ErrInvalidOrder, err)
+		mockReservationRepo.AssertNotCalled(t, "GetOrderReply", mock.Anything, mock.Anything)
+	})
+}
+
+func TestReservationService_CommitOrder(t *testing.T) {
+	mockReservationRepo := new(mocks.ReservationRepository)
+	mockReservationRepo.On("CommitOrder", mock.Anything, uint32(7), mock.AnythingOfType("time.Time")).Return(domain.ErrReservationExpired).Once()
+
+	r := service.NewReservationService(mockReservationRepo, time.Minute, time.Second*2)
+	assert.Equal(t, domain.ErrReservationExpired, r.CommitOrder(context.TODO(), 7))
+	// The reservations made outside of an order are not an order
+	assert.Equal(t, domain.ErrNotFound, r.CommitOrder(context.TODO(), 0))
+	assert.Equal(t, domain.ErrNotFound, r.ReleaseOrder(context.TODO(), 0))
+	mockReservationRepo.AssertExpectations(t)
+}
+
+func TestRunReaper(t *testing.T) {
+	mockReservationService := new(mocks.ReservationService)
+	expired := make(chan struct{}, 1)
+	mockReservationService.On("Expire", mock.Anything).Return(int64(2), nil).Run(func(mock.Arguments) {
+		select {
+		case expired <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.RunReaper(ctx, mockReservationService, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("the reaper did not expire the reservations")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the reaper did not stop once its context was done")
+	}
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"platform/pagination"
 	"strconv"
 	"user/domain"
 )
@@ -25,17 +26,25 @@ func NewUserController(e *echo.Echo, us domain.UserService) {
 	group.DELETE("/users/:id", controller.Delete)
 }
 
-// Fetch method will fetch all users data
+// Fetch method will fetch a page of users, filtered by ?email= and sorted by ?sort=
 func (uc *UserController) Fetch(c echo.Context) error  {
+	q, err := pagination.Parse(c, domain.UserSorts...)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"err": err.Error(),
+		})
+	}
+
+	filter := domain.UserFilter{Email: c.QueryParam("email")}
 	ctx := c.Request().Context()
 
-	users, err := uc.UserService.Fetch(ctx)
+	users, next, err := uc.UserService.Fetch(ctx, filter, q)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, echo.Map{
+		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"err": err.Error(),
 		})
 	}
-	return c.JSON(http.StatusOK, users)
+	return c.JSON(http.StatusOK, pagination.Page{Data: users, NextCursor: next})
 }
 
 func (uc *UserController) GetByID(c echo.Context) error  {
@@ -50,11 +59,16 @@ func (uc *UserController) GetByID(c echo.Context) error  {
 	ctx := c.Request().Context()
 
 	user, err := uc.UserService.GetByID(ctx, id)
-	if err != nil {
+	if err == domain.ErrNotFound {
 		return c.JSON(http.StatusNotFound, echo.Map{
 			"err": err.Error(),
 		})
 	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"err": err.Error(),
+		})
+	}
 
 	return c.JSON(http.StatusOK, user)
 }
@@ -2,12 +2,14 @@ package controller_test
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"platform/pagination"
 	"strconv"
 	"strings"
 	"testing"
@@ -26,10 +28,11 @@ func TestUserController_Fetch(t *testing.T) {
 	mockListUser := make([]domain.User, 0)
 	mockListUser = append(mockListUser, mockUser)
 
-	mockUserService.On("Fetch", mock.Anything).Return(mockListUser, nil)
+	query := pagination.Query{Limit: 5, Sort: "email"}
+	mockUserService.On("Fetch", mock.Anything, domain.UserFilter{Email: "a@mail.com"}, query).Return(mockListUser, "next", nil)
 
 	e := echo.New()
-	req, err := http.NewRequest(echo.GET, "/api/v1/users", strings.NewReader(""))
+	req, err := http.NewRequest(echo.GET, "/api/v1/users?email=a@mail.com&limit=5&sort=email", strings.NewReader(""))
 	assert.NoError(t, err)
 
 	rec := httptest.NewRecorder()
@@ -38,11 +41,25 @@ func TestUserController_Fetch(t *testing.T) {
 	err = handler.Fetch(c)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data":[{"id":0,"email":""}],"next_cursor":"next"}`, rec.Body.String())
 	mockUserService.AssertExpectations(t)
 }
 
+func TestUserController_Fetch_BadQuery(t *testing.T) {
+	e := echo.New()
+	req, err := http.NewRequest(echo.GET, "/api/v1/users?sort=password", strings.NewReader(""))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	handler := controller.UserController{UserService: new(mocks.UserService)}
+	err = handler.Fetch(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
 func TestUserController_Fetch_Error(t *testing.T) {
-	mockUserService.On("Fetch", mock.Anything).Return(nil, nil)
+	mockUserService.On("Fetch", mock.Anything, domain.UserFilter{}, mock.Anything).Return(nil, "", nil)
 
 	e := echo.New()
 	req, err := http.NewRequest(echo.GET, "/api/v1/users", strings.NewReader(""))
@@ -81,6 +98,40 @@ func TestUserController_GetByID(t *testing.T) {
 	mockUserService.AssertExpectations(t)
 }
 
+func TestUserController_GetByID_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+		{"database down", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserService := new(mocks.UserService)
+			mockUserService.On("GetByID", mock.Anything, uint32(9)).Return(domain.User{}, tt.err).Once()
+
+			e := echo.New()
+			req, err := http.NewRequest(echo.GET, "/api/v1/users/9", strings.NewReader(""))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("api/v1/users/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("9")
+			handler := controller.UserController{UserService: mockUserService}
+			err = handler.GetByID(c)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.code, rec.Code)
+			mockUserService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestUserController_Store(t *testing.T) {
 	mockUser := domain.User{
 		Email:     "senowijayanto@gmail.com",
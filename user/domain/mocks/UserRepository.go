@@ -3,6 +3,7 @@ package mocks
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"platform/pagination"
 	"user/domain"
 )
 
@@ -10,26 +11,15 @@ type UserRepository struct {
 	mock.Mock
 }
 
-func (_m *UserRepository) Fetch(ctx context.Context) ([]domain.User, error)  {
-	ret := _m.Called(ctx)
+func (_m *UserRepository) Fetch(ctx context.Context, filter domain.UserFilter, q pagination.Query) ([]domain.User, string, error)  {
+	ret := _m.Called(ctx, filter, q)
 
 	var r0 []domain.User
-	if rf, ok := ret.Get(0).(func(context.Context) []domain.User); ok {
-		r0 = rf(ctx)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]domain.User)
-		}
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]domain.User)
 	}
 
-	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
+	return r0, ret.String(1), ret.Error(2)
 }
 
 func (_m *UserRepository) GetByID(ctx context.Context, id uint32) (domain.User, error)  {
@@ -2,9 +2,13 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"platform/pagination"
 	"time"
 )
 
+var ErrNotFound = errors.New("user not found")
+
 type User struct {
 	ID        uint32    `json:"id"`
 	Email     string    `json:"email" validate:"required"`
@@ -12,8 +16,16 @@ type User struct {
 	UpdatedAt time.Time `json:"-"`
 }
 
+// UserSorts are the fields a list of users can be sorted by, besides id
+var UserSorts = []string{"email", "created_at"}
+
+// UserFilter narrows a list of users, a field left empty does not filter
+type UserFilter struct {
+	Email string
+}
+
 type UserRepository interface {
-	Fetch(ctx context.Context) (users []User, err error)
+	Fetch(ctx context.Context, filter UserFilter, q pagination.Query) (users []User, next string, err error)
 	GetByID(ctx context.Context, id uint32) (user User, err error)
 	Store(ctx context.Context, user *User) error
 	Update(ctx context.Context, user *User, id uint32) error
@@ -21,7 +33,7 @@ type UserRepository interface {
 }
 
 type UserService interface {
-	Fetch(ctx context.Context) ([]User, error)
+	Fetch(ctx context.Context, filter UserFilter, q pagination.Query) ([]User, string, error)
 	GetByID(ctx context.Context, id uint32) (User, error)
 	Store(context.Context, *User) error
 	Update(ctx context.Context, user *User, id uint32) error
@@ -4,13 +4,10 @@ go 1.16
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
-	github.com/go-playground/universal-translator v0.17.0 // indirect
-	github.com/go-playground/validator v9.31.0+incompatible
-	github.com/go-sql-driver/mysql v1.6.0
 	github.com/labstack/echo/v4 v4.2.2
-	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/spf13/viper v1.7.1
-	github.com/stretchr/testify v1.7.0
-	golang.org/x/sys v0.0.0-20201210223839-7e3030f88018 // indirect
-	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
+	github.com/stretchr/testify v1.7.1
+	platform v0.0.0
 )
+
+replace platform => ../platform
@@ -1,53 +1,49 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
 	"log"
-	"net/url"
-	"time"
+	"os"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/viper"
 
+	"platform/config"
+	"platform/database"
+	"platform/health"
+	"platform/metrics"
+	"platform/migrate"
+	"platform/server"
+	"platform/tracing"
+
 	_userController "user/controller"
+	_userMigrations "user/migrations"
 	_userRepo "user/repository"
 	_userService "user/service"
 )
 
 func init() {
-	viper.SetConfigFile(`config.json`)
-	err := viper.ReadInConfig()
+	err := config.Load(`config.json`)
 	if err != nil {
 		panic(err)
 	}
-
-	if viper.GetBool(`debug`) {
-		log.Println("Service RUN on DEBUG mode")
-	}
 }
 
 func main() {
-	// Setup database
-	dbHost := viper.GetString(`database.host`)
-	dbPort := viper.GetString(`database.port`)
-	dbUser := viper.GetString(`database.user`)
-	dbPass := viper.GetString(`database.pass`)
-	dbName := viper.GetString(`database.name`)
-	fmt.Println(dbPass)
-	connection := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPass, dbHost, dbPort, dbName)
-	val := url.Values{}
-	val.Add("parseTime", "1")
-	val.Add("loc", "Asia/Jakarta")
-	dsn := fmt.Sprintf("%s?%s", connection, val.Encode())
-	dbConn, err := sql.Open(`mysql`, dsn)
-
+	// Setup tracing, the spans left are flushed once everything else is closed
+	shutdownTracing, err := tracing.Setup(tracing.NewConfig(), `user`)
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = dbConn.Ping()
+
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	// Setup database
+	dbConn, err := database.Open(database.NewConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -55,33 +51,54 @@ func main() {
 	defer func() {
 		err := dbConn.Close()
 		if err != nil {
-			log.Fatal(err)
+			log.Println(err)
 		}
 	}()
 
-	// Setup Echo
-	e := echo.New()
-
-	// Setup middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
+	// Setup migrations, `binary migrate up|down [steps]|status` only runs them
+	migrator, err := migrate.New(dbConn, _userMigrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = migrate.Command(context.Background(), migrator, os.Args[2:], os.Stdout)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if viper.GetBool(`database.migrate`) {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range applied {
+			log.Printf("applied migration %04d_%s", migration.Version, migration.Name)
+		}
+	}
 
-	// CORS
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
-	}))
+	// Setup Echo
+	e := server.New()
 
 	// Setup User Repository
 	userRepo := _userRepo.NewUserRepository(dbConn)
 
 	// Setup User Service
-	timeoutContext := time.Duration(viper.GetInt("context.timeout")) * time.Second
+	timeoutContext := config.ContextTimeout()
 	userService := _userService.NewUserService(userRepo, timeoutContext)
 
 	// Setup User Controller
 	_userController.NewUserController(e, userService)
 
-	log.Fatal(e.Start(viper.GetString("server.address")))
+	// Setup Health Controller
+	health.NewHealthController(e, health.Check{Name: "database", Checker: health.DB(dbConn)})
+	database.NewStatsController(e, dbConn)
+	metrics.NewMetricsController(e, metrics.DB(dbConn, viper.GetString(`database.name`)))
 
+	// Serve until SIGTERM, the deferred closes run once the requests in flight are done
+	err = server.Run(e, viper.GetString("server.address"), config.ShutdownTimeout())
+	if err != nil {
+		log.Println(err)
+	}
 }
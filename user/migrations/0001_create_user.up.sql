@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	email VARCHAR(255) NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS user_created ON user;
+DROP INDEX IF EXISTS user_email ON user;
@@ -0,0 +1,3 @@
+-- Lists of users are paged by sort column then id
+CREATE INDEX IF NOT EXISTS user_email ON user (email, id);
+CREATE INDEX IF NOT EXISTS user_created ON user (created_at, id);
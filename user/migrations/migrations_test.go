@@ -0,0 +1,21 @@
+package migrations_test
+
+import (
+	"platform/migrate"
+	"testing"
+	"user/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	list, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, migration := range list {
+		assert.Equal(t, int64(i+1), migration.Version, "versions follow each other")
+		assert.NotEmpty(t, migration.Down, "%04d_%s can be reverted", migration.Version, migration.Name)
+	}
+}
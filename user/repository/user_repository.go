@@ -4,16 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"platform/pagination"
 	"time"
 	"user/domain"
 )
 
-var (
-	t = time.Now()
-	ts = t.Format("2006-01-02 15:04:05")
-)
-
 type userRepository struct {
 	Conn *sql.DB
 }
@@ -23,34 +18,57 @@ func NewUserRepository(Conn *sql.DB) domain.UserRepository {
 	return &userRepository{Conn}
 }
 
-func (ur *userRepository) Fetch(ctx context.Context) (users []domain.User, err error)  {
-	query := `SELECT id, email, created_at, updated_at FROM user`
-	rows, err := ur.Conn.QueryContext(ctx, query)
+// Fetch will return a page of the users matching the filter, and the cursor of the next
+// page when there is one
+func (ur *userRepository) Fetch(ctx context.Context, filter domain.UserFilter, q pagination.Query) (users []domain.User, next string, err error)  {
+	var conds []string
+	var args []interface{}
+	if filter.Email != "" {
+		conds = append(conds, "email=?")
+		args = append(args, filter.Email)
+	}
+
+	keyset, keysetArgs, orderBy := q.Keyset()
+	query := `SELECT id, email, created_at, updated_at FROM user` + pagination.Where(append(conds, keyset)...) + orderBy
+	rows, err := ur.Conn.QueryContext(ctx, query, append(args, keysetArgs...)...)
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, "", err
 	}
-	defer func() {
-		errRow := rows.Close()
-		if errRow != nil {
-			log.Fatal(errRow)
-		}
-	}()
+	defer rows.Close()
 
 	users = make([]domain.User, 0)
 	for rows.Next() {
 		t := domain.User{}
 		err = rows.Scan(&t.ID, &t.Email, &t.CreatedAt, &t.UpdatedAt)
 		if err != nil {
-			log.Fatal(err)
-			return nil, err
+			return nil, "", err
 		}
 		users = append(users,t)
 	}
+	err = rows.Err()
+	if err != nil {
+		return nil, "", err
+	}
 
+	if q.More(len(users)) {
+		users = users[:q.Limit]
+		last := users[q.Limit-1]
+		next = q.Cursor(sortValue(last, q.Sort), last.ID)
+	}
 	return
 }
 
+// sortValue will return the value of the sort field of user, as a cursor holds it
+func sortValue(user domain.User, sort string) string {
+	switch sort {
+	case "email":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt.Format(pagination.TimeLayout)
+	}
+	return ""
+}
+
 func (ur *userRepository) GetByID(ctx context.Context, id uint32) (user domain.User, err error)  {
 	query := `SELECT id, email, created_at, updated_at FROM user WHERE id=?`
 
@@ -67,6 +85,9 @@ func (ur *userRepository) GetByID(ctx context.Context, id uint32) (user domain.U
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		)
+	if err == sql.ErrNoRows {
+		err = domain.ErrNotFound
+	}
 	return
 }
 
@@ -77,6 +98,8 @@ func (ur *userRepository) Store(ctx context.Context, user *domain.User) (err err
 		return
 	}
 
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
 	res, err := stmt.ExecContext(ctx, user.Email, ts, ts)
 	if err != nil {
 		return
@@ -87,6 +110,8 @@ func (ur *userRepository) Store(ctx context.Context, user *domain.User) (err err
 		return
 	}
 	user.ID = uint32(lastID)
+	user.CreatedAt = t
+	user.UpdatedAt = t
 	return
 }
 
@@ -98,7 +123,8 @@ func (ur *userRepository) Update(ctx context.Context, user *domain.User, id uint
 		return
 	}
 
-
+	t := time.Now()
+	ts := t.Format("2006-01-02 15:04:05")
 	res, err := stmt.ExecContext(ctx, user.Email, ts, id)
 	if err != nil {
 		return
@@ -6,6 +6,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"log"
+	"platform/pagination"
 	"regexp"
 	"testing"
 	"time"
@@ -13,8 +14,6 @@ import (
 	"user/repository"
 )
 var (
-	t    = time.Now()
-	ts   = t.Format("2006-01-02 15:04:05")
 	user = &domain.User{
 		ID:    1,
 		Email: "senowijayanto@gmail.com",
@@ -37,17 +36,54 @@ func TestUserRepository_Fetch(t *testing.T) {
 		db.Close()
 	}()
 
-	query := "SELECT id, email, created_at, updated_at FROM user"
+	columns := []string{"id", "email", "created_at", "updated_at"}
 
-	rows := sqlmock.NewRows([]string{"id", "email", "created_at", "updated_at"}).
-		AddRow(user.ID, user.Email, user.CreatedAt, user.UpdatedAt)
+	t.Run("success", func(t *testing.T) {
+		query := regexp.QuoteMeta("SELECT id, email, created_at, updated_at FROM user ORDER BY id ASC LIMIT 21")
 
-	mock.ExpectQuery(query).WillReturnRows(rows)
+		rows := sqlmock.NewRows(columns).
+			AddRow(user.ID, user.Email, user.CreatedAt, user.UpdatedAt)
 
-	users, err := repo.Fetch(context.TODO())
-	assert.NotEmpty(t, users)
-	assert.NoError(t, err)
-	assert.Len(t, users, 1)
+		mock.ExpectQuery(query).WillReturnRows(rows)
+
+		users, next, err := repo.Fetch(context.TODO(), domain.UserFilter{}, pagination.Query{Limit: 20, Sort: "id"})
+		assert.NotEmpty(t, users)
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Empty(t, next)
+	})
+
+	t.Run("next page", func(t *testing.T) {
+		query := regexp.QuoteMeta("SELECT id, email, created_at, updated_at FROM user WHERE email=? AND (created_at < ? OR (created_at = ? AND id < ?)) ORDER BY created_at DESC, id DESC LIMIT 3")
+		created := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+
+		rows := sqlmock.NewRows(columns).
+			AddRow(4, user.Email, created, created).
+			AddRow(3, user.Email, created, created).
+			AddRow(2, user.Email, created.Add(-time.Hour), created)
+
+		q := pagination.Query{Limit: 2, Sort: "created_at", Desc: true, After: &pagination.Cursor{Sort: "-created_at", Value: "2021-05-01 11:00:00", ID: 9}}
+		mock.ExpectQuery(query).WithArgs(user.Email, "2021-05-01 11:00:00", "2021-05-01 11:00:00", 9).WillReturnRows(rows)
+
+		users, next, err := repo.Fetch(context.TODO(), domain.UserFilter{Email: user.Email}, q)
+		assert.NoError(t, err)
+		assert.Len(t, users, 2)
+		assert.Equal(t, q.Cursor("2021-05-01 10:00:00", 3), next)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUserRepository_Fetch_Error(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewUserRepository(db)
+	defer db.Close()
+
+	query := regexp.QuoteMeta("SELECT id, email, created_at, updated_at FROM user ORDER BY id ASC LIMIT 21")
+	mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+
+	_, _, err := repo.Fetch(context.TODO(), domain.UserFilter{}, pagination.Query{Limit: 20, Sort: "id"})
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestUserRepository_GetByID(t *testing.T) {
@@ -71,6 +107,23 @@ func TestUserRepository_GetByID(t *testing.T) {
 
 }
 
+func TestUserRepository_GetByID_NotFound(t *testing.T) {
+	db, mock := NewMock()
+	repo := repository.NewUserRepository(db)
+	defer func() {
+		db.Close()
+	}()
+
+	query := "SELECT id, email, created_at, updated_at FROM user WHERE id=\\?"
+
+	prep := mock.ExpectPrepare(query)
+	prep.ExpectQuery().WithArgs(uint32(9)).WillReturnRows(sqlmock.NewRows([]string{"id", "email", "created_at", "updated_at"}))
+
+	_, err := repo.GetByID(context.TODO(), 9)
+	assert.Equal(t, domain.ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUserRepository_Store(t *testing.T) {
 	db, mock := NewMock()
 	repo := repository.NewUserRepository(db)
@@ -81,7 +134,7 @@ func TestUserRepository_Store(t *testing.T) {
 	query := regexp.QuoteMeta("INSERT INTO user (email, created_at, updated_at) VALUES (?, ?, ?)")
 	prep := mock.ExpectPrepare(query)
 	prep.ExpectExec().
-		WithArgs(user.Email, ts, ts).
+		WithArgs(user.Email, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := repo.Store(context.TODO(), user)
@@ -99,7 +152,7 @@ func TestUserRepository_Update(t *testing.T) {
 	query := regexp.QuoteMeta("UPDATE user SET email=?, updated_at=? WHERE id=?")
 
 	prep := mock.ExpectPrepare(query)
-	prep.ExpectExec().WithArgs(user.Email, ts, user.ID).WillReturnResult(sqlmock.NewResult(1,1))
+	prep.ExpectExec().WithArgs(user.Email, sqlmock.AnyArg(), user.ID).WillReturnResult(sqlmock.NewResult(1,1))
 
 	err := repo.Update(context.TODO(), user, uint32(1))
 	assert.NoError(t, err)
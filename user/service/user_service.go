@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"platform/pagination"
 	"time"
 	"user/domain"
 )
@@ -19,13 +20,13 @@ func NewUserService(user domain.UserRepository, timeout time.Duration) domain.Us
 	}
 }
 
-func (us *userService) Fetch(c context.Context) (users []domain.User, err error) {
+func (us *userService) Fetch(c context.Context, filter domain.UserFilter, q pagination.Query) (users []domain.User, next string, err error) {
 	ctx, cancel := context.WithTimeout(c, us.contextTimeout)
 	defer cancel()
 
-	users, err = us.userRepo.Fetch(ctx)
+	users, next, err = us.userRepo.Fetch(ctx, filter, q)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	return
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"platform/pagination"
 	"testing"
 	"time"
 	"user/domain"
@@ -21,19 +22,21 @@ var (
 func TestUserService_Fetch(t *testing.T) {
 	mockListUser := make([]domain.User, 0)
 	mockListUser = append(mockListUser, mockUser)
+	query := pagination.Query{Limit: 20, Sort: "id"}
 
 	t.Run("success", func(t *testing.T) {
-		mockUserRepo.On("Fetch", mock.Anything).Return(mockListUser, nil).Once()
-		list, err := u.Fetch(context.TODO())
+		mockUserRepo.On("Fetch", mock.Anything, domain.UserFilter{}, query).Return(mockListUser, "next", nil).Once()
+		list, next, err := u.Fetch(context.TODO(), domain.UserFilter{}, query)
 		assert.NoError(t, err)
 		assert.Len(t, list, len(mockListUser))
+		assert.Equal(t, "next", next)
 
 		mockUserRepo.AssertExpectations(t)
 	})
 
 	t.Run("error", func(t *testing.T) {
-		mockUserRepo.On("Fetch", mock.Anything).Return(nil, errors.New("unexpected error")).Once()
-		list, err := u.Fetch(context.TODO())
+		mockUserRepo.On("Fetch", mock.Anything, domain.UserFilter{}, query).Return(nil, "", errors.New("unexpected error")).Once()
+		list, _, err := u.Fetch(context.TODO(), domain.UserFilter{}, query)
 
 		assert.Error(t, err)
 		assert.Len(t, list, 0)